@@ -3,20 +3,28 @@ package main
 import (
 	"fmt"
 	"time"
+
+	"go-concurrency-exercises/pkg/clock"
 )
 
 func main() {
+	fmt.Println(receive(clock.New(), 5*time.Second, 3*time.Second))
+}
+
+// receive waits for a value that takes work to arrive, giving up after
+// timeout. It returns the value, or "timeout".
+func receive(c clock.Clock, work, timeout time.Duration) string {
 	ch := make(chan int, 1)
 
 	go func() {
-		time.Sleep(5 * time.Second)
+		c.Sleep(work)
 		ch <- 1
 	}()
 
 	select {
 	case v := <-ch:
-		fmt.Println(v)
-	case <-time.After(3 * time.Second):
-		fmt.Println("timeout")
+		return fmt.Sprint(v)
+	case <-c.After(timeout):
+		return "timeout"
 	}
 }
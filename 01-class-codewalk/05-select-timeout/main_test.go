@@ -0,0 +1,48 @@
+package main
+
+import (
+	"testing"
+	"time"
+
+	"go-concurrency-exercises/pkg/clock"
+	"go-concurrency-exercises/pkg/leakcheck"
+)
+
+func TestReceive(t *testing.T) {
+	tests := []struct {
+		name          string
+		work, timeout time.Duration
+		want          string
+	}{
+		{"timeout", 5 * time.Second, 3 * time.Second, "timeout"},
+		{"value", 1 * time.Second, 3 * time.Second, "1"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			leakcheck.Check(t)
+
+			c := clock.NewFake(time.Now())
+			got := make(chan string)
+			go func() {
+				got <- receive(c, tt.work, tt.timeout)
+			}()
+
+			// Wait for both the sleep and the timeout to start,
+			// then move time on to whichever ends first.
+			c.BlockUntil(2)
+			if tt.work < tt.timeout {
+				c.Advance(tt.work)
+			} else {
+				c.Advance(tt.timeout)
+			}
+
+			if g := <-got; g != tt.want {
+				t.Errorf("got %q, want %q", g, tt.want)
+			}
+
+			// Let the sending goroutine finish.
+			c.Advance(tt.work)
+		})
+	}
+}
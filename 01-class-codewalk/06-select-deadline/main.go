@@ -3,20 +3,30 @@ package main
 import (
 	"fmt"
 	"time"
+
+	"go-concurrency-exercises/pkg/clock"
 )
 
 func main() {
-	ch := make(chan string)
+	fmt.Println(poll(clock.New(), 1*time.Second))
+}
+
+// poll starts a goroutine that sends a message after delay, then checks
+// for the message once without blocking.
+func poll(c clock.Clock, delay time.Duration) string {
+	// Buffered so the goroutine can still send and exit
+	// after poll has given up on the message.
+	ch := make(chan string, 1)
 
 	go func() {
-		time.Sleep(1 * time.Second)
+		c.Sleep(delay)
 		ch <- "message"
 	}()
 
 	select {
 	case m := <-ch:
-		fmt.Println("received message", m)
+		return fmt.Sprint("received message ", m)
 	default:
-		fmt.Println("no message received")
+		return "no message received"
 	}
 }
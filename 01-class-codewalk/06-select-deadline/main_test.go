@@ -0,0 +1,23 @@
+package main
+
+import (
+	"testing"
+	"time"
+
+	"go-concurrency-exercises/pkg/clock"
+	"go-concurrency-exercises/pkg/leakcheck"
+)
+
+func TestPollDoesNotWait(t *testing.T) {
+	leakcheck.Check(t)
+
+	c := clock.NewFake(time.Now())
+
+	if got := poll(c, time.Second); got != "no message received" {
+		t.Errorf("got %q, want %q", got, "no message received")
+	}
+
+	// Let the sending goroutine finish.
+	c.BlockUntil(1)
+	c.Advance(time.Second)
+}
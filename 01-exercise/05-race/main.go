@@ -4,6 +4,8 @@ import (
 	"fmt"
 	"math/rand"
 	"time"
+
+	"go-concurrency-exercises/pkg/clock"
 )
 
 // identify the data race and fix the issue.
@@ -21,25 +23,34 @@ import (
 
 // no data race version
 func main() {
-	start := time.Now()
-	var t *time.Timer
+	run(clock.New(), 5*time.Second, randomDuration, func(d time.Duration) {
+		fmt.Println(d)
+	})
+}
+
+// run keeps resetting a timer to a new duration from next until total has
+// passed. Each time the timer fires it calls print with the time since
+// the start.
+func run(c clock.Clock, total time.Duration, next func() time.Duration, print func(time.Duration)) {
+	start := c.Now()
+	var t clock.Timer
 	ch := make(chan bool)
 
-	t = time.AfterFunc(randomDuration(), func() {
-		fmt.Println(time.Now().Sub(start))
+	t = c.AfterFunc(next(), func() {
+		print(c.Now().Sub(start))
 		// Don't reset t in the goroutine.
 		// Instead, send message on a channel.
 		ch <- true
 	})
 
-	// Keep resetting t for 5 seconds.
-	for time.Since(start) < 5*time.Second {
+	// Keep resetting t for total.
+	for c.Now().Sub(start) < total {
 		// Wait for channel to have a value and then get
 		// main routine to reset, rather than goroutine setting it.
 		// This avoids concurrent access of t between main goroutine
 		// and AfterFunc goroutine.
 		<-ch
-		t.Reset(randomDuration())
+		t.Reset(next())
 	}
 }
 
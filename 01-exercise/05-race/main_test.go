@@ -0,0 +1,36 @@
+package main
+
+import (
+	"testing"
+	"time"
+
+	"go-concurrency-exercises/pkg/clock"
+	"go-concurrency-exercises/pkg/leakcheck"
+)
+
+func TestRun(t *testing.T) {
+	leakcheck.Check(t)
+
+	c := clock.NewFake(time.Now())
+	printed := make(chan time.Duration, 10)
+	finished := make(chan struct{})
+
+	go func() {
+		defer close(finished)
+		run(c, 5*time.Second, func() time.Duration { return time.Second }, func(d time.Duration) {
+			printed <- d
+		})
+	}()
+
+	for i := 1; i <= 5; i++ {
+		// Wait for run to reset the timer before moving time on.
+		c.BlockUntil(1)
+		c.Advance(time.Second)
+
+		if d := <-printed; d != time.Duration(i)*time.Second {
+			t.Fatalf("timer %d fired after %v, want %v", i, d, time.Duration(i)*time.Second)
+		}
+	}
+
+	<-finished
+}
@@ -0,0 +1,72 @@
+// Command pagerank ranks the pages of a crawl by internal link importance,
+// from a graph written by the crawler's -graph json.
+package main
+
+import (
+	"flag"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"text/tabwriter"
+
+	"07-exercise-web-crawler/crawler"
+)
+
+// report writes the top ranked pages of g to w, or all of them if top is
+// 0, and whether the ranks converged.
+func report(g *crawler.Graph, w io.Writer, opts crawler.RankOptions, top int) error {
+	r := g.PageRank(opts)
+	ranks := r.Ranks
+	if top > 0 && top < len(ranks) {
+		ranks = ranks[:top]
+	}
+
+	tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(tw, "rank\tinlinks\turl")
+	for _, rank := range ranks {
+		fmt.Fprintf(tw, "%.6f\t%d\t%s\n", rank.Rank, rank.Inlinks, rank.URL)
+	}
+	if err := tw.Flush(); err != nil {
+		return err
+	}
+
+	if r.Converged {
+		_, err := fmt.Fprintf(w, "converged after %d iterations\n", r.Iterations)
+		return err
+	}
+	_, err := fmt.Fprintf(w, "not converged after %d iterations\n", r.Iterations)
+	return err
+}
+
+func main() {
+	var opts crawler.RankOptions
+	flag.Float64Var(&opts.Damping, "damping", 0.85, "the chance of following a link rather than jumping to a random page")
+	flag.Float64Var(&opts.Tolerance, "tolerance", 1e-6, "stop once the ranks change by less than this in total")
+	flag.IntVar(&opts.MaxIterations, "max-iterations", 100, "stop after this many iterations even if not converged")
+	flag.IntVar(&opts.Workers, "workers", 0, "goroutines to split each iteration between (default GOMAXPROCS)")
+	top := flag.Int("top", 20, "how many pages to list; 0 for all")
+	flag.Usage = func() {
+		fmt.Fprintf(flag.CommandLine.Output(), "usage: %s [flags] [graph.json]\n\nRanks the pages of a crawl graph, read from standard input if no file is given.\n\nFlags:\n", os.Args[0])
+		flag.PrintDefaults()
+	}
+	flag.Parse()
+
+	in := os.Stdin
+	if flag.NArg() > 0 && flag.Arg(0) != "-" {
+		f, err := os.Open(flag.Arg(0))
+		if err != nil {
+			log.Fatal(err)
+		}
+		defer f.Close()
+		in = f
+	}
+
+	g, err := crawler.ReadGraph(in)
+	if err != nil {
+		log.Fatal(err)
+	}
+	if err := report(g, os.Stdout, opts, *top); err != nil {
+		log.Fatal(err)
+	}
+}
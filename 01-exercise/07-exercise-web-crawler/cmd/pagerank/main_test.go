@@ -0,0 +1,34 @@
+package main
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+
+	"07-exercise-web-crawler/crawler"
+)
+
+func TestReport(t *testing.T) {
+	g, err := crawler.ReadGraph(strings.NewReader(`{"nodes": [
+		{"url": "https://a/", "links": ["https://a/b"]},
+		{"url": "https://a/b", "links": ["https://a/"]},
+		{"url": "https://a/c", "links": ["https://a/"]}
+	]}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var out bytes.Buffer
+	if err := report(g, &out, crawler.RankOptions{}, 2); err != nil {
+		t.Fatal(err)
+	}
+
+	lines := strings.Split(strings.TrimSpace(out.String()), "\n")
+	if len(lines) != 4 {
+		t.Fatalf("got\n%s\nwant a header, 2 pages and a summary", out.String())
+	}
+	if lines[0] != "rank      inlinks  url" || !strings.HasSuffix(lines[1], "2        https://a/") ||
+		!strings.HasSuffix(lines[2], "1        https://a/b") || !strings.HasPrefix(lines[3], "converged after ") {
+		t.Errorf("got\n%s", out.String())
+	}
+}
@@ -0,0 +1,64 @@
+// Command search searches a full-text index of crawled pages, built by
+// the crawler's -index.
+package main
+
+import (
+	"flag"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"strings"
+	"text/tabwriter"
+
+	"07-exercise-web-crawler/index"
+)
+
+// search writes the pages of ix that match query to w, best first, and at
+// most n of them if n is positive.
+func search(ix *index.Index, w io.Writer, query string, n int) error {
+	results, err := ix.Search(query, n)
+	if err != nil {
+		return err
+	}
+	if len(results) == 0 {
+		_, err := fmt.Fprintln(w, "no pages match")
+		return err
+	}
+
+	tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(tw, "score\turl\ttitle")
+	for _, r := range results {
+		fmt.Fprintf(tw, "%.4f\t%s\t%s\n", r.Score, r.URL, r.Title)
+	}
+	return tw.Flush()
+}
+
+func main() {
+	dir := flag.String("index", "index", "the directory the index was saved to")
+	n := flag.Int("n", 10, "how many pages to list; 0 for all")
+	flag.Usage = func() {
+		fmt.Fprintf(flag.CommandLine.Output(), `usage: %s [flags] query...
+
+Searches an index of crawled pages, best matches first by BM25. Terms must
+all match; OR between them matches either, "quoted phrases" match words in
+order, and parentheses group, as in: go (tour OR tutorial) "web crawler"
+
+Flags:
+`, os.Args[0])
+		flag.PrintDefaults()
+	}
+	flag.Parse()
+	if flag.NArg() == 0 {
+		flag.Usage()
+		os.Exit(2)
+	}
+
+	ix, err := index.Load(*dir)
+	if err != nil {
+		log.Fatal(err)
+	}
+	if err := search(ix, os.Stdout, strings.Join(flag.Args(), " "), *n); err != nil {
+		log.Fatal(err)
+	}
+}
@@ -0,0 +1,41 @@
+package main
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+
+	"07-exercise-web-crawler/index"
+)
+
+func TestSearch(t *testing.T) {
+	ix := index.New(2)
+	ix.Add(index.Document{URL: "https://a/", Title: "Home", Text: "Welcome to the docs."})
+	ix.Add(index.Document{URL: "https://a/install", Title: "Install", Text: "How to install the docs tool. Install it first."})
+
+	var out bytes.Buffer
+	if err := search(ix, &out, "install", 0); err != nil {
+		t.Fatal(err)
+	}
+	lines := strings.Split(strings.TrimSpace(out.String()), "\n")
+	if len(lines) != 2 || lines[0] != "score   url                title" || !strings.HasSuffix(lines[1], "https://a/install  Install") {
+		t.Errorf("got\n%s", out.String())
+	}
+
+	out.Reset()
+	if err := search(ix, &out, `"docs tool" OR missing`, 0); err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(out.String(), "https://a/install") || strings.Contains(out.String(), "https://a/ ") {
+		t.Errorf("got\n%s\nwant only the install page", out.String())
+	}
+
+	out.Reset()
+	if err := search(ix, &out, "missing", 0); err != nil || out.String() != "no pages match\n" {
+		t.Errorf("got %q, %v; want no pages", out.String(), err)
+	}
+
+	if err := search(ix, &out, "(docs", 0); err == nil {
+		t.Error("got no error for a bad query")
+	}
+}
@@ -0,0 +1,560 @@
+// Package crawler crawls web sites concurrently. A Crawler follows links
+// from its seeds to a maximum depth, keeping to its scope and to each
+// host's robots.txt, with limits on how many pages are fetched at once
+// and how often each host is asked. The pages it fetches are streamed on
+// a channel, so it can be embedded in a longer-running service.
+package crawler
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"sync"
+	"time"
+)
+
+const (
+	// DefaultDepth is how many links deep a Crawler goes by default.
+	DefaultDepth = 2
+
+	// DefaultConcurrency is the most pages fetched at once by default.
+	DefaultConcurrency = 16
+
+	// DefaultHostConcurrency is the most pages fetched at once from one
+	// host by default.
+	DefaultHostConcurrency = 2
+
+	// DefaultHostDelay is the least time between starting requests to one
+	// host by default.
+	DefaultHostDelay = 100 * time.Millisecond
+
+	// DefaultConnectTimeout, DefaultHeaderTimeout and DefaultBodyTimeout
+	// limit the default fetcher's requests.
+	DefaultConnectTimeout = 10 * time.Second
+	DefaultHeaderTimeout  = 10 * time.Second
+	DefaultBodyTimeout    = 30 * time.Second
+)
+
+// ErrDisallowed is a Page's error when robots.txt disallows it.
+var ErrDisallowed = errors.New("disallowed by robots.txt")
+
+// Page is a page a Crawler fetched, or tried to.
+type Page struct {
+	URL string
+
+	// Depth is the number of links followed from a seed to the page.
+	Depth int
+
+	// Kind is what the page was first found as: an anchor, an image and
+	// so on.
+	Kind Kind
+
+	// Checked is set for a page that was only checked, not crawled; see
+	// CheckLinks.
+	Checked bool
+
+	// Status and Redirects are from the page's Response, if the Fetcher
+	// is a Checker and a response was received.
+	Status    int
+	Redirects []string
+
+	// Latency is how long fetching or checking the page took.
+	Latency time.Duration
+
+	// Refs are the normalised URLs the page refers to, each once for
+	// each kind, in the order they first appear. Links are those of the
+	// refs that are other pages, not assets, each once. Both include
+	// URLs that were not followed.
+	Refs  []Ref
+	Links []string
+
+	// Meta is what the page says about itself, if it was crawled and the
+	// Fetcher is a Checker that read it, as HTTPFetcher does for HTML.
+	Meta *Meta
+
+	// Text is the page's visible text, with white space collapsed, if the
+	// Crawler keeps it; see KeepText.
+	Text string
+
+	// Err is why the page couldn't be fetched, if it couldn't. It is the
+	// context's error for a page still being fetched when the context
+	// passed to Run was done, and a *TimeoutError for one that took too
+	// long.
+	Err error
+}
+
+// Option configures a Crawler.
+type Option func(*Crawler)
+
+// Depth sets how many links deep to follow from the seeds. The seeds are
+// at depth 0, so with Depth(0) only they are fetched.
+func Depth(n int) Option {
+	return func(c *Crawler) {
+		if n >= 0 {
+			c.depth = n
+		}
+	}
+}
+
+// Concurrency sets the most pages fetched at once.
+func Concurrency(n int) Option {
+	return func(c *Crawler) {
+		if n > 0 {
+			c.concurrency = n
+		}
+	}
+}
+
+// HostConcurrency sets the most pages fetched at once from one host.
+func HostConcurrency(n int) Option {
+	return func(c *Crawler) {
+		if n > 0 {
+			c.hostConcurrency = n
+		}
+	}
+}
+
+// HostDelay sets the least time between starting requests to one host. A
+// longer Crawl-delay in the host's robots.txt takes its place.
+func HostDelay(d time.Duration) Option {
+	return func(c *Crawler) {
+		if d >= 0 {
+			c.hostDelay = d
+		}
+	}
+}
+
+// Timeouts sets the default fetcher's limits on how long connecting to a
+// host, waiting for a response's headers, and reading its body may take.
+// Zero means no limit.
+func Timeouts(connect, header, body time.Duration) Option {
+	return func(c *Crawler) {
+		if connect >= 0 && header >= 0 && body >= 0 {
+			c.connectTimeout, c.headerTimeout, c.bodyTimeout = connect, header, body
+		}
+	}
+}
+
+// WithScope sets which links are followed. By default, links are followed
+// anywhere.
+func WithScope(s Scope) Option {
+	return func(c *Crawler) {
+		c.scope = s
+	}
+}
+
+// WithFetcher sets what fetches pages. By default, an HTTPFetcher does,
+// with the Crawler's user agent.
+func WithFetcher(f Fetcher) Option {
+	return func(c *Crawler) {
+		c.fetcher = f
+	}
+}
+
+// UserAgent sets the User-Agent sent by the default fetcher, and by which
+// robots.txt rules are picked.
+func UserAgent(ua string) Option {
+	return func(c *Crawler) {
+		c.userAgent = ua
+	}
+}
+
+// IgnoreRobots makes the Crawler fetch pages that robots.txt disallows.
+// robots.txt is only ever read by an HTTPFetcher's client.
+func IgnoreRobots() Option {
+	return func(c *Crawler) {
+		c.ignoreRobots = true
+	}
+}
+
+// SortQuery makes URLs whose query parameters differ only in order count
+// as the same page. It is off by default because some sites give the
+// order meaning.
+func SortQuery() Option {
+	return func(c *Crawler) {
+		c.sortQuery = true
+	}
+}
+
+// CheckLinks makes the Crawler check every link on the pages it crawls,
+// as a broken link checker does. Links it wouldn't follow, because they
+// are out of scope or too deep, are checked with the Fetcher's Head
+// method if it is a Checker, and are never crawled. robots.txt doesn't
+// apply to them, as each is one request for a link a page already has.
+func CheckLinks() Option {
+	return func(c *Crawler) {
+		c.checkLinks = true
+	}
+}
+
+// Assets makes the Crawler fetch the assets pages refer to, such as
+// images, scripts and stylesheets, so they are inventoried and checked.
+// They are only checked, with the Fetcher's Head method if it is a
+// Checker, unless follow is set, when they are crawled as pages are,
+// within the scope and depth. Without Assets, they are only listed in
+// each Page's Refs.
+func Assets(follow bool) Option {
+	return func(c *Crawler) {
+		c.assets, c.followAssets = true, follow
+	}
+}
+
+// KeepText makes the Crawler send the visible text of each page it
+// crawls with the page, for indexing, if the Fetcher is a Checker that
+// reads it.
+func KeepText() Option {
+	return func(c *Crawler) {
+		c.keepText = true
+	}
+}
+
+// DebugLog logs why URLs are skipped to l.
+func DebugLog(l *log.Logger) Option {
+	return func(c *Crawler) {
+		c.debug = l
+	}
+}
+
+// Crawler crawls from seed URLs. It is safe to Run more than once, and
+// from more than one goroutine at a time; each Run has its own record of
+// the pages it has visited.
+type Crawler struct {
+	depth           int
+	concurrency     int
+	hostConcurrency int
+	hostDelay       time.Duration
+	connectTimeout  time.Duration
+	headerTimeout   time.Duration
+	bodyTimeout     time.Duration
+	scope           Scope
+	fetcher         Fetcher
+	userAgent       string
+	ignoreRobots    bool
+	sortQuery       bool
+	checkLinks      bool
+	assets          bool
+	followAssets    bool
+	keepText        bool
+	debug           *log.Logger
+}
+
+// New returns a Crawler configured by opts.
+func New(opts ...Option) *Crawler {
+	c := &Crawler{
+		depth:           DefaultDepth,
+		concurrency:     DefaultConcurrency,
+		hostConcurrency: DefaultHostConcurrency,
+		hostDelay:       DefaultHostDelay,
+		connectTimeout:  DefaultConnectTimeout,
+		headerTimeout:   DefaultHeaderTimeout,
+		bodyTimeout:     DefaultBodyTimeout,
+		scope:           Scope{Mode: ScopeAny},
+		userAgent:       DefaultUserAgent,
+	}
+	for _, opt := range opts {
+		opt(c)
+	}
+	if c.fetcher == nil {
+		c.fetcher = &HTTPFetcher{
+			UserAgent:      c.userAgent,
+			ConnectTimeout: c.connectTimeout,
+			HeaderTimeout:  c.headerTimeout,
+			BodyTimeout:    c.bodyTimeout,
+		}
+	}
+	return c
+}
+
+func (c *Crawler) debugf(format string, args ...interface{}) {
+	if c.debug != nil {
+		c.debug.Printf("debug: "+format, args...)
+	}
+}
+
+// visited is the set of URLs a crawl has queued, so each is fetched once,
+// with whether each was only to be checked.
+type visited struct {
+	mu   sync.Mutex
+	urls map[string]bool
+}
+
+func newVisited() *visited {
+	return &visited{urls: make(map[string]bool)}
+}
+
+// add adds url to the set, to be checked or crawled, and reports whether
+// it should be queued: it is new, or it was only to be checked and is now
+// to be crawled, so the crawl doesn't depend on the order links are found.
+func (v *visited) add(url string, check bool) bool {
+	v.mu.Lock()
+	defer v.mu.Unlock()
+
+	if wasCheck, ok := v.urls[url]; ok && (check || !wasCheck) {
+		return false
+	}
+	v.urls[url] = check
+	return true
+}
+
+// crawled reports whether url is to be crawled, not only checked.
+func (v *visited) crawled(url string) bool {
+	v.mu.Lock()
+	defer v.mu.Unlock()
+	check, ok := v.urls[url]
+	return ok && !check
+}
+
+// result is a fetch's outcome, passed from its goroutine to the crawl
+// loop.
+type result struct {
+	job
+	resp    *Response
+	err     error
+	latency time.Duration
+
+	// robots is set if the url's host's robots.txt was read, and delay
+	// is then its Crawl-delay.
+	robots bool
+	delay  time.Duration
+}
+
+// Run crawls from seeds and sends each page it fetches, or fails to, on
+// the channel it returns. The channel is closed when there is nothing
+// left to fetch, or once ctx is done and the fetches in flight have
+// returned; their pages are sent with ctx's error, so the caller can
+// tell what was cut short. A URL that was checked, and then found to be
+// crawled too, may be sent twice, the crawled page last. The caller must
+// read the channel until it is closed.
+func (c *Crawler) Run(ctx context.Context, seeds ...string) <-chan Page {
+	pages := make(chan Page)
+	go func() {
+		defer close(pages)
+		c.run(ctx, seeds, pages)
+
+		// A finished crawl leaves no connections open.
+		if f, ok := c.fetcher.(interface{ CloseIdleConnections() }); ok {
+			f.CloseIdleConnections()
+		}
+	}()
+	return pages
+}
+
+func (c *Crawler) run(ctx context.Context, seeds []string, pages chan<- Page) {
+	seen := newVisited()
+
+	// Dedup on the normalised form of the seeds, as for the links found.
+	var starts []string
+	for _, seed := range seeds {
+		u, ok := normalizeString(seed, c.sortQuery)
+		if !ok {
+			pages <- Page{URL: seed, Err: fmt.Errorf("can't crawl %q", seed)}
+			continue
+		}
+		if seen.add(u, false) {
+			starts = append(starts, u)
+		}
+	}
+
+	scope, err := c.scope.bind(starts)
+	if err != nil {
+		for _, u := range starts {
+			pages <- Page{URL: u, Err: err}
+		}
+		return
+	}
+
+	// robots.txt is only read over HTTP; other fetchers have none.
+	var robots *robotsCache
+	if f, ok := c.fetcher.(*HTTPFetcher); ok && !c.ignoreRobots {
+		robots = newRobotsCache(f.client(), c.userAgent, c.debugf)
+	}
+
+	// The scheduler picks which url to fetch next, so no more than
+	// c.concurrency are fetched at once and each host is given time
+	// between requests.
+	sched := newScheduler(c.concurrency, c.hostConcurrency, c.hostDelay, robots == nil)
+	for _, u := range starts {
+		sched.add(job{url: u, kind: KindAnchor})
+	}
+
+	results := make(chan *result)
+	fetch := func(j job) {
+		res := &result{job: j}
+		// A check is one request for a link a page already has, so
+		// robots.txt, which may itself be unreachable, doesn't apply.
+		if robots != nil && !j.check {
+			res.robots = true
+			var allowed bool
+			if allowed, res.delay = robots.check(ctx, j.url); !allowed {
+				c.debugf("skip %s: %v", j.url, ErrDisallowed)
+				res.err = ErrDisallowed
+				results <- res
+				return
+			}
+		}
+		start := time.Now()
+		res.resp, res.err = c.get(ctx, j)
+		res.latency = time.Since(start)
+		results <- res
+	}
+
+	// skipped holds the out of scope urls, so each is only logged once.
+	skipped := make(map[string]bool)
+	inFlight := 0
+
+	for {
+		// Start every fetch that may start now.
+		var wait time.Duration
+		for ctx.Err() == nil {
+			j, ok, w := sched.next(time.Now())
+			if !ok {
+				wait = w
+				break
+			}
+			inFlight++
+			go fetch(j)
+		}
+
+		// Once ctx is done, nothing more is started; the loop waits for
+		// the fetches in flight, so none is left sending.
+		if inFlight == 0 && (ctx.Err() != nil || sched.pending() == 0) {
+			return
+		}
+
+		// Wait for a fetch to finish, or for a host to be free again.
+		var timer *time.Timer
+		var free <-chan time.Time
+		if wait > 0 {
+			timer = time.NewTimer(wait)
+			free = timer.C
+		}
+		var done <-chan struct{}
+		if ctx.Err() == nil {
+			done = ctx.Done()
+		}
+
+		select {
+		case res := <-results:
+			inFlight--
+			sched.done(res.url, res.delay, res.robots)
+			if res.check && seen.crawled(res.url) {
+				// The url was found to crawl after it was queued to
+				// check; the crawl's page stands for it.
+				break
+			}
+
+			p := Page{URL: res.url, Depth: res.depth, Kind: res.kind, Checked: res.check, Latency: res.latency, Err: res.err}
+			if res.resp != nil {
+				p.Status, p.Redirects = res.resp.Status, res.resp.Redirects
+				if !res.check {
+					p.Refs, p.Links = c.refs(res.resp.Refs)
+					p.Meta = c.meta(res.resp.Meta)
+					if c.keepText {
+						p.Text = res.resp.Text
+					}
+				}
+			}
+			if err := ctx.Err(); err != nil {
+				// The fetch was cut short, or finished as ctx ended;
+				// either way nothing more is followed. Only a fetch
+				// that failed because ctx ended gets ctx's error, so
+				// real failures are still reported as they were.
+				if errors.Is(p.Err, context.Canceled) || errors.Is(p.Err, context.DeadlineExceeded) {
+					p.Err = err
+				}
+				pages <- p
+				break
+			}
+			if res.err == nil {
+				for _, ref := range p.Refs {
+					u, asset := ref.URL, ref.Kind.Asset()
+					if asset && !c.assets {
+						continue
+					}
+					follow := res.depth < c.depth && !skipped[u] && (!asset || c.followAssets)
+					if follow {
+						if ok, why := scope.check(u); !ok {
+							c.debugf("skip %s: out of scope: %s", u, why)
+							skipped[u] = true
+							follow = false
+						}
+					}
+					// Assets are always checked; links to pages only
+					// when checking links.
+					if !follow && !asset && !c.checkLinks {
+						continue
+					}
+					if seen.add(u, !follow) {
+						sched.add(job{url: u, depth: res.depth + 1, check: !follow, kind: ref.Kind})
+					}
+				}
+			}
+			pages <- p
+		case <-free:
+		case <-done:
+		}
+		if timer != nil {
+			timer.Stop()
+		}
+	}
+}
+
+// get fetches j's url, or only checks it if that is all j is for.
+func (c *Crawler) get(ctx context.Context, j job) (*Response, error) {
+	if ch, ok := c.fetcher.(Checker); ok {
+		if j.check {
+			return ch.Head(ctx, j.url)
+		}
+		return ch.Get(ctx, j.url)
+	}
+	urls, err := c.fetcher.Fetch(ctx, j.url)
+	if err != nil {
+		return nil, err
+	}
+	resp := &Response{}
+	for _, u := range urls {
+		resp.Refs = append(resp.Refs, Ref{u, KindAnchor})
+	}
+	return resp, nil
+}
+
+// meta returns a copy of m with its canonical URL normalised as the
+// crawl's URLs are.
+func (c *Crawler) meta(m *Meta) *Meta {
+	if m == nil {
+		return nil
+	}
+	cp := *m
+	if u, ok := normalizeString(cp.Canonical, c.sortQuery); ok {
+		cp.Canonical = u
+	}
+	return &cp
+}
+
+// refs normalises the refs a Fetcher returned, dropping those that can't
+// be crawled and repeats, and returns them with the URLs of the pages
+// among them.
+func (c *Crawler) refs(found []Ref) ([]Ref, []string) {
+	var refs []Ref
+	var links []string
+	seen := make(map[Ref]bool)
+	seenLink := make(map[string]bool)
+	for _, ref := range found {
+		u, ok := normalizeString(ref.URL, c.sortQuery)
+		if !ok {
+			continue
+		}
+		ref.URL = u
+		if seen[ref] {
+			continue
+		}
+		seen[ref] = true
+		refs = append(refs, ref)
+		if !ref.Kind.Asset() && !seenLink[u] {
+			seenLink[u] = true
+			links = append(links, u)
+		}
+	}
+	return refs, links
+}
@@ -0,0 +1,504 @@
+package crawler
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"regexp"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"go-concurrency-exercises/pkg/leakcheck"
+)
+
+// collect runs c from seeds to the end, and returns the pages by url.
+func collect(c *Crawler, seeds ...string) map[string]Page {
+	pages := make(map[string]Page)
+	for p := range c.Run(context.Background(), seeds...) {
+		pages[p.URL] = p
+	}
+	return pages
+}
+
+// fetched returns the paths of the pages that were fetched without an
+// error, relative to base.
+func fetched(pages map[string]Page, base string) map[string]bool {
+	paths := make(map[string]bool)
+	for u, p := range pages {
+		if p.Err == nil {
+			paths[strings.TrimPrefix(u, base)] = true
+		}
+	}
+	return paths
+}
+
+// newSite starts a test server where every page links to the next one
+// and the last page links back to the first.
+func newSite(t *testing.T, pages int) *httptest.Server {
+	t.Helper()
+
+	var srv *httptest.Server
+	srv = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var n int
+		if _, err := fmt.Sscanf(r.URL.Path, "/%d", &n); err != nil || n >= pages {
+			http.NotFound(w, r)
+			return
+		}
+		fmt.Fprintf(w, `<html><body><a href="%s/%d">next</a><a href="%s/missing">missing</a></body></html>`,
+			srv.URL, (n+1)%pages, srv.URL)
+	}))
+	t.Cleanup(srv.Close)
+	t.Cleanup(http.DefaultClient.CloseIdleConnections)
+	return srv
+}
+
+func TestRun(t *testing.T) {
+	leakcheck.Check(t)
+
+	srv := newSite(t, 3)
+	pages := collect(New(HostDelay(0), Depth(5)), srv.URL+"/0")
+
+	if len(pages) != 4 {
+		t.Errorf("got %d pages, want 4", len(pages))
+	}
+	for path, depth := range map[string]int{"/0": 0, "/1": 1, "/2": 2} {
+		p, ok := pages[srv.URL+path]
+		if !ok {
+			t.Errorf("%s was not fetched", path)
+			continue
+		}
+		if p.Err != nil || p.Depth != depth {
+			t.Errorf("%s: got depth %d, error %v; want depth %d, no error", path, p.Depth, p.Err, depth)
+		}
+	}
+
+	p := pages[srv.URL+"/1"]
+	if want := []string{srv.URL + "/2", srv.URL + "/missing"}; !reflect.DeepEqual(p.Links, want) {
+		t.Errorf("/1 links to %q, want %q", p.Links, want)
+	}
+	if p := pages[srv.URL+"/missing"]; p.Err == nil || !strings.Contains(p.Err.Error(), "404") {
+		t.Errorf("/missing: got error %v, want a 404", p.Err)
+	}
+}
+
+func TestRunSeeds(t *testing.T) {
+	leakcheck.Check(t)
+
+	a, b := newSite(t, 2), newSite(t, 1)
+	c := New(HostDelay(0), Depth(1), WithScope(Scope{Mode: ScopeHost}))
+
+	// Repeated seeds, however written, are crawled once. Each seed's host
+	// is in scope.
+	pages := collect(c, a.URL+"/0", b.URL+"/0", a.URL+"/0#top", "mailto:x@example.com")
+
+	var got []string
+	for u, p := range pages {
+		if p.Err == nil {
+			got = append(got, u)
+		}
+	}
+	if len(got) != 3 {
+		t.Errorf("fetched %q, want %s/0, %s/1 and %s/0", got, a.URL, a.URL, b.URL)
+	}
+	if p, ok := pages["mailto:x@example.com"]; !ok || p.Err == nil {
+		t.Errorf("got %+v for a seed that can't be crawled, want an error", p)
+	}
+}
+
+func TestRunConcurrently(t *testing.T) {
+	leakcheck.Check(t)
+
+	// Runs on one Crawler don't share their visited pages.
+	c := New(WithFetcher(goTour), HostDelay(0), Depth(10))
+	var wg sync.WaitGroup
+	counts := make([]int, 8)
+	for i := range counts {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			counts[i] = len(collect(c, "https://golang.org/"))
+		}(i)
+	}
+	wg.Wait()
+
+	for i, n := range counts {
+		if n != 5 {
+			t.Errorf("run %d got %d pages, want 5", i, n)
+		}
+	}
+}
+
+// blockingFetcher blocks every fetch until ctx is done. A fetch of a url
+// in errs then fails with its error, as one that failed for its own reason
+// as ctx ended would.
+type blockingFetcher struct {
+	started chan string
+	errs    map[string]error
+}
+
+func (f *blockingFetcher) Fetch(ctx context.Context, url string) ([]string, error) {
+	f.started <- url
+	<-ctx.Done()
+	if err, ok := f.errs[url]; ok {
+		return nil, err
+	}
+	return nil, ctx.Err()
+}
+
+func TestRunCancel(t *testing.T) {
+	leakcheck.Check(t)
+
+	f := &blockingFetcher{started: make(chan string)}
+	c := New(WithFetcher(f), HostDelay(0))
+
+	ctx, cancel := context.WithCancel(context.Background())
+	pages := c.Run(ctx, "https://example.com/a", "https://example.org/b")
+	<-f.started
+	<-f.started
+	cancel()
+
+	// The channel is closed once the fetches in flight have returned, and
+	// they are reported as cancelled.
+	done := make(chan map[string]Page)
+	go func() {
+		got := make(map[string]Page)
+		for p := range pages {
+			got[p.URL] = p
+		}
+		done <- got
+	}()
+	select {
+	case got := <-done:
+		for _, u := range []string{"https://example.com/a", "https://example.org/b"} {
+			if p, ok := got[u]; !ok || !errors.Is(p.Err, context.Canceled) {
+				t.Errorf("%s: got %+v, want context.Canceled", u, p)
+			}
+		}
+		if len(got) != 2 {
+			t.Errorf("got %d pages, want the 2 in flight", len(got))
+		}
+	case <-time.After(10 * time.Second):
+		t.Fatal("Run didn't close its channel after ctx was cancelled")
+	}
+}
+
+func TestRunCancelKeepsErrors(t *testing.T) {
+	leakcheck.Check(t)
+
+	errNotFound := errors.New("404 Not Found")
+	f := &blockingFetcher{started: make(chan string), errs: map[string]error{"https://example.org/b": errNotFound}}
+	c := New(WithFetcher(f), HostDelay(0))
+
+	ctx, cancel := context.WithCancel(context.Background())
+	pages := c.Run(ctx, "https://example.com/a", "https://example.org/b")
+	<-f.started
+	<-f.started
+	cancel()
+
+	got := make(map[string]Page)
+	for p := range pages {
+		got[p.URL] = p
+	}
+	if p := got["https://example.com/a"]; !errors.Is(p.Err, context.Canceled) {
+		t.Errorf("/a: got error %v, want %v", p.Err, context.Canceled)
+	}
+	if p := got["https://example.org/b"]; p.Err != errNotFound {
+		t.Errorf("/b: got error %v, want %v", p.Err, errNotFound)
+	}
+}
+
+func TestRunDeadline(t *testing.T) {
+	leakcheck.Check(t)
+
+	// /slow never answers; without a deadline the crawl would wait on it
+	// for good.
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/":
+			fmt.Fprint(w, `<a href="/slow">slow</a><a href="/fast">fast</a>`)
+		case "/slow":
+			<-r.Context().Done()
+		}
+	}))
+	t.Cleanup(srv.Close)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 200*time.Millisecond)
+	defer cancel()
+	got := make(map[string]Page)
+	for p := range New(HostDelay(0), Timeouts(0, 0, 0)).Run(ctx, srv.URL) {
+		got[p.URL] = p
+	}
+
+	if want := map[string]bool{"/": true, "/fast": true}; !reflect.DeepEqual(fetched(got, srv.URL), want) {
+		t.Errorf("fetched %v, want %v", fetched(got, srv.URL), want)
+	}
+	if p := got[srv.URL+"/slow"]; !errors.Is(p.Err, context.DeadlineExceeded) {
+		t.Errorf("/slow: got error %v, want %v", p.Err, context.DeadlineExceeded)
+	}
+}
+
+func TestRunRelativeLinks(t *testing.T) {
+	leakcheck.Check(t)
+
+	pages := map[string]string{
+		"/":   `<a href="a">a</a><a href="a#part">a again</a><a href="/b/">b</a><a href="mailto:x@example.com">mail</a>`,
+		"/a":  `<head><base href="/b/"></head><body><a href="./">b</a><a href="c">c</a></body>`,
+		"/b/": `<a href="../">home</a><a href="../a?">a</a>`,
+	}
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		page, ok := pages[r.URL.Path]
+		if !ok {
+			http.NotFound(w, r)
+			return
+		}
+		fmt.Fprintf(w, "<html>%s</html>", page)
+	}))
+	t.Cleanup(srv.Close)
+	t.Cleanup(http.DefaultClient.CloseIdleConnections)
+
+	got := collect(New(HostDelay(0), Depth(5)), srv.URL)
+
+	want := []string{"/", "/a", "/b/", "/b/c"}
+	for _, p := range want {
+		if _, ok := got[srv.URL+p]; !ok {
+			t.Errorf("%s was not fetched", p)
+		}
+	}
+	if len(got) != len(want) {
+		t.Errorf("fetched %v, want %d urls", fetched(got, srv.URL), len(want))
+	}
+}
+
+func TestRunScopeAndRobots(t *testing.T) {
+	leakcheck.Check(t)
+
+	other := newSite(t, 1)
+	pages := map[string]string{
+		"/":         `<a href="/secret/x">secret</a><a href="/docs/a.pdf">pdf</a><a href="/docs/">docs</a><a href="` + other.URL + `/0">elsewhere</a>`,
+		"/docs/":    `<a href="/">home</a>`,
+		"/secret/x": `<a href="/">home</a>`,
+	}
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/robots.txt" {
+			fmt.Fprint(w, "User-agent: *\nDisallow: /secret/\n")
+			return
+		}
+		page, ok := pages[r.URL.Path]
+		if !ok {
+			http.NotFound(w, r)
+			return
+		}
+		fmt.Fprintf(w, "<html>%s</html>", page)
+	}))
+	t.Cleanup(srv.Close)
+
+	var logs bytes.Buffer
+	c := New(
+		HostDelay(0),
+		Depth(5),
+		WithScope(Scope{Mode: ScopeHost, Deny: Regexps{regexp.MustCompile(`\.pdf$`)}}),
+		DebugLog(log.New(&logs, "", 0)),
+	)
+	got := collect(c, srv.URL)
+
+	// A url disallowed by robots.txt is still a page, with an error, but
+	// it is never fetched.
+	if want := map[string]bool{"/": true, "/docs/": true}; !reflect.DeepEqual(fetched(got, srv.URL), want) {
+		t.Errorf("fetched %v, want %v", fetched(got, srv.URL), want)
+	}
+	if p := got[srv.URL+"/secret/x"]; !errors.Is(p.Err, ErrDisallowed) {
+		t.Errorf("/secret/x: got error %v, want %v", p.Err, ErrDisallowed)
+	}
+	if len(got) != 3 {
+		t.Errorf("got %d pages, want 3", len(got))
+	}
+
+	for _, msg := range []string{
+		"skip " + srv.URL + "/secret/x: disallowed by robots.txt",
+		"skip " + srv.URL + "/docs/a.pdf: out of scope: matches deny pattern",
+		"skip " + other.URL + "/0: out of scope: host",
+	} {
+		if !strings.Contains(logs.String(), msg) {
+			t.Errorf("debug log has no %q:\n%s", msg, logs.String())
+		}
+	}
+
+	// With robots.txt ignored, the disallowed page is fetched.
+	c = New(HostDelay(0), Depth(5), WithScope(Scope{Mode: ScopeHost}), IgnoreRobots())
+	if p := collect(c, srv.URL)[srv.URL+"/secret/x"]; p.Err != nil {
+		t.Errorf("/secret/x: got error %v ignoring robots.txt", p.Err)
+	}
+}
+
+// load counts the requests test servers are serving at once.
+type load struct {
+	mu       sync.Mutex
+	inFlight int
+	most     int
+	starts   []time.Time
+}
+
+// newBusySite starts a test server with a hub page linking to pages that
+// take a while to serve, recording its requests in l. Servers can share l
+// to count requests across hosts.
+func newBusySite(t *testing.T, pages int, robots string, l *load) *httptest.Server {
+	t.Helper()
+
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/robots.txt" {
+			fmt.Fprint(w, robots)
+			return
+		}
+
+		l.mu.Lock()
+		l.inFlight++
+		if l.inFlight > l.most {
+			l.most = l.inFlight
+		}
+		l.starts = append(l.starts, time.Now())
+		l.mu.Unlock()
+
+		time.Sleep(20 * time.Millisecond)
+		if r.URL.Path == "/" {
+			for i := 0; i < pages; i++ {
+				fmt.Fprintf(w, `<a href="/%d">%d</a>`, i, i)
+			}
+		}
+
+		l.mu.Lock()
+		l.inFlight--
+		l.mu.Unlock()
+	}))
+	t.Cleanup(srv.Close)
+	t.Cleanup(http.DefaultClient.CloseIdleConnections)
+	return srv
+}
+
+func TestRunHostLimit(t *testing.T) {
+	leakcheck.Check(t)
+
+	var l load
+	site := newBusySite(t, 20, "", &l)
+
+	pages := collect(New(HostConcurrency(3), HostDelay(0), Depth(1)), site.URL)
+
+	if len(pages) != 21 {
+		t.Errorf("got %d pages, want 21", len(pages))
+	}
+	if l.most != 3 {
+		t.Errorf("served %d requests at once, want 3", l.most)
+	}
+}
+
+func TestRunCrawlDelay(t *testing.T) {
+	leakcheck.Check(t)
+
+	var l load
+	site := newBusySite(t, 5, "User-agent: *\nCrawl-delay: 0.05\n", &l)
+
+	collect(New(HostConcurrency(4), HostDelay(10*time.Millisecond), Depth(1)), site.URL)
+
+	if len(l.starts) != 6 {
+		t.Fatalf("got %d requests, want 6", len(l.starts))
+	}
+	for i := 1; i < len(l.starts); i++ {
+		// Allow for the server seeing requests a little later than they
+		// were sent.
+		if gap := l.starts[i].Sub(l.starts[i-1]); gap < 40*time.Millisecond {
+			t.Errorf("request %d came %v after the one before, want at least the 50ms Crawl-delay", i+1, gap)
+		}
+	}
+}
+
+func TestRunGlobalLimit(t *testing.T) {
+	leakcheck.Check(t)
+
+	// Both sites count requests in flight together.
+	var l load
+	b := newBusySite(t, 5, "", &l)
+	a := newBusySite(t, 0, "", &l)
+	links := ""
+	for i := 0; i < 5; i++ {
+		links += fmt.Sprintf(`<a href="%s/%d">b%d</a>`, b.URL, i, i)
+	}
+	hub := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprintf(w, `%s<a href="%s/">a</a><a href="%s/1">a1</a>`, links, a.URL, a.URL)
+	}))
+	t.Cleanup(hub.Close)
+
+	pages := collect(New(Concurrency(2), HostDelay(0), Depth(1)), hub.URL)
+
+	if len(pages) != 8 {
+		t.Errorf("got %d pages, want 8", len(pages))
+	}
+	if l.most > 2 {
+		t.Errorf("served %d requests at once, want at most 2", l.most)
+	}
+}
+
+// orderedFetcher serves a FakeFetcher, but holds back its hold page until
+// its wait url has been checked.
+type orderedFetcher struct {
+	FakeFetcher
+	hold, wait string
+
+	once    sync.Once
+	checked chan struct{}
+}
+
+func (f *orderedFetcher) Get(ctx context.Context, url string) (*Response, error) {
+	if url == f.hold {
+		select {
+		case <-f.checked:
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		}
+	}
+	return f.FakeFetcher.Get(ctx, url)
+}
+
+func (f *orderedFetcher) Head(ctx context.Context, url string) (*Response, error) {
+	resp, err := f.FakeFetcher.Head(ctx, url)
+	if url == f.wait {
+		f.once.Do(func() { close(f.checked) })
+	}
+	return resp, err
+}
+
+func TestRunCheckedThenCrawled(t *testing.T) {
+	leakcheck.Check(t)
+
+	// /deep/er finds /page past the depth, so it is only checked; /near,
+	// held back until then, finds it again within the depth, and it must
+	// still be crawled.
+	f := &orderedFetcher{
+		FakeFetcher: FakeFetcher{
+			"https://a/":        &FakeResult{URLs: []string{"https://a/deep", "https://a/near"}},
+			"https://a/deep":    &FakeResult{URLs: []string{"https://a/deep/er"}},
+			"https://a/deep/er": &FakeResult{URLs: []string{"https://a/page"}},
+			"https://a/near":    &FakeResult{URLs: []string{"https://a/page"}},
+			"https://a/page":    &FakeResult{URLs: []string{"https://a/next"}},
+			"https://a/next":    &FakeResult{},
+		},
+		hold:    "https://a/near",
+		wait:    "https://a/page",
+		checked: make(chan struct{}),
+	}
+	c := New(WithFetcher(f), CheckLinks(), HostDelay(0), HostConcurrency(4), Depth(2))
+
+	// The last page sent for a url is the one kept.
+	pages := collect(c, "https://a/")
+	if p := pages["https://a/page"]; p.Checked || p.Err != nil || !reflect.DeepEqual(p.Links, []string{"https://a/next"}) {
+		t.Errorf("/page: got %+v, want it crawled", p)
+	}
+	if p, ok := pages["https://a/next"]; !ok || !p.Checked {
+		t.Errorf("/next: got %+v, want it checked", p)
+	}
+}
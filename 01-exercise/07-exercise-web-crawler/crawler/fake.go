@@ -0,0 +1,57 @@
+package crawler
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"unicode/utf8"
+)
+
+// FakeFetcher is a Fetcher that returns canned results from an in-memory
+// site graph, as in the Go tour's web crawler exercise. Use it to run a
+// Crawler without a network.
+type FakeFetcher map[string]*FakeResult
+
+// FakeResult is a page of a FakeFetcher's site.
+type FakeResult struct {
+	Body string
+	URLs []string
+}
+
+// Fetch implements Fetcher.
+func (f FakeFetcher) Fetch(ctx context.Context, url string) ([]string, error) {
+	resp, err := f.Get(ctx, url)
+	if err != nil {
+		return nil, err
+	}
+	return resp.links(), nil
+}
+
+// Get implements Checker. A page's URLs are anchors, its Body is its
+// title and text, and pages not in f are 404s.
+func (f FakeFetcher) Get(ctx context.Context, url string) (*Response, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	if res, ok := f[url]; ok {
+		resp := &Response{
+			Status: http.StatusOK,
+			Meta:   &Meta{Title: res.Body, TextLength: utf8.RuneCountInString(res.Body)},
+			Text:   res.Body,
+		}
+		for _, u := range res.URLs {
+			resp.Refs = append(resp.Refs, Ref{u, KindAnchor})
+		}
+		return resp, nil
+	}
+	return &Response{Status: http.StatusNotFound}, fmt.Errorf("not found: %s", url)
+}
+
+// Head implements Checker.
+func (f FakeFetcher) Head(ctx context.Context, url string) (*Response, error) {
+	resp, err := f.Get(ctx, url)
+	if resp != nil {
+		resp.Refs, resp.Meta, resp.Text = nil, nil, ""
+	}
+	return resp, err
+}
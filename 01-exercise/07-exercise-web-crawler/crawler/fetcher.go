@@ -0,0 +1,276 @@
+package crawler
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"mime"
+	"net"
+	"net/http"
+	"sync"
+	"time"
+
+	"golang.org/x/net/html"
+)
+
+// DefaultUserAgent is the User-Agent sent when none is set.
+const DefaultUserAgent = "GoConcurrencyCrawler/1.0"
+
+// TimeoutError is the error for a fetch that took too long.
+type TimeoutError struct {
+	URL string
+
+	// Phase is what timed out: "connect", "header" or "body".
+	Phase string
+
+	After time.Duration
+}
+
+func (e *TimeoutError) Error() string {
+	if e.After == 0 {
+		return fmt.Sprintf("getting %s: %s timed out", e.URL, e.Phase)
+	}
+	return fmt.Sprintf("getting %s: %s timed out after %v", e.URL, e.Phase, e.After)
+}
+
+// Timeout reports that the error is a timeout, as net.Error does.
+func (e *TimeoutError) Timeout() bool {
+	return true
+}
+
+// Fetcher fetches pages for a Crawler.
+type Fetcher interface {
+	// Fetch returns the absolute URLs of the pages the page at url links
+	// to. It should give up when ctx is done.
+	Fetch(ctx context.Context, url string) (urls []string, err error)
+}
+
+// Checker is a Fetcher that can say more about the responses it gets, and
+// check that a URL works without fetching its page. A Crawler uses a
+// Checker's methods in place of Fetch when it has them.
+type Checker interface {
+	Fetcher
+
+	// Get fetches url as Fetch does, and returns the response. The
+	// response is returned with the error if one was received, such as
+	// for a 404.
+	Get(ctx context.Context, url string) (*Response, error)
+
+	// Head checks url without fetching its page. The response has no
+	// refs.
+	Head(ctx context.Context, url string) (*Response, error)
+}
+
+// Response is what a Checker found at a URL.
+type Response struct {
+	// Status is the HTTP status code of the last response.
+	Status int
+
+	// Redirects are the URLs redirected to, in order, ending with the
+	// one that gave Status.
+	Redirects []string
+
+	// Refs are the absolute URLs the page refers to, with what for.
+	Refs []Ref
+
+	// Meta is what the page says about itself, and Text its visible
+	// text, if it is HTML.
+	Meta *Meta
+	Text string
+}
+
+// links returns the URLs of the pages r refers to.
+func (r *Response) links() []string {
+	var links []string
+	for _, ref := range r.Refs {
+		if !ref.Kind.Asset() {
+			links = append(links, ref.URL)
+		}
+	}
+	return links
+}
+
+// HTTPFetcher fetches pages over HTTP. The URLs a page refers to, and its
+// canonical URL, are resolved against its base URL, which is where any
+// redirects ended up or its <base href>, and normalised. Responses that
+// aren't HTML refer to nothing and have no Meta, and their bodies aren't
+// read.
+type HTTPFetcher struct {
+	// Client makes the requests. If nil, a client with the connect and
+	// header timeouts is made, or http.DefaultClient is used if neither
+	// is set.
+	Client *http.Client
+
+	// UserAgent is sent with each request; if empty, DefaultUserAgent.
+	UserAgent string
+
+	// ConnectTimeout limits how long connecting to a host may take, and
+	// HeaderTimeout how long a response's headers may take after the
+	// request is sent. They only apply if Client is nil.
+	ConnectTimeout, HeaderTimeout time.Duration
+
+	// BodyTimeout limits how long reading a response's body may take.
+	BodyTimeout time.Duration
+
+	once sync.Once
+	own  *http.Client
+}
+
+func (f *HTTPFetcher) client() *http.Client {
+	if f.Client != nil {
+		return f.Client
+	}
+	if f.ConnectTimeout == 0 && f.HeaderTimeout == 0 {
+		return http.DefaultClient
+	}
+	f.once.Do(func() {
+		t := http.DefaultTransport.(*http.Transport).Clone()
+		t.DialContext = (&net.Dialer{Timeout: f.ConnectTimeout, KeepAlive: 30 * time.Second}).DialContext
+		t.ResponseHeaderTimeout = f.HeaderTimeout
+		f.own = &http.Client{Transport: t}
+	})
+	return f.own
+}
+
+// CloseIdleConnections closes the connections the fetcher's client keeps
+// open for reuse.
+func (f *HTTPFetcher) CloseIdleConnections() {
+	f.client().CloseIdleConnections()
+}
+
+func (f *HTTPFetcher) userAgent() string {
+	if f.UserAgent != "" {
+		return f.UserAgent
+	}
+	return DefaultUserAgent
+}
+
+// Fetch implements Fetcher.
+func (f *HTTPFetcher) Fetch(ctx context.Context, url string) ([]string, error) {
+	resp, err := f.Get(ctx, url)
+	if err != nil {
+		return nil, err
+	}
+	return resp.links(), nil
+}
+
+// Get implements Checker.
+func (f *HTTPFetcher) Get(ctx context.Context, url string) (*Response, error) {
+	// The request has its own context, so the body timeout can cancel it
+	// without ending anything else.
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	resp, err := f.do(ctx, http.MethodGet, url)
+	if err != nil {
+		return nil, err
+	}
+	r := newResponse(resp)
+	if resp.StatusCode != http.StatusOK {
+		resp.Body.Close()
+		return r, fmt.Errorf("getting %s: %s", url, resp.Status)
+	}
+	if !isHTML(resp.Header.Get("Content-Type")) {
+		resp.Body.Close()
+		return r, nil
+	}
+
+	var timer *time.Timer
+	if f.BodyTimeout > 0 {
+		timer = time.AfterFunc(f.BodyTimeout, cancel)
+	}
+	doc, err := html.Parse(resp.Body)
+	resp.Body.Close()
+	if err != nil {
+		if timer != nil && !timer.Stop() {
+			return r, &TimeoutError{URL: url, Phase: "body", After: f.BodyTimeout}
+		}
+		return r, fmt.Errorf("parsing %s as HTML: %w", url, err)
+	}
+	if timer != nil {
+		timer.Stop()
+	}
+	// Links are relative to where any redirects ended up.
+	base := baseURL(resp.Request.URL, doc)
+
+	for _, ref := range visit(nil, doc) {
+		if u, ok := resolve(base, ref.URL); ok {
+			r.Refs = append(r.Refs, Ref{u, ref.Kind})
+		}
+	}
+	r.Meta, r.Text = readMeta(doc)
+	if r.Meta.Canonical != "" {
+		r.Meta.Canonical, _ = resolve(base, r.Meta.Canonical)
+	}
+	return r, nil
+}
+
+// isHTML reports whether contentType is HTML's, or not given.
+func isHTML(contentType string) bool {
+	if contentType == "" {
+		return true
+	}
+	mt, _, err := mime.ParseMediaType(contentType)
+	return err == nil && (mt == "text/html" || mt == "application/xhtml+xml")
+}
+
+// Head implements Checker. If the HEAD request fails with an error
+// status, the URL is tried again with GET, as some servers don't answer
+// HEAD properly; the body isn't read.
+func (f *HTTPFetcher) Head(ctx context.Context, url string) (*Response, error) {
+	resp, err := f.do(ctx, http.MethodHead, url)
+	if err == nil && resp.StatusCode >= 400 {
+		resp.Body.Close()
+		resp, err = f.do(ctx, http.MethodGet, url)
+	}
+	if err != nil {
+		return nil, err
+	}
+	resp.Body.Close()
+
+	r := newResponse(resp)
+	if resp.StatusCode >= 400 {
+		return r, fmt.Errorf("checking %s: %s", url, resp.Status)
+	}
+	return r, nil
+}
+
+// do sends a request for url, following redirects.
+func (f *HTTPFetcher) do(ctx context.Context, method, url string) (*http.Response, error) {
+	req, err := http.NewRequestWithContext(ctx, method, url, nil)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("User-Agent", f.userAgent())
+	resp, err := f.client().Do(req)
+	if err != nil {
+		return nil, f.timeout(ctx, url, err)
+	}
+	return resp, nil
+}
+
+// newResponse returns the status of resp, and the redirects that led to
+// it.
+func newResponse(resp *http.Response) *Response {
+	r := &Response{Status: resp.StatusCode}
+	// Each request made to follow a redirect holds the response that
+	// caused it.
+	for req := resp.Request; req.Response != nil; req = req.Response.Request {
+		r.Redirects = append([]string{req.URL.String()}, r.Redirects...)
+	}
+	return r
+}
+
+// timeout returns err as a TimeoutError if the connect or header timeout
+// ran out, rather than ctx. A Client's own timeouts are left as they are.
+func (f *HTTPFetcher) timeout(ctx context.Context, url string, err error) error {
+	var ne net.Error
+	if f.Client != nil || ctx.Err() != nil || !errors.As(err, &ne) || !ne.Timeout() {
+		return err
+	}
+	var oe *net.OpError
+	if errors.As(err, &oe) && oe.Op == "dial" {
+		return &TimeoutError{URL: url, Phase: "connect", After: f.ConnectTimeout}
+	}
+	return &TimeoutError{URL: url, Phase: "header", After: f.HeaderTimeout}
+}
@@ -0,0 +1,247 @@
+package crawler
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"reflect"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"go-concurrency-exercises/pkg/leakcheck"
+)
+
+// newFixtureSite serves testdata/site.
+func newFixtureSite(t *testing.T) *httptest.Server {
+	t.Helper()
+
+	srv := httptest.NewServer(http.FileServer(http.Dir("testdata/site")))
+	t.Cleanup(srv.Close)
+	t.Cleanup(http.DefaultClient.CloseIdleConnections)
+	return srv
+}
+
+// countingFetcher counts the times each url is fetched.
+type countingFetcher struct {
+	Fetcher
+
+	mu     sync.Mutex
+	counts map[string]int
+}
+
+func (f *countingFetcher) Fetch(ctx context.Context, url string) ([]string, error) {
+	f.mu.Lock()
+	if f.counts == nil {
+		f.counts = make(map[string]int)
+	}
+	f.counts[url]++
+	f.mu.Unlock()
+	return f.Fetcher.Fetch(ctx, url)
+}
+
+// crawlWith crawls from start with f, without the politeness delay, and
+// returns the count of fetches of each url.
+func crawlWith(t *testing.T, f Fetcher, start string, depth int) map[string]int {
+	t.Helper()
+
+	cf := &countingFetcher{Fetcher: f}
+	collect(New(WithFetcher(cf), HostDelay(0), Depth(depth)), start)
+	return cf.counts
+}
+
+func TestHTTPFetcher(t *testing.T) {
+	leakcheck.Check(t)
+
+	srv := newFixtureSite(t)
+	f := &HTTPFetcher{}
+	ctx := context.Background()
+
+	got, err := f.Fetch(ctx, srv.URL+"/")
+	if err != nil {
+		t.Fatal(err)
+	}
+	// The canonical link is a link to a page too; the assets are not.
+	want := []string{srv.URL + "/", srv.URL + "/about.html", srv.URL + "/docs/", srv.URL + "/docs/", srv.URL + "/missing.html"}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("got links %q, want %q", got, want)
+	}
+
+	if _, err := f.Fetch(ctx, srv.URL+"/missing.html"); err == nil || !strings.Contains(err.Error(), "404") {
+		t.Errorf("got %v fetching a missing page, want a 404 error", err)
+	}
+}
+
+func TestHTTPFetcherUserAgent(t *testing.T) {
+	leakcheck.Check(t)
+
+	var agent string
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		agent = r.UserAgent()
+	}))
+	t.Cleanup(srv.Close)
+	t.Cleanup(http.DefaultClient.CloseIdleConnections)
+
+	ctx := context.Background()
+	if _, err := (&HTTPFetcher{}).Fetch(ctx, srv.URL); err != nil {
+		t.Fatal(err)
+	}
+	if agent != DefaultUserAgent {
+		t.Errorf("sent User-Agent %q, want %q", agent, DefaultUserAgent)
+	}
+
+	if _, err := (&HTTPFetcher{UserAgent: "TestBot/2.0"}).Fetch(ctx, srv.URL); err != nil {
+		t.Fatal(err)
+	}
+	if agent != "TestBot/2.0" {
+		t.Errorf("sent User-Agent %q, want %q", agent, "TestBot/2.0")
+	}
+}
+
+func TestHTTPFetcherTimeouts(t *testing.T) {
+	leakcheck.Check(t)
+
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/body" {
+			fmt.Fprint(w, "<html><body>")
+			w.(http.Flusher).Flush()
+		}
+		<-r.Context().Done()
+	}))
+	t.Cleanup(srv.Close)
+
+	f := &HTTPFetcher{HeaderTimeout: 50 * time.Millisecond, BodyTimeout: 50 * time.Millisecond}
+	t.Cleanup(f.CloseIdleConnections)
+
+	for _, phase := range []string{"header", "body"} {
+		_, err := f.Fetch(context.Background(), srv.URL+"/"+phase)
+		var te *TimeoutError
+		if !errors.As(err, &te) || te.Phase != phase {
+			t.Errorf("got %v, want a %s timeout", err, phase)
+		}
+	}
+
+	// A connect timeout needs a host that doesn't answer, so only how its
+	// error is told apart is checked.
+	dial := &url.Error{Op: "Get", URL: srv.URL, Err: &net.OpError{Op: "dial", Net: "tcp", Err: os.ErrDeadlineExceeded}}
+	var te *TimeoutError
+	if err := f.timeout(context.Background(), srv.URL, dial); !errors.As(err, &te) || te.Phase != "connect" {
+		t.Errorf("got %v for a dial timeout, want a connect timeout", err)
+	}
+}
+
+func TestCrawlFixtureSite(t *testing.T) {
+	leakcheck.Check(t)
+
+	srv := newFixtureSite(t)
+	counts := crawlWith(t, &HTTPFetcher{}, srv.URL, 5)
+
+	want := map[string]int{
+		srv.URL + "/":                1,
+		srv.URL + "/about.html":      1,
+		srv.URL + "/docs/":           1,
+		srv.URL + "/docs/guide.html": 1,
+		srv.URL + "/missing.html":    1,
+		// The file server redirects this to /docs/, but it is a
+		// different url until it has been fetched.
+		srv.URL + "/docs/index.html": 1,
+	}
+	if !reflect.DeepEqual(counts, want) {
+		t.Errorf("fetched %v, want %v", counts, want)
+	}
+}
+
+// goTour is the Go tour's fake site, where every page links back to
+// others and /cmd/ is missing.
+var goTour = FakeFetcher{
+	"https://golang.org/": &FakeResult{URLs: []string{
+		"https://golang.org/pkg/",
+		"https://golang.org/cmd/",
+	}},
+	"https://golang.org/pkg/": &FakeResult{URLs: []string{
+		"https://golang.org/",
+		"https://golang.org/cmd/",
+		"https://golang.org/pkg/fmt/",
+		"https://golang.org/pkg/os/",
+	}},
+	"https://golang.org/pkg/fmt/": &FakeResult{URLs: []string{
+		"https://golang.org/",
+		"https://golang.org/pkg/",
+	}},
+	"https://golang.org/pkg/os/": &FakeResult{URLs: []string{
+		"https://golang.org/",
+		"https://golang.org/pkg/",
+	}},
+}
+
+// chain is a fake site where page n links to page n+1.
+func chain(n int) FakeFetcher {
+	f := make(FakeFetcher)
+	for i := 0; i < n; i++ {
+		f[page(i)] = &FakeResult{Body: "page", URLs: []string{page(i + 1)}}
+	}
+	return f
+}
+
+func page(i int) string {
+	return "https://example.com/" + string(rune('a'+i))
+}
+
+func TestCrawlDepth(t *testing.T) {
+	for depth := 0; depth < 4; depth++ {
+		counts := crawlWith(t, chain(10), page(0), depth)
+		if len(counts) != depth+1 {
+			t.Errorf("depth %d: fetched %d pages, want %d", depth, len(counts), depth+1)
+		}
+		if counts[page(depth)] != 1 || counts[page(depth+1)] != 0 {
+			t.Errorf("depth %d: fetched %v", depth, counts)
+		}
+	}
+}
+
+func TestCrawlCyclesAndDedup(t *testing.T) {
+	counts := crawlWith(t, goTour, "https://golang.org", 10)
+
+	// Every page links back to others; each is still fetched once, and
+	// the missing /cmd/ page is tried once.
+	want := map[string]int{
+		"https://golang.org/":         1,
+		"https://golang.org/pkg/":     1,
+		"https://golang.org/cmd/":     1,
+		"https://golang.org/pkg/fmt/": 1,
+		"https://golang.org/pkg/os/":  1,
+	}
+	if !reflect.DeepEqual(counts, want) {
+		t.Errorf("fetched %v, want %v", counts, want)
+	}
+}
+
+func TestCrawlErrors(t *testing.T) {
+	// The start page links to a page that fails, and one past it that
+	// doesn't; the failure doesn't stop the crawl.
+	site := FakeFetcher{
+		page(0): &FakeResult{URLs: []string{page(1), page(2)}},
+		page(2): &FakeResult{URLs: []string{page(3)}},
+		page(3): &FakeResult{},
+	}
+
+	done := make(chan map[string]int)
+	go func() {
+		done <- crawlWith(t, site, page(0), 5)
+	}()
+
+	select {
+	case counts := <-done:
+		if len(counts) != 4 || counts[page(1)] != 1 || counts[page(3)] != 1 {
+			t.Errorf("fetched %v, want all 4 pages once", counts)
+		}
+	case <-time.After(10 * time.Second):
+		t.Fatal("the crawl didn't finish after a failed fetch")
+	}
+}
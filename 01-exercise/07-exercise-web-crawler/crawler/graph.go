@@ -0,0 +1,208 @@
+package crawler
+
+import (
+	"encoding/json"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"net/http"
+	"sort"
+	"strings"
+)
+
+// MaxSitemapURLs is the most URLs one sitemap may list.
+const MaxSitemapURLs = 50000
+
+// Node is a page in a Graph.
+type Node struct {
+	URL       string   `json:"url"`
+	Kind      Kind     `json:"kind"`
+	Depth     int      `json:"depth"`
+	Status    int      `json:"status,omitempty"`
+	Redirects []string `json:"redirects,omitempty"`
+
+	// Checked is set for a page that was only checked, not crawled, so
+	// its links aren't known.
+	Checked bool   `json:"checked,omitempty"`
+	Error   string `json:"error,omitempty"`
+
+	// Links are the URLs of the pages the page links to, which are the
+	// graph's edges. Not every URL linked to is a node: links that weren't
+	// followed aren't.
+	Links []string `json:"links"`
+
+	// Assets are the page's refs to images, scripts and the like.
+	Assets []Ref `json:"assets,omitempty"`
+
+	// Meta is what the page says about itself, if it was read.
+	Meta *Meta `json:"meta,omitempty"`
+}
+
+// ok reports whether the page answered 200 OK without redirecting. A page
+// from a Fetcher that isn't a Checker has no status.
+func (n *Node) ok() bool {
+	return n.Error == "" && (n.Status == 0 || n.Status == http.StatusOK) && len(n.Redirects) == 0
+}
+
+// Graph is the structure of a crawl: the pages fetched and the links
+// between them. Add the pages from Run to it, then write it out.
+type Graph struct {
+	nodes map[string]*Node
+}
+
+// NewGraph returns an empty Graph.
+func NewGraph() *Graph {
+	return &Graph{nodes: make(map[string]*Node)}
+}
+
+// Add adds p to the graph.
+func (g *Graph) Add(p Page) {
+	n := &Node{
+		URL:       p.URL,
+		Kind:      p.Kind,
+		Depth:     p.Depth,
+		Status:    p.Status,
+		Redirects: p.Redirects,
+		Checked:   p.Checked,
+		Links:     p.Links,
+		Meta:      p.Meta,
+	}
+	if n.Links == nil {
+		n.Links = []string{}
+	}
+	for _, ref := range p.Refs {
+		if ref.Kind.Asset() {
+			n.Assets = append(n.Assets, ref)
+		}
+	}
+	if p.Err != nil {
+		n.Error = p.Err.Error()
+	}
+	g.nodes[p.URL] = n
+}
+
+// ReadGraph reads a graph written by WriteJSON.
+func ReadGraph(r io.Reader) (*Graph, error) {
+	var data struct {
+		Nodes []*Node `json:"nodes"`
+	}
+	if err := json.NewDecoder(r).Decode(&data); err != nil {
+		return nil, fmt.Errorf("reading graph: %v", err)
+	}
+	g := NewGraph()
+	for _, n := range data.Nodes {
+		if n.URL == "" {
+			return nil, fmt.Errorf("reading graph: node with no url")
+		}
+		g.nodes[n.URL] = n
+	}
+	return g, nil
+}
+
+// Nodes returns the graph's nodes, sorted by URL.
+func (g *Graph) Nodes() []*Node {
+	nodes := []*Node{}
+	for _, n := range g.nodes {
+		nodes = append(nodes, n)
+	}
+	sort.Slice(nodes, func(i, j int) bool { return nodes[i].URL < nodes[j].URL })
+	return nodes
+}
+
+// WriteJSON writes the graph to w as JSON adjacency lists: each node,
+// with the URLs it links to.
+func (g *Graph) WriteJSON(w io.Writer) error {
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	return enc.Encode(struct {
+		Nodes []*Node `json:"nodes"`
+	}{g.Nodes()})
+}
+
+// WriteDOT writes the graph to w in Graphviz's DOT language. Pages that
+// failed are red, pages that were only checked are dashed, and URLs that
+// were linked to but not fetched are dotted.
+func (g *Graph) WriteDOT(w io.Writer) error {
+	var b strings.Builder
+	b.WriteString("digraph crawl {\n\tnode [shape=box];\n")
+
+	nodes := g.Nodes()
+	unfetched := make(map[string]bool)
+	for _, n := range nodes {
+		var attrs []string
+		if n.Error != "" {
+			attrs = append(attrs, "color=red")
+		}
+		if n.Checked {
+			attrs = append(attrs, "style=dashed")
+		}
+		fmt.Fprintf(&b, "\t%s", dotQuote(n.URL))
+		if len(attrs) > 0 {
+			fmt.Fprintf(&b, " [%s]", strings.Join(attrs, ", "))
+		}
+		b.WriteString(";\n")
+
+		for _, u := range n.Links {
+			if g.nodes[u] == nil {
+				unfetched[u] = true
+			}
+		}
+	}
+
+	var us []string
+	for u := range unfetched {
+		us = append(us, u)
+	}
+	sort.Strings(us)
+	for _, u := range us {
+		fmt.Fprintf(&b, "\t%s [style=dotted];\n", dotQuote(u))
+	}
+
+	for _, n := range nodes {
+		for _, u := range n.Links {
+			fmt.Fprintf(&b, "\t%s -> %s;\n", dotQuote(n.URL), dotQuote(u))
+		}
+	}
+	b.WriteString("}\n")
+	_, err := io.WriteString(w, b.String())
+	return err
+}
+
+// dotQuote returns s as a quoted DOT identifier.
+func dotQuote(s string) string {
+	return `"` + strings.NewReplacer(`\`, `\\`, `"`, `\"`).Replace(s) + `"`
+}
+
+// WriteSitemap writes a sitemap.xml to w listing the pages that were
+// crawled, so are in scope, and answered 200 OK without redirecting.
+// Assets are left out.
+func (g *Graph) WriteSitemap(w io.Writer) error {
+	type loc struct {
+		Loc string `xml:"loc"`
+	}
+	set := struct {
+		XMLName xml.Name `xml:"urlset"`
+		XMLNS   string   `xml:"xmlns,attr"`
+		URLs    []loc    `xml:"url"`
+	}{XMLNS: "http://www.sitemaps.org/schemas/sitemap/0.9"}
+
+	for _, n := range g.Nodes() {
+		if n.ok() && !n.Checked && !n.Kind.Asset() {
+			set.URLs = append(set.URLs, loc{n.URL})
+		}
+	}
+	if len(set.URLs) > MaxSitemapURLs {
+		return fmt.Errorf("sitemap has %d urls, more than the %d allowed", len(set.URLs), MaxSitemapURLs)
+	}
+
+	if _, err := io.WriteString(w, xml.Header); err != nil {
+		return err
+	}
+	enc := xml.NewEncoder(w)
+	enc.Indent("", "  ")
+	if err := enc.Encode(set); err != nil {
+		return err
+	}
+	_, err := io.WriteString(w, "\n")
+	return err
+}
@@ -0,0 +1,135 @@
+package crawler
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"encoding/xml"
+	"reflect"
+	"testing"
+
+	"go-concurrency-exercises/pkg/leakcheck"
+)
+
+// tourGraph crawls the Go tour's fake site into a Graph.
+func tourGraph(t *testing.T, opts ...Option) *Graph {
+	t.Helper()
+
+	g := NewGraph()
+	opts = append([]Option{WithFetcher(goTour), HostDelay(0), Depth(1)}, opts...)
+	for p := range New(opts...).Run(context.Background(), "https://golang.org/") {
+		g.Add(p)
+	}
+	return g
+}
+
+func TestGraphJSON(t *testing.T) {
+	leakcheck.Check(t)
+
+	var out bytes.Buffer
+	if err := tourGraph(t).WriteJSON(&out); err != nil {
+		t.Fatal(err)
+	}
+
+	var got struct{ Nodes []Node }
+	if err := json.Unmarshal(out.Bytes(), &got); err != nil {
+		t.Fatalf("%v in\n%s", err, out.String())
+	}
+	want := []Node{
+		{URL: "https://golang.org/", Kind: KindAnchor, Depth: 0, Status: 200, Links: []string{"https://golang.org/pkg/", "https://golang.org/cmd/"}, Meta: &Meta{}},
+		{URL: "https://golang.org/cmd/", Kind: KindAnchor, Depth: 1, Status: 404, Error: "not found: https://golang.org/cmd/", Links: []string{}},
+		{URL: "https://golang.org/pkg/", Kind: KindAnchor, Depth: 1, Status: 200, Links: []string{
+			"https://golang.org/", "https://golang.org/cmd/", "https://golang.org/pkg/fmt/", "https://golang.org/pkg/os/",
+		}, Meta: &Meta{}},
+	}
+	if !reflect.DeepEqual(got.Nodes, want) {
+		t.Errorf("got %+v, want %+v", got.Nodes, want)
+	}
+}
+
+func TestGraphDOT(t *testing.T) {
+	leakcheck.Check(t)
+
+	var out bytes.Buffer
+	if err := tourGraph(t, CheckLinks()).WriteDOT(&out); err != nil {
+		t.Fatal(err)
+	}
+
+	want := `digraph crawl {
+	node [shape=box];
+	"https://golang.org/";
+	"https://golang.org/cmd/" [color=red];
+	"https://golang.org/pkg/";
+	"https://golang.org/pkg/fmt/" [style=dashed];
+	"https://golang.org/pkg/os/" [style=dashed];
+	"https://golang.org/" -> "https://golang.org/pkg/";
+	"https://golang.org/" -> "https://golang.org/cmd/";
+	"https://golang.org/pkg/" -> "https://golang.org/";
+	"https://golang.org/pkg/" -> "https://golang.org/cmd/";
+	"https://golang.org/pkg/" -> "https://golang.org/pkg/fmt/";
+	"https://golang.org/pkg/" -> "https://golang.org/pkg/os/";
+}
+`
+	if out.String() != want {
+		t.Errorf("got\n%s\nwant\n%s", out.String(), want)
+	}
+
+	// Without CheckLinks, the links past the depth aren't fetched.
+	out.Reset()
+	if err := tourGraph(t).WriteDOT(&out); err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Contains(out.Bytes(), []byte("\t\"https://golang.org/pkg/os/\" [style=dotted];\n")) {
+		t.Errorf("got\n%s\nwant /pkg/os/ dotted", out.String())
+	}
+}
+
+func TestGraphSitemap(t *testing.T) {
+	leakcheck.Check(t)
+
+	var out bytes.Buffer
+	if err := tourGraph(t, CheckLinks()).WriteSitemap(&out); err != nil {
+		t.Fatal(err)
+	}
+
+	// /cmd/ is missing, and the pages past the depth were only checked.
+	want := `<?xml version="1.0" encoding="UTF-8"?>
+<urlset xmlns="http://www.sitemaps.org/schemas/sitemap/0.9">
+  <url>
+    <loc>https://golang.org/</loc>
+  </url>
+  <url>
+    <loc>https://golang.org/pkg/</loc>
+  </url>
+</urlset>
+`
+	if out.String() != want {
+		t.Errorf("got\n%s\nwant\n%s", out.String(), want)
+	}
+}
+
+func TestGraphSitemapRedirects(t *testing.T) {
+	leakcheck.Check(t)
+
+	srv := newFixtureSite(t)
+	g := NewGraph()
+	for p := range New(HostDelay(0), Depth(5)).Run(context.Background(), srv.URL) {
+		g.Add(p)
+	}
+	var out bytes.Buffer
+	if err := g.WriteSitemap(&out); err != nil {
+		t.Fatal(err)
+	}
+
+	// /docs/index.html redirects to /docs/, and /missing.html is a 404.
+	var got struct {
+		URLs []string `xml:"url>loc"`
+	}
+	if err := xml.Unmarshal(out.Bytes(), &got); err != nil {
+		t.Fatalf("%v in\n%s", err, out.String())
+	}
+	want := []string{srv.URL + "/", srv.URL + "/about.html", srv.URL + "/docs/", srv.URL + "/docs/guide.html"}
+	if !reflect.DeepEqual(got.URLs, want) {
+		t.Errorf("got %q, want %q", got.URLs, want)
+	}
+}
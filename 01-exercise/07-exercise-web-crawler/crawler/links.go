@@ -0,0 +1,204 @@
+package crawler
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"sort"
+	"strings"
+	"syscall"
+)
+
+// The problems a broken link can have.
+const (
+	ProblemClientError = "4xx"
+	ProblemServerError = "5xx"
+	ProblemTimeout     = "timeout"
+	ProblemUnreachable = "unreachable"
+)
+
+// Link is what a crawl found out about a URL.
+type Link struct {
+	URL       string   `json:"url"`
+	Kind      Kind     `json:"kind"`
+	Status    int      `json:"status,omitempty"`
+	Redirects []string `json:"redirects,omitempty"`
+	LatencyMS int64    `json:"latency_ms"`
+
+	// Checked is set for a URL that was only checked, not crawled.
+	Checked bool `json:"checked,omitempty"`
+
+	// Referrers are the crawled pages that refer to the URL, as a link
+	// or for an asset. A seed has none, unless another page links to it
+	// too.
+	Referrers []string `json:"referrers,omitempty"`
+
+	// Problem is why the link is broken, if it is: ProblemClientError,
+	// ProblemServerError, ProblemTimeout or ProblemUnreachable.
+	Problem string `json:"problem,omitempty"`
+	Error   string `json:"error,omitempty"`
+}
+
+// LinkReport gathers the pages of a crawl, and reports its broken links.
+// Use it with a Crawler made with CheckLinks, so the links that aren't
+// crawled are checked too.
+type LinkReport struct {
+	links     map[string]*Link
+	referrers map[string][]string
+}
+
+// NewLinkReport returns an empty LinkReport.
+func NewLinkReport() *LinkReport {
+	return &LinkReport{links: make(map[string]*Link), referrers: make(map[string][]string)}
+}
+
+// Add records p.
+func (r *LinkReport) Add(p Page) {
+	l := &Link{
+		URL:       p.URL,
+		Kind:      p.Kind,
+		Status:    p.Status,
+		Redirects: p.Redirects,
+		LatencyMS: p.Latency.Milliseconds(),
+		Checked:   p.Checked,
+		Problem:   problem(p),
+	}
+	if p.Err != nil {
+		l.Error = p.Err.Error()
+	}
+	r.links[p.URL] = l
+
+	if p.Err == nil {
+		seen := make(map[string]bool)
+		for _, ref := range p.Refs {
+			if !seen[ref.URL] {
+				seen[ref.URL] = true
+				r.referrers[ref.URL] = append(r.referrers[ref.URL], p.URL)
+			}
+		}
+	}
+}
+
+// problem returns why p is a broken link, or "" if it isn't one, or the
+// crawl was stopped before it could tell.
+func problem(p Page) string {
+	switch {
+	case p.Status >= 500:
+		return ProblemServerError
+	case p.Status >= 400:
+		return ProblemClientError
+	case p.Err == nil:
+		return ""
+	}
+	var te *TimeoutError
+	if errors.As(p.Err, &te) {
+		return ProblemTimeout
+	}
+	if errors.Is(p.Err, context.Canceled) || errors.Is(p.Err, context.DeadlineExceeded) {
+		return ""
+	}
+	var ne net.Error
+	if errors.As(p.Err, &ne) && ne.Timeout() {
+		return ProblemTimeout
+	}
+	// The host couldn't be found, or connected to.
+	var dnsErr *net.DNSError
+	var opErr *net.OpError
+	if errors.As(p.Err, &dnsErr) || errors.As(p.Err, &opErr) || errors.Is(p.Err, syscall.ECONNREFUSED) {
+		return ProblemUnreachable
+	}
+	return ""
+}
+
+// Links returns every URL recorded, sorted.
+func (r *LinkReport) Links() []*Link {
+	links := []*Link{}
+	for _, l := range r.links {
+		refs := append([]string(nil), r.referrers[l.URL]...)
+		sort.Strings(refs)
+		l.Referrers = refs
+		links = append(links, l)
+	}
+	sort.Slice(links, func(i, j int) bool { return links[i].URL < links[j].URL })
+	return links
+}
+
+// Referrer is a page and the broken links on it.
+type Referrer struct {
+	// URL is "" for the broken seeds.
+	URL    string  `json:"url"`
+	Broken []*Link `json:"broken"`
+}
+
+// Broken returns the broken links grouped by the pages that link to
+// them, sorted. A link on more than one page is in each page's group.
+func (r *LinkReport) Broken() []Referrer {
+	groups := make(map[string][]*Link)
+	for _, l := range r.Links() {
+		if l.Problem == "" {
+			continue
+		}
+		if len(l.Referrers) == 0 {
+			groups[""] = append(groups[""], l)
+		}
+		for _, ref := range l.Referrers {
+			groups[ref] = append(groups[ref], l)
+		}
+	}
+
+	refs := []Referrer{}
+	for u, links := range groups {
+		refs = append(refs, Referrer{URL: u, Broken: links})
+	}
+	sort.Slice(refs, func(i, j int) bool { return refs[i].URL < refs[j].URL })
+	return refs
+}
+
+// WriteText writes the broken links to w, grouped by referrer, and a
+// count of the URLs checked.
+func (r *LinkReport) WriteText(w io.Writer) error {
+	var b strings.Builder
+	broken := make(map[string]bool)
+	for _, ref := range r.Broken() {
+		if ref.URL == "" {
+			b.WriteString("start urls\n")
+		} else {
+			fmt.Fprintf(&b, "%s\n", ref.URL)
+		}
+		for _, l := range ref.Broken {
+			what := l.Problem
+			if l.Status != 0 {
+				what = fmt.Sprint(l.Status)
+			}
+			fmt.Fprintf(&b, "\t%s %s", what, l.URL)
+			if l.Kind.Asset() {
+				fmt.Fprintf(&b, " (%s)", l.Kind)
+			}
+			if len(l.Redirects) > 0 {
+				fmt.Fprintf(&b, " -> %s", strings.Join(l.Redirects, " -> "))
+			}
+			if l.Problem == ProblemTimeout || l.Problem == ProblemUnreachable {
+				fmt.Fprintf(&b, " (%s)", l.Error)
+			}
+			b.WriteString("\n")
+			broken[l.URL] = true
+		}
+	}
+	fmt.Fprintf(&b, "checked %d urls, %d broken\n", len(r.links), len(broken))
+	_, err := io.WriteString(w, b.String())
+	return err
+}
+
+// WriteJSON writes every URL recorded, and the broken links grouped by
+// referrer, to w as JSON.
+func (r *LinkReport) WriteJSON(w io.Writer) error {
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	return enc.Encode(struct {
+		Links  []*Link    `json:"links"`
+		Broken []Referrer `json:"broken"`
+	}{r.Links(), r.Broken()})
+}
@@ -0,0 +1,267 @@
+package crawler
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"reflect"
+	"strings"
+	"sync"
+	"syscall"
+	"testing"
+	"time"
+
+	"go-concurrency-exercises/pkg/leakcheck"
+)
+
+// checkSites starts a docs site with broken links, and another site it
+// links to that records the requests it gets.
+func checkSites(t *testing.T) (docs, other *httptest.Server, requests func() []string) {
+	t.Helper()
+
+	var mu sync.Mutex
+	var reqs []string
+	other = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		reqs = append(reqs, r.Method+" "+r.URL.Path)
+		mu.Unlock()
+		switch r.URL.Path {
+		case "/ok":
+			fmt.Fprint(w, `<a href="/never">never crawled</a>`)
+		case "/no-head":
+			if r.Method == http.MethodHead {
+				w.WriteHeader(http.StatusMethodNotAllowed)
+			}
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	t.Cleanup(other.Close)
+
+	pages := map[string]string{
+		"/": `<a href="/guide">guide</a><a href="/gone">gone</a><a href="/old">old</a>` +
+			`<a href="` + other.URL + `/ok">ok</a><a href="` + other.URL + `/no-head">no head</a>`,
+		"/guide": `<a href="/gone">gone</a><a href="/error">error</a><a href="/slow">slow</a><a href="` + other.URL + `/missing">missing</a>`,
+	}
+	docs = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/old":
+			http.Redirect(w, r, "/moved", http.StatusMovedPermanently)
+		case "/error":
+			http.Error(w, "oops", http.StatusInternalServerError)
+		case "/slow":
+			<-r.Context().Done()
+		default:
+			page, ok := pages[r.URL.Path]
+			if !ok {
+				http.NotFound(w, r)
+				return
+			}
+			fmt.Fprint(w, page)
+		}
+	}))
+	t.Cleanup(docs.Close)
+
+	return docs, other, func() []string {
+		mu.Lock()
+		defer mu.Unlock()
+		return append([]string(nil), reqs...)
+	}
+}
+
+func checkLinks(t *testing.T, seeds ...string) *LinkReport {
+	t.Helper()
+
+	c := New(
+		CheckLinks(),
+		WithScope(Scope{Mode: ScopeHost}),
+		HostDelay(0),
+		Depth(5),
+		Timeouts(0, 200*time.Millisecond, 0),
+	)
+	r := NewLinkReport()
+	for p := range c.Run(context.Background(), seeds...) {
+		r.Add(p)
+	}
+	return r
+}
+
+func TestLinkReport(t *testing.T) {
+	leakcheck.Check(t)
+
+	docs, other, requests := checkSites(t)
+	r := checkLinks(t, docs.URL)
+
+	// Off-site links are only checked: HEAD first, and GET if that fails.
+	// robots.txt doesn't apply to checks, so isn't read.
+	got := make(map[string]bool)
+	for _, req := range requests() {
+		got[req] = true
+	}
+	want := map[string]bool{
+		"HEAD /ok":      true,
+		"HEAD /no-head": true,
+		"GET /no-head":  true,
+		"HEAD /missing": true,
+		"GET /missing":  true,
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("other site got %v, want %v", got, want)
+	}
+
+	links := make(map[string]*Link)
+	for _, l := range r.Links() {
+		links[strings.TrimPrefix(strings.TrimPrefix(l.URL, docs.URL), other.URL)] = l
+	}
+	if l := links["/ok"]; l == nil || !l.Checked || l.Status != 200 || l.Problem != "" {
+		t.Errorf("/ok: got %+v, want a checked 200", l)
+	}
+	if l := links["/old"]; l == nil || l.Status != 404 || !reflect.DeepEqual(l.Redirects, []string{docs.URL + "/moved"}) {
+		t.Errorf("/old: got %+v, want a 404 after a redirect to /moved", l)
+	}
+	if l := links["/gone"]; l == nil || !reflect.DeepEqual(l.Referrers, []string{docs.URL + "/", docs.URL + "/guide"}) {
+		t.Errorf("/gone: got %+v, want it referred by / and /guide", l)
+	}
+
+	// Each referrer's broken links, by url, with their problems.
+	groups := make(map[string]map[string]string)
+	for _, ref := range r.Broken() {
+		groups[ref.URL] = make(map[string]string)
+		for _, l := range ref.Broken {
+			groups[ref.URL][l.URL] = l.Problem
+		}
+	}
+	wantGroups := map[string]map[string]string{
+		docs.URL + "/": {
+			docs.URL + "/gone": ProblemClientError,
+			docs.URL + "/old":  ProblemClientError,
+		},
+		docs.URL + "/guide": {
+			docs.URL + "/error":    ProblemServerError,
+			docs.URL + "/gone":     ProblemClientError,
+			docs.URL + "/slow":     ProblemTimeout,
+			other.URL + "/missing": ProblemClientError,
+		},
+	}
+	if !reflect.DeepEqual(groups, wantGroups) {
+		t.Errorf("got broken links %v, want %v", groups, wantGroups)
+	}
+}
+
+func TestLinkReportText(t *testing.T) {
+	leakcheck.Check(t)
+
+	docs, _, _ := checkSites(t)
+	var out bytes.Buffer
+	if err := checkLinks(t, docs.URL, docs.URL+"/nowhere").WriteText(&out); err != nil {
+		t.Fatal(err)
+	}
+
+	// The other site's /missing sorts by its port among the /guide lines.
+	for _, want := range []string{
+		"start urls\n\t404 DOCS/nowhere\nDOCS/\n",
+		"DOCS/\n\t404 DOCS/gone\n\t404 DOCS/old -> DOCS/moved\nDOCS/guide\n",
+		"\t500 DOCS/error\n",
+		"\t404 DOCS/gone\n\ttimeout DOCS/slow (getting DOCS/slow: header timed out after 200ms)\n",
+		"/missing\n",
+	} {
+		want = strings.ReplaceAll(want, "DOCS", docs.URL)
+		if !strings.Contains(out.String(), want) {
+			t.Errorf("got\n%s\nwant it to have\n%s", out.String(), want)
+		}
+	}
+	if !strings.HasSuffix(out.String(), "checked 10 urls, 6 broken\n") {
+		t.Errorf("got\n%s\nwant 10 urls checked and 6 broken", out.String())
+	}
+}
+
+func TestLinkReportJSON(t *testing.T) {
+	leakcheck.Check(t)
+
+	docs, _, _ := checkSites(t)
+	var out bytes.Buffer
+	if err := checkLinks(t, docs.URL).WriteJSON(&out); err != nil {
+		t.Fatal(err)
+	}
+
+	var got struct {
+		Links  []Link
+		Broken []struct {
+			URL    string
+			Broken []Link
+		}
+	}
+	if err := json.Unmarshal(out.Bytes(), &got); err != nil {
+		t.Fatalf("%v in\n%s", err, out.String())
+	}
+	if len(got.Links) != 9 || len(got.Broken) != 2 {
+		t.Errorf("got %d links and %d referrers, want 9 and 2:\n%s", len(got.Links), len(got.Broken), out.String())
+	}
+	if len(got.Broken) > 1 && got.Broken[1].URL != docs.URL+"/guide" {
+		t.Errorf("second referrer is %s, want %s/guide", got.Broken[1].URL, docs.URL)
+	}
+}
+
+func TestLinkReportOffSite(t *testing.T) {
+	leakcheck.Check(t)
+
+	// Neither host answers for its robots.txt either, which mustn't hide
+	// its broken links.
+	failing := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		http.Error(w, "down", http.StatusInternalServerError)
+	}))
+	t.Cleanup(failing.Close)
+	hanging := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		<-r.Context().Done()
+	}))
+	t.Cleanup(hanging.Close)
+	const refused = "http://127.0.0.1:1/page"
+
+	docs := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprintf(w, `<a href="%s/page">failing</a><a href="%s/page">hanging</a><a href="%s">refused</a>`,
+			failing.URL, hanging.URL, refused)
+	}))
+	t.Cleanup(docs.Close)
+
+	got := make(map[string]string)
+	for _, l := range checkLinks(t, docs.URL).Links() {
+		got[l.URL] = l.Problem
+	}
+	want := map[string]string{
+		docs.URL + "/":        "",
+		failing.URL + "/page": ProblemServerError,
+		hanging.URL + "/page": ProblemTimeout,
+		refused:               ProblemUnreachable,
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("got problems %v, want %v", got, want)
+	}
+}
+
+func TestProblem(t *testing.T) {
+	dial := &net.OpError{Op: "dial", Net: "tcp", Err: os.NewSyscallError("connect", syscall.ECONNREFUSED)}
+	for _, tt := range []struct {
+		name string
+		err  error
+		want string
+	}{
+		{"refused", &url.Error{Op: "Get", URL: "http://a/", Err: dial}, ProblemUnreachable},
+		{"refused, unwrapped", fmt.Errorf("getting http://a/: %w", syscall.ECONNREFUSED), ProblemUnreachable},
+		{"dns", &url.Error{Op: "Get", URL: "http://a/", Err: &net.OpError{Op: "dial", Err: &net.DNSError{Err: "no such host", Name: "a", IsNotFound: true}}}, ProblemUnreachable},
+		{"dns, unwrapped", &net.DNSError{Err: "no such host", Name: "a"}, ProblemUnreachable},
+		{"dns timeout", &net.DNSError{Err: "i/o timeout", Name: "a", IsTimeout: true}, ProblemTimeout},
+		{"timeout", &TimeoutError{URL: "http://a/", Phase: "header"}, ProblemTimeout},
+		{"canceled", context.Canceled, ""},
+		{"disallowed", ErrDisallowed, ""},
+	} {
+		if got := problem(Page{Err: tt.err}); got != tt.want {
+			t.Errorf("%s: got %q, want %q", tt.name, got, tt.want)
+		}
+	}
+}
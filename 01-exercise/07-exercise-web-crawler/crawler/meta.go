@@ -0,0 +1,145 @@
+package crawler
+
+import (
+	"strings"
+	"unicode/utf8"
+
+	"golang.org/x/net/html"
+)
+
+// Meta is what a page says about itself, read from its HTML.
+type Meta struct {
+	Title       string `json:"title,omitempty"`
+	Description string `json:"description,omitempty"`
+
+	// Robots are the directives of the page's robots meta tags, such as
+	// "noindex" and "nofollow", in lower case.
+	Robots []string `json:"robots,omitempty"`
+
+	// Canonical is the absolute URL of the page's <link rel=canonical>,
+	// if it has one.
+	Canonical string `json:"canonical,omitempty"`
+
+	// Lang is the lang attribute of the page's <html>.
+	Lang string `json:"lang,omitempty"`
+
+	// Headings are the page's <h1> to <h6>, in order, as an outline.
+	Headings []Heading `json:"headings,omitempty"`
+
+	// TextLength is the number of characters of visible text on the page,
+	// with runs of white space counted as one.
+	TextLength int `json:"text_length"`
+}
+
+// Heading is a heading on a page.
+type Heading struct {
+	Level int    `json:"level"`
+	Text  string `json:"text"`
+}
+
+// NoIndex reports whether the page asks not to be indexed.
+func (m *Meta) NoIndex() bool {
+	return contains(m.Robots, "noindex") || contains(m.Robots, "none")
+}
+
+// invisible are the elements whose text isn't shown.
+var invisible = map[string]bool{
+	"head":     true,
+	"script":   true,
+	"style":    true,
+	"noscript": true,
+	"template": true,
+}
+
+// readMeta reads the metadata of the page doc, and its visible text with
+// white space collapsed. Its canonical URL is as written.
+func readMeta(doc *html.Node) (*Meta, string) {
+	m := &Meta{}
+	var text strings.Builder
+	var walk func(n *html.Node, visible bool)
+	walk = func(n *html.Node, visible bool) {
+		switch n.Type {
+		case html.TextNode:
+			if visible {
+				text.WriteString(n.Data)
+			}
+			return
+		case html.ElementNode:
+			readElement(m, n)
+			if invisible[n.Data] {
+				visible = false
+			}
+		}
+		for c := n.FirstChild; c != nil; c = c.NextSibling {
+			walk(c, visible)
+		}
+	}
+	walk(doc, true)
+	visible := collapse(text.String())
+	m.TextLength = utf8.RuneCountInString(visible)
+	return m, visible
+}
+
+// readElement records what the element n says about its page in m.
+func readElement(m *Meta, n *html.Node) {
+	attr := func(key string) string {
+		for _, a := range n.Attr {
+			if a.Key == key {
+				return a.Val
+			}
+		}
+		return ""
+	}
+
+	switch n.Data {
+	case "html":
+		if m.Lang == "" {
+			m.Lang = strings.TrimSpace(attr("lang"))
+		}
+	case "title":
+		if m.Title == "" {
+			m.Title = collapse(textOf(n))
+		}
+	case "meta":
+		switch strings.ToLower(attr("name")) {
+		case "description":
+			if m.Description == "" {
+				m.Description = collapse(attr("content"))
+			}
+		case "robots":
+			for _, d := range strings.Split(attr("content"), ",") {
+				if d = strings.ToLower(strings.TrimSpace(d)); d != "" {
+					m.Robots = append(m.Robots, d)
+				}
+			}
+		}
+	case "link":
+		rels := strings.Fields(strings.ToLower(attr("rel")))
+		if m.Canonical == "" && contains(rels, "canonical") {
+			m.Canonical = strings.TrimSpace(attr("href"))
+		}
+	case "h1", "h2", "h3", "h4", "h5", "h6":
+		m.Headings = append(m.Headings, Heading{Level: int(n.Data[1] - '0'), Text: collapse(textOf(n))})
+	}
+}
+
+// textOf returns the text within n.
+func textOf(n *html.Node) string {
+	var b strings.Builder
+	var walk func(n *html.Node)
+	walk = func(n *html.Node) {
+		if n.Type == html.TextNode {
+			b.WriteString(n.Data)
+		}
+		for c := n.FirstChild; c != nil; c = c.NextSibling {
+			walk(c)
+		}
+	}
+	walk(n)
+	return b.String()
+}
+
+// collapse trims s and turns each run of white space in it into a space.
+func collapse(s string) string {
+	return strings.Join(strings.Fields(s), " ")
+}
@@ -0,0 +1,83 @@
+package crawler
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+
+	"golang.org/x/net/html"
+)
+
+func TestReadMeta(t *testing.T) {
+	const page = `<!DOCTYPE html>
+<html lang=" en-GB ">
+<head>
+<title>
+	A  &amp; page
+</title>
+<meta name="Description" content=" What the
+ page is about. ">
+<meta name="description" content="Not this one.">
+<meta name="robots" content="NoIndex, nofollow">
+<meta name="robots" content="noarchive">
+<link rel="Canonical" href="/page">
+<link rel="canonical" href="/other">
+<style>h1 { color: red }</style>
+<script>var hidden = "text";</script>
+</head>
+<body>
+<h1>Top <em>heading</em></h1>
+<p>Some   text,
+with white space.</p>
+<noscript>Turn on JavaScript.</noscript>
+<h3>Skipped a level</h3>
+<template><p>Not shown.</p></template>
+<h2></h2>
+</body>
+</html>`
+	doc, err := html.Parse(strings.NewReader(page))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	want := &Meta{
+		Title:       "A & page",
+		Description: "What the page is about.",
+		Robots:      []string{"noindex", "nofollow", "noarchive"},
+		Canonical:   "/page",
+		Lang:        "en-GB",
+		Headings: []Heading{
+			{Level: 1, Text: "Top heading"},
+			{Level: 3, Text: "Skipped a level"},
+			{Level: 2, Text: ""},
+		},
+		TextLength: len("Top heading Some text, with white space. Skipped a level"),
+	}
+	got, text := readMeta(doc)
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("got %+v, want %+v", got, want)
+	}
+	if want := "Top heading Some text, with white space. Skipped a level"; text != want {
+		t.Errorf("got text %q, want %q", text, want)
+	}
+	if !want.NoIndex() {
+		t.Error("NoIndex() = false for a noindex page")
+	}
+}
+
+func TestReadMetaEmpty(t *testing.T) {
+	doc, err := html.Parse(strings.NewReader("<p>Café</p>"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	got, _ := readMeta(doc)
+	if want := (&Meta{TextLength: 4}); !reflect.DeepEqual(got, want) {
+		t.Errorf("got %+v, want %+v", got, want)
+	}
+	if got.NoIndex() {
+		t.Error("NoIndex() = true for a page with no robots meta")
+	}
+	if !(&Meta{Robots: []string{"none"}}).NoIndex() {
+		t.Error(`NoIndex() = false for robots "none"`)
+	}
+}
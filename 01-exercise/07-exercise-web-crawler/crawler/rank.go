@@ -0,0 +1,189 @@
+package crawler
+
+import (
+	"math"
+	"runtime"
+	"sort"
+	"sync"
+)
+
+// RankOptions tune PageRank. The zero value uses the defaults.
+type RankOptions struct {
+	// Damping is the chance a surfer follows a link rather than jumping
+	// to a random page; 0.85 by default.
+	Damping float64
+
+	// Tolerance stops the iterations once the ranks change by less than
+	// it in total; 1e-6 by default.
+	Tolerance float64
+
+	// MaxIterations stops the iterations even if the ranks haven't
+	// converged; 100 by default.
+	MaxIterations int
+
+	// Workers is the number of goroutines each iteration is split
+	// between; runtime.GOMAXPROCS(0) by default.
+	Workers int
+}
+
+func (o RankOptions) withDefaults() RankOptions {
+	if o.Damping <= 0 || o.Damping >= 1 {
+		o.Damping = 0.85
+	}
+	if o.Tolerance <= 0 {
+		o.Tolerance = 1e-6
+	}
+	if o.MaxIterations <= 0 {
+		o.MaxIterations = 100
+	}
+	if o.Workers <= 0 {
+		o.Workers = runtime.GOMAXPROCS(0)
+	}
+	return o
+}
+
+// Rank is a page's PageRank.
+type Rank struct {
+	URL  string
+	Rank float64
+
+	// Inlinks is how many other pages in the graph link to the page.
+	Inlinks int
+}
+
+// Ranking is the outcome of PageRank.
+type Ranking struct {
+	// Ranks are highest first. They add up to 1.
+	Ranks []Rank
+
+	Iterations int
+	Converged  bool
+}
+
+// PageRank ranks the pages that were crawled, leaving out assets, by how
+// important the links between them make them. Only links between crawled
+// pages count, so the ranks show internal link importance; links to other
+// pages, and a page's links to itself, are left out. A page with no links
+// left, such as a missing page, is dangling: its rank is shared out
+// between every page, as if a surfer jumped from it at random.
+func (g *Graph) PageRank(opts RankOptions) Ranking {
+	var urls []string
+	for _, n := range g.Nodes() {
+		if !n.Checked && !n.Kind.Asset() {
+			urls = append(urls, n.URL)
+		}
+	}
+	index := make(map[string]int, len(urls))
+	for i, u := range urls {
+		index[u] = i
+	}
+
+	out := make([][]int, len(urls))
+	for i, u := range urls {
+		for _, link := range g.nodes[u].Links {
+			if j, ok := index[link]; ok && j != i {
+				out[i] = append(out[i], j)
+			}
+		}
+	}
+
+	ranks, iterations, converged := pageRank(out, opts.withDefaults())
+	r := Ranking{Iterations: iterations, Converged: converged}
+	inlinks := make([]int, len(urls))
+	for _, js := range out {
+		for _, j := range js {
+			inlinks[j]++
+		}
+	}
+	for i, u := range urls {
+		r.Ranks = append(r.Ranks, Rank{URL: u, Rank: ranks[i], Inlinks: inlinks[i]})
+	}
+	sort.SliceStable(r.Ranks, func(i, j int) bool { return r.Ranks[i].Rank > r.Ranks[j].Rank })
+	return r
+}
+
+// pageRank computes the PageRank of the nodes of a graph where out[i]
+// are the nodes i links to, each once. It returns the ranks, how many
+// iterations were run, and whether they converged.
+//
+// Each iteration splits the nodes into a contiguous part per worker. A
+// worker works out the new rank of each of its nodes from the old ranks
+// of the nodes linking to it, so no two workers write the same rank, and
+// sums its part of the change and of the dangling nodes' rank for the
+// next iteration.
+func pageRank(out [][]int, o RankOptions) ([]float64, int, bool) {
+	n := len(out)
+	if n == 0 {
+		return nil, 0, true
+	}
+
+	in := make([][]int, n)
+	for i, js := range out {
+		for _, j := range js {
+			in[j] = append(in[j], i)
+		}
+	}
+
+	old, next := make([]float64, n), make([]float64, n)
+	dangling := 0.0
+	for i := range old {
+		old[i] = 1 / float64(n)
+		if len(out[i]) == 0 {
+			dangling += old[i]
+		}
+	}
+
+	workers := o.Workers
+	if workers > n {
+		workers = n
+	}
+	size := (n + workers - 1) / workers
+
+	// Each worker's share of the change in rank, and of the rank of the
+	// dangling nodes, for the iteration.
+	type partial struct{ diff, dangling float64 }
+	partials := make([]partial, workers)
+
+	for it := 1; it <= o.MaxIterations; it++ {
+		base := (1-o.Damping)/float64(n) + o.Damping*dangling/float64(n)
+
+		var wg sync.WaitGroup
+		for w := 0; w < workers; w++ {
+			lo, hi := w*size, (w+1)*size
+			if hi > n {
+				hi = n
+			}
+			wg.Add(1)
+			go func(w, lo, hi int) {
+				defer wg.Done()
+
+				var p partial
+				for j := lo; j < hi; j++ {
+					sum := 0.0
+					for _, i := range in[j] {
+						sum += old[i] / float64(len(out[i]))
+					}
+					next[j] = base + o.Damping*sum
+					p.diff += math.Abs(next[j] - old[j])
+					if len(out[j]) == 0 {
+						p.dangling += next[j]
+					}
+				}
+				partials[w] = p
+			}(w, lo, hi)
+		}
+		wg.Wait()
+
+		diff := 0.0
+		dangling = 0
+		for _, p := range partials[:workers] {
+			diff += p.diff
+			dangling += p.dangling
+		}
+		old, next = next, old
+		if diff < o.Tolerance {
+			return old, it, true
+		}
+	}
+	return old, o.MaxIterations, false
+}
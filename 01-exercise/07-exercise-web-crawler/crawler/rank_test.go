@@ -0,0 +1,144 @@
+package crawler
+
+import (
+	"bytes"
+	"math"
+	"math/rand"
+	"strings"
+	"testing"
+
+	"go-concurrency-exercises/pkg/leakcheck"
+)
+
+func TestPageRankKnownGraphs(t *testing.T) {
+	leakcheck.Check(t)
+
+	tests := []struct {
+		name string
+		out  [][]int
+		want []float64
+	}{
+		{"pair", [][]int{{1}, {0}}, []float64{0.5, 0.5}},
+		{"cycle", [][]int{{1}, {2}, {0}}, []float64{1.0 / 3, 1.0 / 3, 1.0 / 3}},
+		// 1 is dangling, so its rank is shared by both: with r0 + r1 = 1,
+		// r0 = 0.075 + 0.425 r1, so r0 = 0.5 / 1.425.
+		{"dangling", [][]int{{1}, {}}, []float64{0.5 / 1.425, 1 - 0.5/1.425}},
+		// 0 links to 1 and 2, 1 to 2, and 2 back to 0; solving the
+		// equations by hand gives these.
+		{"triangle", [][]int{{1, 2}, {2}, {0}}, []float64{0.387790, 0.214811, 0.397399}},
+		{"no links", [][]int{{}, {}, {}, {}}, []float64{0.25, 0.25, 0.25, 0.25}},
+	}
+
+	for _, tt := range tests {
+		for _, workers := range []int{1, 2, 8} {
+			opts := RankOptions{Workers: workers, Tolerance: 1e-10, MaxIterations: 1000}
+			got, _, converged := pageRank(tt.out, opts.withDefaults())
+			if !converged {
+				t.Errorf("%s with %d workers didn't converge", tt.name, workers)
+			}
+			for i := range tt.want {
+				if math.Abs(got[i]-tt.want[i]) > 1e-5 {
+					t.Errorf("%s with %d workers: got %v, want %v", tt.name, workers, got, tt.want)
+					break
+				}
+			}
+		}
+	}
+}
+
+// randomGraph returns a graph of n nodes with links at random, some of
+// them dangling.
+func randomGraph(n int, seed int64) [][]int {
+	rnd := rand.New(rand.NewSource(seed))
+	out := make([][]int, n)
+	for i := range out {
+		if rnd.Intn(10) == 0 {
+			continue
+		}
+		seen := make(map[int]bool)
+		for k := rnd.Intn(8); k >= 0; k-- {
+			if j := rnd.Intn(n); j != i && !seen[j] {
+				seen[j] = true
+				out[i] = append(out[i], j)
+			}
+		}
+	}
+	return out
+}
+
+func TestPageRankWorkersAgree(t *testing.T) {
+	leakcheck.Check(t)
+
+	out := randomGraph(1000, 1)
+	serial, its, _ := pageRank(out, RankOptions{Workers: 1}.withDefaults())
+
+	for _, workers := range []int{3, 7, 64} {
+		got, n, _ := pageRank(out, RankOptions{Workers: workers}.withDefaults())
+		if n != its {
+			t.Errorf("%d workers took %d iterations, 1 took %d", workers, n, its)
+		}
+		sum := 0.0
+		for i := range got {
+			sum += got[i]
+			if math.Abs(got[i]-serial[i]) > 1e-12 {
+				t.Fatalf("%d workers: rank %d is %v, 1 worker gave %v", workers, i, got[i], serial[i])
+			}
+		}
+		if math.Abs(sum-1) > 1e-9 {
+			t.Errorf("%d workers: ranks add up to %v, want 1", workers, sum)
+		}
+	}
+}
+
+func TestPageRankMaxIterations(t *testing.T) {
+	leakcheck.Check(t)
+
+	_, its, converged := pageRank(randomGraph(100, 2), RankOptions{MaxIterations: 2}.withDefaults())
+	if its != 2 || converged {
+		t.Errorf("got %d iterations, converged %v; want 2, not converged", its, converged)
+	}
+}
+
+func TestGraphPageRank(t *testing.T) {
+	leakcheck.Check(t)
+
+	// The graph is read back from JSON, as the pagerank command does.
+	// Pages only checked, links off the graph and self-links don't count.
+	const graph = `{"nodes": [
+		{"url": "https://a/", "links": ["https://a/", "https://a/b", "https://a/c", "https://elsewhere/"]},
+		{"url": "https://a/b", "links": ["https://a/c", "https://a/x"]},
+		{"url": "https://a/c", "links": ["https://a/"]},
+		{"url": "https://a/x", "checked": true, "links": []}
+	]}`
+	g, err := ReadGraph(strings.NewReader(graph))
+	if err != nil {
+		t.Fatal(err)
+	}
+	r := g.PageRank(RankOptions{Tolerance: 1e-10, MaxIterations: 1000})
+
+	want := []Rank{
+		{URL: "https://a/c", Rank: 0.397399, Inlinks: 2},
+		{URL: "https://a/", Rank: 0.387790, Inlinks: 1},
+		{URL: "https://a/b", Rank: 0.214811, Inlinks: 1},
+	}
+	if len(r.Ranks) != len(want) || !r.Converged {
+		t.Fatalf("got %+v, want %+v, converged", r, want)
+	}
+	for i, w := range want {
+		got := r.Ranks[i]
+		if got.URL != w.URL || got.Inlinks != w.Inlinks || math.Abs(got.Rank-w.Rank) > 1e-5 {
+			t.Errorf("rank %d: got %+v, want %+v", i+1, got, w)
+		}
+	}
+
+	var buf bytes.Buffer
+	if err := g.WriteJSON(&buf); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := ReadGraph(&buf); err != nil {
+		t.Errorf("reading a written graph: %v", err)
+	}
+	if _, err := ReadGraph(strings.NewReader(`{"nodes": [{"depth": 1}]}`)); err == nil {
+		t.Error("got no error reading a node with no url")
+	}
+}
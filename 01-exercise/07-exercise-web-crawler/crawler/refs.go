@@ -0,0 +1,155 @@
+package crawler
+
+import (
+	"regexp"
+	"strings"
+
+	"golang.org/x/net/html"
+)
+
+// Kind is what a page refers to a URL for.
+type Kind string
+
+const (
+	// KindAnchor is an <a href>. Seeds are anchors too.
+	KindAnchor Kind = "a"
+
+	// KindFrame is an <iframe src>.
+	KindFrame Kind = "iframe"
+
+	// KindCanonical and KindAlternate are <link href>s with rel
+	// canonical or alternate.
+	KindCanonical Kind = "canonical"
+	KindAlternate Kind = "alternate"
+
+	// KindImage is an <img src>, or a URL in an <img> or <source> srcset.
+	KindImage Kind = "img"
+
+	// KindScript is a <script src>.
+	KindScript Kind = "script"
+
+	// KindStylesheet is a <link rel=stylesheet href>.
+	KindStylesheet Kind = "stylesheet"
+
+	// KindStyle is a url() in a style attribute or <style> element.
+	KindStyle Kind = "style"
+)
+
+// Asset reports whether k refers to something a page uses, such as an
+// image or script, rather than to another page.
+func (k Kind) Asset() bool {
+	switch k {
+	case KindImage, KindScript, KindStylesheet, KindStyle:
+		return true
+	}
+	return false
+}
+
+// Ref is a reference from a page to a URL.
+type Ref struct {
+	URL  string `json:"url"`
+	Kind Kind   `json:"kind"`
+}
+
+// cssURL matches a url() in CSS, with the URL in one of its groups.
+var cssURL = regexp.MustCompile(`url\(\s*(?:"([^"]*)"|'([^']*)'|([^)\s"']*))\s*\)`)
+
+// visit appends to refs each reference found in n, with its URL as
+// written, and returns the result.
+func visit(refs []Ref, n *html.Node) []Ref {
+	if n.Type == html.ElementNode {
+		refs = element(refs, n)
+	}
+	for c := n.FirstChild; c != nil; c = c.NextSibling {
+		refs = visit(refs, c)
+	}
+	return refs
+}
+
+// element appends the references made by the element n's tag and
+// attributes to refs, and returns the result.
+func element(refs []Ref, n *html.Node) []Ref {
+	attr := func(key string) (string, bool) {
+		for _, a := range n.Attr {
+			if a.Key == key {
+				return a.Val, true
+			}
+		}
+		return "", false
+	}
+	add := func(key string, kind Kind) {
+		if v, ok := attr(key); ok {
+			refs = append(refs, Ref{v, kind})
+		}
+	}
+
+	switch n.Data {
+	case "a":
+		add("href", KindAnchor)
+	case "iframe":
+		add("src", KindFrame)
+	case "img":
+		add("src", KindImage)
+		refs = srcset(refs, n)
+	case "source":
+		refs = srcset(refs, n)
+	case "script":
+		add("src", KindScript)
+	case "link":
+		rel, _ := attr("rel")
+		rels := strings.Fields(strings.ToLower(rel))
+		switch {
+		case contains(rels, "stylesheet"):
+			add("href", KindStylesheet)
+		case contains(rels, "canonical"):
+			add("href", KindCanonical)
+		case contains(rels, "alternate"):
+			add("href", KindAlternate)
+		}
+	case "style":
+		for c := n.FirstChild; c != nil; c = c.NextSibling {
+			if c.Type == html.TextNode {
+				refs = styleURLs(refs, c.Data)
+			}
+		}
+	}
+	if style, ok := attr("style"); ok {
+		refs = styleURLs(refs, style)
+	}
+	return refs
+}
+
+// srcset appends the image URLs in n's srcset, such as
+// "a.png 1x, b.png 2x", to refs, and returns the result.
+func srcset(refs []Ref, n *html.Node) []Ref {
+	for _, a := range n.Attr {
+		if a.Key != "srcset" {
+			continue
+		}
+		for _, candidate := range strings.Split(a.Val, ",") {
+			if fields := strings.Fields(candidate); len(fields) > 0 {
+				refs = append(refs, Ref{fields[0], KindImage})
+			}
+		}
+	}
+	return refs
+}
+
+// styleURLs appends the url()s in css to refs, and returns the result.
+func styleURLs(refs []Ref, css string) []Ref {
+	for _, m := range cssURL.FindAllStringSubmatch(css, -1) {
+		if u := m[1] + m[2] + m[3]; u != "" {
+			refs = append(refs, Ref{u, KindStyle})
+		}
+	}
+	return refs
+}
+
+func contains(ss []string, s string) bool {
+	for _, x := range ss {
+		if x == s {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,142 @@
+package crawler
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+
+	"golang.org/x/net/html"
+
+	"go-concurrency-exercises/pkg/leakcheck"
+)
+
+func TestVisit(t *testing.T) {
+	tests := []struct {
+		html string
+		want []Ref
+	}{
+		{`<a href="a">a</a><a>no href</a>`, []Ref{{"a", KindAnchor}}},
+		{`<iframe src="frame.html"></iframe>`, []Ref{{"frame.html", KindFrame}}},
+		{`<img src="a.png" srcset="a.png 1x,b.png 2x, c.png 640w">`, []Ref{
+			{"a.png", KindImage}, {"a.png", KindImage}, {"b.png", KindImage}, {"c.png", KindImage},
+		}},
+		{`<picture><source srcset="wide.webp"><img src="narrow.png"></picture>`, []Ref{
+			{"wide.webp", KindImage}, {"narrow.png", KindImage},
+		}},
+		{`<script src="app.js"></script><script>var inline;</script>`, []Ref{{"app.js", KindScript}}},
+		{`<link rel="stylesheet" href="s.css"><link rel="Alternate StyleSheet" href="alt.css">`, []Ref{
+			{"s.css", KindStylesheet}, {"alt.css", KindStylesheet},
+		}},
+		{`<link rel="canonical" href="/page"><link rel="alternate" hreflang="fr" href="/fr/page"><link rel="icon" href="/favicon.ico">`, []Ref{
+			{"/page", KindCanonical}, {"/fr/page", KindAlternate},
+		}},
+		{`<div style="background: url(a.png), url( 'b.png' ) ; mask: url(&quot;c.svg&quot;)">x</div>`, []Ref{
+			{"a.png", KindStyle}, {"b.png", KindStyle}, {"c.svg", KindStyle},
+		}},
+		{`<style>body { background: url("bg.png") } .empty { background: url() }</style>`, []Ref{{"bg.png", KindStyle}}},
+		{`<a href="page" style="background: url(icon.png)">both</a>`, []Ref{{"page", KindAnchor}, {"icon.png", KindStyle}}},
+	}
+
+	for _, tt := range tests {
+		doc, err := html.Parse(strings.NewReader(tt.html))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got := visit(nil, doc); !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("visit(%s) = %v, want %v", tt.html, got, tt.want)
+		}
+	}
+}
+
+func TestRunAssets(t *testing.T) {
+	leakcheck.Check(t)
+
+	srv := newFixtureSite(t)
+	run := func(opts ...Option) map[string]Page {
+		opts = append([]Option{HostDelay(0), Depth(1)}, opts...)
+		return collect(New(opts...), srv.URL)
+	}
+
+	// Without Assets, they are only listed.
+	pages := run()
+	home := pages[srv.URL+"/"]
+	wantRefs := []Ref{
+		{srv.URL + "/style.css", KindStylesheet},
+		{srv.URL + "/", KindCanonical},
+		{srv.URL + "/app.js", KindScript},
+		{srv.URL + "/bg.png", KindStyle},
+		{srv.URL + "/logo.gif", KindImage},
+		{srv.URL + "/logo-2x.gif", KindImage},
+		{srv.URL + "/about.html", KindAnchor},
+		{srv.URL + "/docs/", KindAnchor},
+		{srv.URL + "/missing.html", KindAnchor},
+	}
+	if !reflect.DeepEqual(home.Refs, wantRefs) {
+		t.Errorf("got refs %v, want %v", home.Refs, wantRefs)
+	}
+	if _, ok := pages[srv.URL+"/logo.gif"]; ok {
+		t.Error("fetched an asset without Assets")
+	}
+
+	// With Assets, they are checked, as is the one past the depth.
+	pages = run(Assets(false), Depth(0))
+	for u, want := range map[string]struct {
+		kind   Kind
+		status int
+	}{
+		"/style.css":   {KindStylesheet, 200},
+		"/app.js":      {KindScript, 200},
+		"/bg.png":      {KindStyle, 404},
+		"/logo.gif":    {KindImage, 200},
+		"/logo-2x.gif": {KindImage, 404},
+	} {
+		p, ok := pages[srv.URL+u]
+		if !ok || !p.Checked || p.Kind != want.kind || p.Status != want.status {
+			t.Errorf("%s: got %+v, want a checked %s with status %d", u, p, want.kind, want.status)
+		}
+	}
+	if _, ok := pages[srv.URL+"/about.html"]; ok {
+		t.Error("fetched a page past the depth")
+	}
+
+	r := NewLinkReport()
+	for _, p := range pages {
+		r.Add(p)
+	}
+	var b strings.Builder
+	if err := r.WriteText(&b); err != nil {
+		t.Fatal(err)
+	}
+	for _, want := range []string{
+		"\t404 " + srv.URL + "/bg.png (style)\n",
+		"\t404 " + srv.URL + "/logo-2x.gif (img)\n",
+	} {
+		if !strings.Contains(b.String(), want) {
+			t.Errorf("got report\n%s\nwant it to contain %q", b.String(), want)
+		}
+	}
+
+	// Followed assets are fetched as pages are, but not parsed unless
+	// they are HTML.
+	pages = run(Assets(true))
+	if p := pages[srv.URL+"/style.css"]; p.Checked || p.Status != 200 || len(p.Refs) != 0 {
+		t.Errorf("style.css: got %+v, want it fetched, with no refs", p)
+	}
+}
+
+func TestIsHTML(t *testing.T) {
+	for ct, want := range map[string]bool{
+		"":                          true,
+		"text/html":                 true,
+		"text/html; charset=utf-8":  true,
+		"application/xhtml+xml":     true,
+		"text/css; charset=utf-8":   false,
+		"image/png":                 false,
+		"application/octet-stream":  false,
+		"text/html; charset=\"utf8": false,
+	} {
+		if got := isHTML(ct); got != want {
+			t.Errorf("isHTML(%q) = %v, want %v", ct, got, want)
+		}
+	}
+}
@@ -0,0 +1,265 @@
+package crawler
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"regexp"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// maxRobotsSize is how much of a robots.txt file is read; RFC 9309 lets
+// crawlers ignore anything after the first 500 KiB.
+const maxRobotsSize = 500 << 10
+
+// robotsRule is an Allow or Disallow line of a robots.txt file.
+type robotsRule struct {
+	allow   bool
+	pattern string
+	re      *regexp.Regexp
+}
+
+// robotsRules are the rules in a robots.txt file for one user agent.
+type robotsRules struct {
+	rules []robotsRule
+
+	// delay is the Crawl-delay, or 0 if none is given.
+	delay time.Duration
+}
+
+var (
+	// allowAll is used when a host has no robots.txt.
+	allowAll = &robotsRules{}
+
+	// disallowAll is used when a host's robots.txt can't be fetched
+	// because of a server or network error.
+	disallowAll = &robotsRules{rules: []robotsRule{newRobotsRule(false, "/")}}
+)
+
+func newRobotsRule(allow bool, pattern string) robotsRule {
+	// * matches any characters and a trailing $ anchors the end.
+	expr := strings.ReplaceAll(regexp.QuoteMeta(pattern), `\*`, ".*")
+	if strings.HasSuffix(expr, `\$`) {
+		expr = strings.TrimSuffix(expr, `\$`) + "$"
+	}
+	return robotsRule{allow: allow, pattern: pattern, re: regexp.MustCompile("^" + expr)}
+}
+
+// allowed reports whether the rules allow u to be fetched. The rule with
+// the longest pattern that matches u's path wins, and Allow wins a tie.
+func (r *robotsRules) allowed(u *url.URL) bool {
+	path := u.EscapedPath()
+	if path == "" {
+		path = "/"
+	}
+	if u.RawQuery != "" {
+		path += "?" + u.RawQuery
+	}
+
+	best, allow := -1, true
+	for _, rule := range r.rules {
+		if !rule.re.MatchString(path) {
+			continue
+		}
+		if n := len(rule.pattern); n > best || n == best && rule.allow {
+			best, allow = n, rule.allow
+		}
+	}
+	return allow
+}
+
+// robotsGroup is a group of lines in a robots.txt file, for the user
+// agents at its start.
+type robotsGroup struct {
+	agents []string
+	robotsRules
+}
+
+// parseRobots parses a robots.txt file and returns the rules for
+// userAgent. They are the rules of the groups naming its product token,
+// such as "examplebot" for "ExampleBot/1.0", or else those for *.
+func parseRobots(r io.Reader, userAgent string) *robotsRules {
+	var groups []*robotsGroup
+	var g *robotsGroup
+	inRules := false
+
+	sc := bufio.NewScanner(io.LimitReader(r, maxRobotsSize))
+	for sc.Scan() {
+		line := sc.Text()
+		if i := strings.IndexByte(line, '#'); i >= 0 {
+			line = line[:i]
+		}
+		key, value, ok := strings.Cut(line, ":")
+		if !ok {
+			continue
+		}
+		key, value = strings.ToLower(strings.TrimSpace(key)), strings.TrimSpace(value)
+
+		switch key {
+		case "user-agent":
+			// A user-agent line after rules starts a new group.
+			if g == nil || inRules {
+				g = &robotsGroup{}
+				groups = append(groups, g)
+				inRules = false
+			}
+			g.agents = append(g.agents, strings.ToLower(value))
+		case "allow", "disallow":
+			if g == nil {
+				continue
+			}
+			inRules = true
+			// An empty Disallow allows everything, as no rule does.
+			if value != "" {
+				g.rules = append(g.rules, newRobotsRule(key == "allow", value))
+			}
+		case "crawl-delay":
+			if g == nil {
+				continue
+			}
+			inRules = true
+			if secs, err := strconv.ParseFloat(value, 64); err == nil && secs > 0 {
+				g.delay = time.Duration(secs * float64(time.Second))
+			}
+		}
+	}
+
+	token := strings.ToLower(userAgent)
+	if i := strings.IndexAny(token, "/ "); i >= 0 {
+		token = token[:i]
+	}
+
+	for _, agent := range []string{token, "*"} {
+		var rules *robotsRules
+		for _, g := range groups {
+			for _, a := range g.agents {
+				if a != agent {
+					continue
+				}
+				// Groups for the same agent are merged.
+				if rules == nil {
+					rules = &robotsRules{}
+				}
+				rules.rules = append(rules.rules, g.rules...)
+				if g.delay > rules.delay {
+					rules.delay = g.delay
+				}
+				break
+			}
+		}
+		if rules != nil {
+			return rules
+		}
+	}
+	return allowAll
+}
+
+// robotsCache fetches and keeps the robots.txt rules for each host.
+type robotsCache struct {
+	client    *http.Client
+	userAgent string
+	debugf    func(format string, args ...interface{})
+
+	mu    sync.Mutex
+	hosts map[string]*robotsEntry
+}
+
+type robotsEntry struct {
+	// ready is closed once rules is set.
+	ready chan struct{}
+	rules *robotsRules
+}
+
+func newRobotsCache(client *http.Client, userAgent string, debugf func(string, ...interface{})) *robotsCache {
+	return &robotsCache{
+		client:    client,
+		userAgent: userAgent,
+		debugf:    debugf,
+		hosts:     make(map[string]*robotsEntry),
+	}
+}
+
+// rules returns the rules for u's host, fetching its robots.txt the first
+// time the host is seen. Callers asking for a host that is being fetched
+// wait for it, or until ctx is done, when nothing is allowed.
+func (c *robotsCache) rules(ctx context.Context, u *url.URL) *robotsRules {
+	origin := u.Scheme + "://" + u.Host
+
+	c.mu.Lock()
+	e, ok := c.hosts[origin]
+	if !ok {
+		e = &robotsEntry{ready: make(chan struct{})}
+		c.hosts[origin] = e
+	}
+	c.mu.Unlock()
+
+	if ok {
+		select {
+		case <-e.ready:
+			return e.rules
+		case <-ctx.Done():
+			return disallowAll
+		}
+	}
+
+	e.rules = c.fetch(ctx, origin)
+	close(e.ready)
+	return e.rules
+}
+
+// check reports whether link may be fetched, and the Crawl-delay for its
+// host.
+func (c *robotsCache) check(ctx context.Context, link string) (bool, time.Duration) {
+	u, err := url.Parse(link)
+	if err != nil {
+		return false, 0
+	}
+	rules := c.rules(ctx, u)
+	return rules.allowed(u), rules.delay
+}
+
+func (c *robotsCache) fetch(ctx context.Context, origin string) *robotsRules {
+	robotsURL := origin + "/robots.txt"
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, robotsURL, nil)
+	if err != nil {
+		c.debugf("robots: %v", err)
+		return disallowAll
+	}
+	req.Header.Set("User-Agent", c.userAgent)
+
+	resp, err := c.client.Do(req)
+	if err != nil {
+		c.debugf("robots: %v; not crawling %s", err, origin)
+		return disallowAll
+	}
+	defer resp.Body.Close()
+
+	// RFC 9309: a missing file allows everything, and an unreachable one
+	// disallows everything.
+	switch {
+	case resp.StatusCode >= 200 && resp.StatusCode < 300:
+		rules := parseRobots(resp.Body, c.userAgent)
+		c.debugf("robots: %s has %d rules for us%s", robotsURL, len(rules.rules), delayNote(rules))
+		return rules
+	case resp.StatusCode >= 400 && resp.StatusCode < 500:
+		c.debugf("robots: %s: %s; allowing everything", robotsURL, resp.Status)
+		return allowAll
+	default:
+		c.debugf("robots: %s: %s; not crawling %s", robotsURL, resp.Status, origin)
+		return disallowAll
+	}
+}
+
+func delayNote(r *robotsRules) string {
+	if r.delay == 0 {
+		return ""
+	}
+	return fmt.Sprintf(" and a crawl delay of %v", r.delay)
+}
@@ -0,0 +1,145 @@
+package crawler
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"go-concurrency-exercises/pkg/leakcheck"
+)
+
+const robotsTxt = `
+# Everyone else
+User-agent: *
+Disallow: /private/
+Allow: /private/public.html
+Disallow: /*.pdf$
+
+User-agent: OtherBot
+User-agent: GoConcurrencyCrawler
+Disallow: /tmp
+Crawl-delay: 1.5
+
+user-agent: goconcurrencycrawler   # groups for one agent are merged
+Disallow: /search?
+`
+
+func TestParseRobots(t *testing.T) {
+	tests := []struct {
+		agent, path string
+		allowed     bool
+	}{
+		{"SomeBot/2.0", "/", true},
+		{"SomeBot/2.0", "/private/x", false},
+		{"SomeBot/2.0", "/private/public.html", true},
+		{"SomeBot/2.0", "/docs/a.pdf", false},
+		{"SomeBot/2.0", "/docs/a.pdf?download=1", true},
+		{"SomeBot/2.0", "/tmp/x", true},
+		{"GoConcurrencyCrawler/1.0", "/private/x", true},
+		{"GoConcurrencyCrawler/1.0", "/tmp/x", false},
+		{"GoConcurrencyCrawler/1.0", "/tmpfile", false},
+		{"GoConcurrencyCrawler/1.0", "/search?q=go", false},
+		{"GoConcurrencyCrawler/1.0", "/search", true},
+	}
+	for _, tt := range tests {
+		rules := parseRobots(strings.NewReader(robotsTxt), tt.agent)
+		u, _ := url.Parse("http://example.com" + tt.path)
+		if got := rules.allowed(u); got != tt.allowed {
+			t.Errorf("%s fetching %s: got allowed %v, want %v", tt.agent, tt.path, got, tt.allowed)
+		}
+	}
+
+	if d := parseRobots(strings.NewReader(robotsTxt), "GoConcurrencyCrawler").delay; d != 1500*time.Millisecond {
+		t.Errorf("got crawl delay %v, want 1.5s", d)
+	}
+	if d := parseRobots(strings.NewReader(robotsTxt), "SomeBot").delay; d != 0 {
+		t.Errorf("got crawl delay %v for *, want none", d)
+	}
+}
+
+func TestRobotsPrecedence(t *testing.T) {
+	rules := parseRobots(strings.NewReader("User-agent: *\nDisallow: /a\nAllow: /a\nDisallow: /b/\nAllow: /b/c\nDisallow:\n"), "x")
+
+	for path, want := range map[string]bool{
+		"/a":   true, // Allow wins a tie.
+		"/b/x": false,
+		"/b/c": true, // The longer pattern wins.
+		"/z":   true,
+	} {
+		u, _ := url.Parse("http://example.com" + path)
+		if got := rules.allowed(u); got != want {
+			t.Errorf("%s: got allowed %v, want %v", path, got, want)
+		}
+	}
+}
+
+func TestRobotsCache(t *testing.T) {
+	leakcheck.Check(t)
+
+	var (
+		fetches int32
+		agents  sync.Map
+	)
+	status := map[string]int{"/a": http.StatusOK, "/b": http.StatusNotFound, "/c": http.StatusServiceUnavailable}
+	// Each test server is one host; the path prefix picks its behaviour.
+	newHost := func(kind string) *httptest.Server {
+		srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if r.URL.Path != "/robots.txt" {
+				t.Errorf("got request for %s", r.URL)
+			}
+			atomic.AddInt32(&fetches, 1)
+			agents.Store(r.UserAgent(), true)
+			w.WriteHeader(status[kind])
+			if status[kind] == http.StatusOK {
+				w.Write([]byte("User-agent: *\nDisallow: /no\n"))
+			}
+		}))
+		t.Cleanup(srv.Close)
+		return srv
+	}
+	ok, missing, failing := newHost("/a"), newHost("/b"), newHost("/c")
+
+	c := newRobotsCache(http.DefaultClient, "TestBot/1.0", t.Logf)
+	t.Cleanup(http.DefaultClient.CloseIdleConnections)
+
+	// Ask for the same host at once; it is fetched once.
+	var wg sync.WaitGroup
+	for i := 0; i < 10; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if allowed, _ := c.check(context.Background(), ok.URL+"/yes"); !allowed {
+				t.Error("/yes was disallowed")
+			}
+		}()
+	}
+	wg.Wait()
+
+	tests := []struct {
+		link    string
+		allowed bool
+	}{
+		{ok.URL + "/no/x", false},
+		{missing.URL + "/no/x", true},
+		{failing.URL + "/", false},
+		{"http://127.0.0.1:1/", false},
+	}
+	for _, tt := range tests {
+		if got, _ := c.check(context.Background(), tt.link); got != tt.allowed {
+			t.Errorf("%s: got allowed %v, want %v", tt.link, got, tt.allowed)
+		}
+	}
+
+	if n := atomic.LoadInt32(&fetches); n != 3 {
+		t.Errorf("fetched robots.txt %d times, want once per host", n)
+	}
+	if _, ok := agents.Load("TestBot/1.0"); !ok {
+		t.Error("robots.txt was not fetched with the user agent")
+	}
+}
@@ -0,0 +1,180 @@
+package crawler
+
+import (
+	"net/url"
+	"time"
+)
+
+// job is a page waiting to be fetched.
+type job struct {
+	url string
+
+	// depth is the number of links followed from a seed to the page.
+	depth int
+
+	// check is set for a url that is only checked, not crawled.
+	check bool
+
+	// kind is what the url was first found as.
+	kind Kind
+}
+
+// host is the queue of pages waiting to be fetched from one host.
+type host struct {
+	name   string
+	queue  []job
+	active int
+
+	// lastStart is when the last request to the host started, and delay
+	// the least time until the next may start.
+	lastStart time.Time
+	delay     time.Duration
+
+	// known is set once the host's Crawl-delay is known. Until then only
+	// one request to the host is made at a time.
+	known bool
+}
+
+// scheduler decides which page to fetch next, keeping to a global limit
+// on requests in flight, a limit per host, and a delay between requests to
+// one host. Hosts with pages waiting take turns, so a host with many pages
+// doesn't hold up the others. It is not safe for concurrent use; the crawl
+// loop owns it.
+type scheduler struct {
+	maxActive, maxPerHost int
+	minDelay              time.Duration
+
+	// delayKnown is whether hosts' delays are known from the start,
+	// because robots.txt is not being read.
+	delayKnown bool
+
+	active int
+	queued int
+	hosts  map[string]*host
+
+	// ring holds the hosts with pages waiting, in turn order, and cursor
+	// is the index of the host whose turn is next.
+	ring   []*host
+	cursor int
+}
+
+// newScheduler returns a scheduler with the given limits. If delayKnown
+// is false, each host is limited to one request at a time until done has
+// passed on its Crawl-delay.
+func newScheduler(maxActive, maxPerHost int, minDelay time.Duration, delayKnown bool) *scheduler {
+	if maxActive < 1 {
+		maxActive = 1
+	}
+	if maxPerHost < 1 {
+		maxPerHost = 1
+	}
+	return &scheduler{
+		maxActive:  maxActive,
+		maxPerHost: maxPerHost,
+		minDelay:   minDelay,
+		delayKnown: delayKnown,
+		hosts:      make(map[string]*host),
+	}
+}
+
+// add queues j to be fetched.
+func (s *scheduler) add(j job) {
+	name := hostOf(j.url)
+	h, ok := s.hosts[name]
+	if !ok {
+		h = &host{name: name, delay: s.minDelay, known: s.delayKnown}
+		s.hosts[name] = h
+	}
+
+	if len(h.queue) == 0 {
+		// The host joins the back of the turn order, just before the
+		// host whose turn is next.
+		s.ring = append(s.ring, nil)
+		copy(s.ring[s.cursor+1:], s.ring[s.cursor:])
+		s.ring[s.cursor] = h
+		s.cursor = (s.cursor + 1) % len(s.ring)
+	}
+	h.queue = append(h.queue, j)
+	s.queued++
+}
+
+// next returns a job that may start at now, and marks it started. If
+// none may, it returns false and how long until one may, or 0 if none
+// may until a job is done.
+func (s *scheduler) next(now time.Time) (job, bool, time.Duration) {
+	if s.active >= s.maxActive {
+		return job{}, false, 0
+	}
+
+	var wait time.Duration
+	for i := 0; i < len(s.ring); i++ {
+		idx := (s.cursor + i) % len(s.ring)
+		h := s.ring[idx]
+
+		limit := s.maxPerHost
+		if !h.known {
+			limit = 1
+		}
+		if h.active >= limit {
+			continue
+		}
+		if ready := h.lastStart.Add(h.delay); now.Before(ready) {
+			if d := ready.Sub(now); wait == 0 || d < wait {
+				wait = d
+			}
+			continue
+		}
+
+		j := h.queue[0]
+		h.queue = h.queue[1:]
+		h.active++
+		h.lastStart = now
+		s.active++
+		s.queued--
+
+		// The next turn goes to the host after this one.
+		if len(h.queue) == 0 {
+			s.ring = append(s.ring[:idx], s.ring[idx+1:]...)
+		} else {
+			idx++
+		}
+		if len(s.ring) > 0 {
+			s.cursor = idx % len(s.ring)
+		} else {
+			s.cursor = 0
+		}
+		return j, true, 0
+	}
+	return job{}, false, wait
+}
+
+// done marks a job from link's host as finished. If robots is set, the
+// job read the host's robots.txt, and delay is its Crawl-delay; the host
+// then waits the longer of it and the scheduler's least delay between
+// requests. A job that didn't read robots.txt leaves the delay unknown.
+func (s *scheduler) done(link string, delay time.Duration, robots bool) {
+	h := s.hosts[hostOf(link)]
+	h.active--
+	s.active--
+
+	if !s.delayKnown && robots {
+		h.known = true
+		if delay > s.minDelay {
+			h.delay = delay
+		}
+	}
+}
+
+// pending returns the number of jobs queued or started and not done.
+func (s *scheduler) pending() int {
+	return s.queued + s.active
+}
+
+// hostOf returns the host, with any port, that link is fetched from.
+func hostOf(link string) string {
+	u, err := url.Parse(link)
+	if err != nil {
+		return ""
+	}
+	return u.Host
+}
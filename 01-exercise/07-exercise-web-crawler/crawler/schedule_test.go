@@ -0,0 +1,121 @@
+package crawler
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+	"time"
+)
+
+func queue(s *scheduler, host string, n int) {
+	for i := 1; i <= n; i++ {
+		s.add(job{url: fmt.Sprintf("http://%s/%d", host, i)})
+	}
+}
+
+func TestSchedulerGlobalLimit(t *testing.T) {
+	s := newScheduler(2, 10, 0, true)
+	now := time.Now()
+	queue(s, "a", 2)
+	queue(s, "b", 2)
+	queue(s, "c", 2)
+
+	for i := 0; i < 2; i++ {
+		if _, ok, _ := s.next(now); !ok {
+			t.Fatalf("job %d didn't start", i+1)
+		}
+	}
+	j, ok, wait := s.next(now)
+	if ok || wait != 0 {
+		t.Fatalf("got %v, %v, %v over the limit, want to wait for a job to be done", j, ok, wait)
+	}
+
+	s.done("http://a/1", 0, true)
+	if _, ok, _ := s.next(now); !ok {
+		t.Error("no job started after one was done")
+	}
+	if got := s.pending(); got != 5 {
+		t.Errorf("got %d pending, want 5", got)
+	}
+}
+
+func TestSchedulerHostLimitAndDelay(t *testing.T) {
+	s := newScheduler(10, 2, time.Second, true)
+	start := time.Now()
+	queue(s, "a", 4)
+
+	if _, ok, _ := s.next(start); !ok {
+		t.Fatal("first job didn't start")
+	}
+	if _, ok, wait := s.next(start.Add(300 * time.Millisecond)); ok || wait != 700*time.Millisecond {
+		t.Errorf("got %v, %v before the delay, want to wait 700ms", ok, wait)
+	}
+	if _, ok, _ := s.next(start.Add(time.Second)); !ok {
+		t.Error("second job didn't start after the delay")
+	}
+	// Two are in flight, the most for one host.
+	if _, ok, wait := s.next(start.Add(5 * time.Second)); ok || wait != 0 {
+		t.Errorf("got %v, %v with the host busy, want to wait for a job to be done", ok, wait)
+	}
+}
+
+func TestSchedulerCrawlDelay(t *testing.T) {
+	s := newScheduler(10, 3, 100*time.Millisecond, false)
+	start := time.Now()
+	queue(s, "a", 3)
+
+	s.next(start)
+	// Until the Crawl-delay is known, one request at a time.
+	if _, ok, wait := s.next(start.Add(time.Second)); ok || wait != 0 {
+		t.Errorf("got %v, %v before the Crawl-delay is known, want to wait", ok, wait)
+	}
+
+	s.done("http://a/1", 2*time.Second, true)
+	if _, ok, wait := s.next(start.Add(time.Second)); ok || wait != time.Second {
+		t.Errorf("got %v, %v, want to wait out the 2s Crawl-delay", ok, wait)
+	}
+	if _, ok, _ := s.next(start.Add(2 * time.Second)); !ok {
+		t.Error("job didn't start after the Crawl-delay")
+	}
+}
+
+func TestSchedulerFairness(t *testing.T) {
+	s := newScheduler(100, 100, 0, true)
+	now := time.Now()
+	queue(s, "a", 5)
+	queue(s, "b", 2)
+	queue(s, "c", 1)
+
+	var order []string
+	for {
+		j, ok, _ := s.next(now)
+		if !ok {
+			break
+		}
+		order = append(order, strings.TrimPrefix(j.url, "http://"))
+	}
+
+	want := "a/1 b/1 c/1 a/2 b/2 a/3 a/4 a/5"
+	if got := strings.Join(order, " "); got != want {
+		t.Errorf("got order %s, want %s", got, want)
+	}
+
+	// A host that joins while others are waiting goes to the back of the
+	// turn order.
+	s = newScheduler(100, 100, 0, true)
+	queue(s, "a", 2)
+	queue(s, "b", 2)
+	s.next(now) // a/1
+	queue(s, "c", 1)
+	order = nil
+	for {
+		j, ok, _ := s.next(now)
+		if !ok {
+			break
+		}
+		order = append(order, strings.TrimPrefix(j.url, "http://"))
+	}
+	if got, want := strings.Join(order, " "), "b/1 a/2 c/1 b/2"; got != want {
+		t.Errorf("got order %s, want %s", got, want)
+	}
+}
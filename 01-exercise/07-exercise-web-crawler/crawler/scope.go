@@ -0,0 +1,196 @@
+package crawler
+
+import (
+	"fmt"
+	"net"
+	"net/url"
+	"regexp"
+	"sort"
+	"strings"
+
+	"golang.org/x/net/publicsuffix"
+)
+
+// ScopeMode is the rule for which hosts and paths a crawl may follow
+// links to, relative to the seeds it starts from.
+type ScopeMode string
+
+const (
+	// ScopeAny follows links anywhere. It is the default.
+	ScopeAny ScopeMode = "any"
+
+	// ScopeHost follows links on a seed's host.
+	ScopeHost ScopeMode = "host"
+
+	// ScopeDomain follows links on a seed's registrable domain, such as
+	// example.co.uk, including its subdomains.
+	ScopeDomain ScopeMode = "domain"
+
+	// ScopePrefix follows links that start with a URL prefix.
+	ScopePrefix ScopeMode = "prefix"
+)
+
+// Set implements flag.Value.
+func (m *ScopeMode) Set(s string) error {
+	switch mode := ScopeMode(s); mode {
+	case ScopeAny, ScopeHost, ScopeDomain, ScopePrefix:
+		*m = mode
+		return nil
+	}
+	return fmt.Errorf("unknown scope %q: want any, host, domain or prefix", s)
+}
+
+func (m *ScopeMode) String() string {
+	return string(*m)
+}
+
+// Regexps is a list of regular expressions that can be given as a flag
+// more than once.
+type Regexps []*regexp.Regexp
+
+// Set implements flag.Value.
+func (rs *Regexps) Set(s string) error {
+	re, err := regexp.Compile(s)
+	if err != nil {
+		return err
+	}
+	*rs = append(*rs, re)
+	return nil
+}
+
+func (rs *Regexps) String() string {
+	var ss []string
+	for _, re := range *rs {
+		ss = append(ss, re.String())
+	}
+	return strings.Join(ss, " ")
+}
+
+// Scope says which URLs a crawl may follow. A URL is in scope if it
+// passes the mode's rule for one of the seeds, matches one of Allow if any
+// are given, and matches none of Deny. Seeds are always fetched.
+type Scope struct {
+	Mode ScopeMode
+
+	// Prefix is the URL prefix for ScopePrefix. If empty, each seed is a
+	// prefix.
+	Prefix string
+
+	Allow, Deny Regexps
+}
+
+// boundScope is a scope tied to the seeds a crawl starts from.
+type boundScope struct {
+	*Scope
+	hosts, domains map[string]bool
+	prefixes       []string
+}
+
+// bind ties s to the normalised seeds a crawl starts from.
+func (s *Scope) bind(seeds []string) (*boundScope, error) {
+	b := &boundScope{Scope: s, hosts: make(map[string]bool), domains: make(map[string]bool)}
+	for _, seed := range seeds {
+		u, err := url.Parse(seed)
+		if err != nil {
+			return nil, err
+		}
+		b.hosts[u.Host] = true
+		b.domains[domain(u.Hostname())] = true
+		b.prefixes = append(b.prefixes, seed)
+	}
+
+	if s.Prefix != "" {
+		prefix, ok := normalizeString(s.Prefix, false)
+		if !ok {
+			return nil, fmt.Errorf("bad scope prefix %q", s.Prefix)
+		}
+		b.prefixes = []string{prefix}
+	}
+	return b, nil
+}
+
+// check reports whether link is in scope and, if it isn't, why not.
+func (b *boundScope) check(link string) (bool, string) {
+	u, err := url.Parse(link)
+	if err != nil {
+		return false, err.Error()
+	}
+
+	switch b.Mode {
+	case ScopeHost:
+		if !b.hosts[u.Host] {
+			return false, fmt.Sprintf("host %s is not %s", u.Host, oneOf(b.hosts))
+		}
+	case ScopeDomain:
+		if d := domain(u.Hostname()); !b.domains[d] {
+			return false, fmt.Sprintf("domain %s is not %s", d, oneOf(b.domains))
+		}
+	case ScopePrefix:
+		under := false
+		for _, p := range b.prefixes {
+			if underPrefix(link, p) {
+				under = true
+				break
+			}
+		}
+		if !under {
+			return false, fmt.Sprintf("not under %s", strings.Join(b.prefixes, " or "))
+		}
+	}
+
+	for _, re := range b.Deny {
+		if re.MatchString(link) {
+			return false, fmt.Sprintf("matches deny pattern %s", re)
+		}
+	}
+	if len(b.Allow) == 0 {
+		return true, ""
+	}
+	for _, re := range b.Allow {
+		if re.MatchString(link) {
+			return true, ""
+		}
+	}
+	return false, "matches no allow pattern"
+}
+
+// underPrefix reports whether link is prefix or under it. The prefix must
+// end on a path segment, so https://host/docs covers https://host/docs/a
+// and https://host/docs?page=2 but not https://host/docs-old/ or
+// https://host/docsearch.
+func underPrefix(link, prefix string) bool {
+	if !strings.HasPrefix(link, prefix) {
+		return false
+	}
+	if len(link) == len(prefix) || strings.HasSuffix(prefix, "/") {
+		return true
+	}
+	switch link[len(prefix)] {
+	case '/', '?', '#':
+		return true
+	}
+	return false
+}
+
+func oneOf(set map[string]bool) string {
+	var ss []string
+	for s := range set {
+		ss = append(ss, s)
+	}
+	sort.Strings(ss)
+	return strings.Join(ss, " or ")
+}
+
+// domain returns the registrable domain of host, such as example.co.uk
+// for www.example.co.uk. Hosts that have none, such as IP addresses and
+// localhost, are their own domain.
+func domain(host string) string {
+	if net.ParseIP(host) != nil {
+		return host
+	}
+	d, err := publicsuffix.EffectiveTLDPlusOne(host)
+	if err != nil {
+		return host
+	}
+	return d
+}
@@ -0,0 +1,99 @@
+package crawler
+
+import (
+	"regexp"
+	"testing"
+)
+
+func TestScope(t *testing.T) {
+	const start = "https://docs.example.co.uk/guide/"
+
+	tests := []struct {
+		scope Scope
+		link  string
+		want  bool
+	}{
+		{Scope{Mode: ScopeAny}, "http://elsewhere.org/", true},
+		{Scope{Mode: ScopeHost}, "https://docs.example.co.uk/other", true},
+		{Scope{Mode: ScopeHost}, "https://www.example.co.uk/", false},
+		{Scope{Mode: ScopeDomain}, "http://www.example.co.uk/", true},
+		{Scope{Mode: ScopeDomain}, "https://other.co.uk/", false},
+		{Scope{Mode: ScopePrefix}, "https://docs.example.co.uk/guide/intro", true},
+		{Scope{Mode: ScopePrefix}, "https://docs.example.co.uk/blog/", false},
+		{Scope{Mode: ScopePrefix, Prefix: "https://docs.example.co.uk/blog"}, "https://docs.example.co.uk/blog/1", true},
+		{Scope{Mode: ScopePrefix, Prefix: "https://docs.example.co.uk/blog"}, "https://docs.example.co.uk/blog?page=2", true},
+		{Scope{Mode: ScopePrefix, Prefix: "https://docs.example.co.uk/docs"}, "https://docs.example.co.uk/docs", true},
+		{Scope{Mode: ScopePrefix, Prefix: "https://docs.example.co.uk/docs"}, "https://docs.example.co.uk/docs-old/a", false},
+		{Scope{Mode: ScopePrefix, Prefix: "https://docs.example.co.uk/docs"}, "https://docs.example.co.uk/docsearch", false},
+		{Scope{Mode: ScopeAny, Deny: Regexps{regexp.MustCompile(`\.pdf$`)}}, "https://docs.example.co.uk/a.pdf", false},
+		{Scope{Mode: ScopeAny, Allow: Regexps{regexp.MustCompile(`/guide/`)}}, "https://docs.example.co.uk/guide/a", true},
+		{Scope{Mode: ScopeAny, Allow: Regexps{regexp.MustCompile(`/guide/`)}}, "https://docs.example.co.uk/blog/a", false},
+		{Scope{
+			Mode:  ScopeHost,
+			Allow: Regexps{regexp.MustCompile(`/guide/`)},
+			Deny:  Regexps{regexp.MustCompile(`/guide/old/`)},
+		}, "https://docs.example.co.uk/guide/old/a", false},
+	}
+	for _, tt := range tests {
+		b, err := tt.scope.bind([]string{start})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got, why := b.check(tt.link); got != tt.want {
+			t.Errorf("%s scope, allow %v, deny %v: check(%s) = %v (%s), want %v",
+				tt.scope.Mode, &tt.scope.Allow, &tt.scope.Deny, tt.link, got, why, tt.want)
+		}
+	}
+}
+
+func TestScopeModeFlag(t *testing.T) {
+	var m ScopeMode
+	if err := m.Set("domain"); err != nil || m != ScopeDomain {
+		t.Errorf("Set(domain) gave %v, %v", m, err)
+	}
+	if err := m.Set("planet"); err == nil {
+		t.Error("Set(planet) gave no error")
+	}
+}
+
+func TestDomain(t *testing.T) {
+	for host, want := range map[string]string{
+		"www.example.com":   "example.com",
+		"a.b.example.co.uk": "example.co.uk",
+		"127.0.0.1":         "127.0.0.1",
+		"localhost":         "localhost",
+	} {
+		if got := domain(host); got != want {
+			t.Errorf("domain(%s) = %s, want %s", host, got, want)
+		}
+	}
+}
+
+func TestScopeSeeds(t *testing.T) {
+	s := &Scope{Mode: ScopeHost}
+	b, err := s.bind([]string{"https://a.example.com/", "https://b.example.com/x/"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	for link, want := range map[string]bool{
+		"https://a.example.com/1": true,
+		"https://b.example.com/2": true,
+		"https://c.example.com/3": false,
+	} {
+		if got, why := b.check(link); got != want {
+			t.Errorf("check(%s) = %v (%s), want %v", link, got, why, want)
+		}
+	}
+
+	s = &Scope{Mode: ScopePrefix}
+	b, _ = s.bind([]string{"https://a.example.com/docs/", "https://b.example.com/x/"})
+	for link, want := range map[string]bool{
+		"https://a.example.com/docs/1": true,
+		"https://b.example.com/x/2":    true,
+		"https://a.example.com/x/2":    false,
+	} {
+		if got, why := b.check(link); got != want {
+			t.Errorf("prefix check(%s) = %v (%s), want %v", link, got, why, want)
+		}
+	}
+}
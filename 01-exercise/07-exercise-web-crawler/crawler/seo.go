@@ -0,0 +1,179 @@
+package crawler
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+)
+
+// The issues an SEOReport finds, in the order it reports them.
+const (
+	IssueNoTitle              = "no title"
+	IssueDuplicateTitle       = "duplicate title"
+	IssueNoDescription        = "no description"
+	IssueDuplicateDescription = "duplicate description"
+	IssueNoIndex              = "noindex"
+)
+
+var issueOrder = []string{IssueNoTitle, IssueDuplicateTitle, IssueNoDescription, IssueDuplicateDescription, IssueNoIndex}
+
+// SEOPage is a page in an SEOReport.
+type SEOPage struct {
+	URL    string   `json:"url"`
+	Meta   *Meta    `json:"meta"`
+	Issues []string `json:"issues,omitempty"`
+}
+
+// SEOIssue is an issue and the pages that have it.
+type SEOIssue struct {
+	Issue string `json:"issue"`
+
+	// Value is the title or description the pages share, for the
+	// duplicates.
+	Value string   `json:"value,omitempty"`
+	URLs  []string `json:"urls"`
+}
+
+// SEOReport gathers the metadata of the pages of a crawl, and reports
+// pages with no title or description, titles and descriptions shared by
+// more than one page, and pages that ask not to be indexed.
+type SEOReport struct {
+	pages map[string]*Meta
+}
+
+// NewSEOReport returns an empty SEOReport.
+func NewSEOReport() *SEOReport {
+	return &SEOReport{pages: make(map[string]*Meta)}
+}
+
+// Add records p, if it is a page that was crawled and its metadata read.
+// A page that redirected is recorded under the URL it ended up at.
+func (r *SEOReport) Add(p Page) {
+	if p.Err != nil || p.Meta == nil || p.Checked || p.Kind.Asset() {
+		return
+	}
+	u := p.URL
+	if len(p.Redirects) > 0 {
+		u = p.Redirects[len(p.Redirects)-1]
+	}
+	r.pages[u] = p.Meta
+}
+
+// Pages returns the pages recorded, sorted by URL, with their issues.
+func (r *SEOReport) Pages() []*SEOPage {
+	pages := []*SEOPage{}
+	byURL := make(map[string]*SEOPage)
+	for u, m := range r.pages {
+		p := &SEOPage{URL: u, Meta: m}
+		pages = append(pages, p)
+		byURL[u] = p
+	}
+	sort.Slice(pages, func(i, j int) bool { return pages[i].URL < pages[j].URL })
+
+	for _, issue := range r.Issues() {
+		for _, u := range issue.URLs {
+			byURL[u].Issues = append(byURL[u].Issues, issue.Issue)
+		}
+	}
+	return pages
+}
+
+// Issues returns the issues found, each with the pages that have it.
+func (r *SEOReport) Issues() []SEOIssue {
+	var urls []string
+	for u := range r.pages {
+		urls = append(urls, u)
+	}
+	sort.Strings(urls)
+
+	// Only pages that may be indexed, and are their own canonical page,
+	// should have titles and descriptions of their own; the others are
+	// expected to repeat them.
+	found := make(map[string][]string)
+	titles := make(map[string][]string)
+	descriptions := make(map[string][]string)
+	for _, u := range urls {
+		m := r.pages[u]
+		if m.NoIndex() {
+			found[IssueNoIndex] = append(found[IssueNoIndex], u)
+			continue
+		}
+		if m.Title == "" {
+			found[IssueNoTitle] = append(found[IssueNoTitle], u)
+		}
+		if m.Description == "" {
+			found[IssueNoDescription] = append(found[IssueNoDescription], u)
+		}
+		if m.Canonical != "" && m.Canonical != u {
+			continue
+		}
+		if m.Title != "" {
+			titles[m.Title] = append(titles[m.Title], u)
+		}
+		if m.Description != "" {
+			descriptions[m.Description] = append(descriptions[m.Description], u)
+		}
+	}
+
+	issues := []SEOIssue{}
+	for _, issue := range issueOrder {
+		switch issue {
+		case IssueDuplicateTitle:
+			issues = append(issues, duplicates(issue, titles)...)
+		case IssueDuplicateDescription:
+			issues = append(issues, duplicates(issue, descriptions)...)
+		default:
+			if len(found[issue]) > 0 {
+				issues = append(issues, SEOIssue{Issue: issue, URLs: found[issue]})
+			}
+		}
+	}
+	return issues
+}
+
+// duplicates returns an issue for each value in byValue that more than
+// one URL has, sorted by value.
+func duplicates(issue string, byValue map[string][]string) []SEOIssue {
+	var issues []SEOIssue
+	for v, urls := range byValue {
+		if len(urls) > 1 {
+			issues = append(issues, SEOIssue{Issue: issue, Value: v, URLs: urls})
+		}
+	}
+	sort.Slice(issues, func(i, j int) bool { return issues[i].Value < issues[j].Value })
+	return issues
+}
+
+// WriteText writes the issues to w, each with the pages that have it,
+// and a count of the pages recorded.
+func (r *SEOReport) WriteText(w io.Writer) error {
+	var b strings.Builder
+	withIssues := make(map[string]bool)
+	for _, issue := range r.Issues() {
+		if issue.Value != "" {
+			fmt.Fprintf(&b, "%s %q\n", issue.Issue, issue.Value)
+		} else {
+			fmt.Fprintf(&b, "%s\n", issue.Issue)
+		}
+		for _, u := range issue.URLs {
+			fmt.Fprintf(&b, "\t%s\n", u)
+			withIssues[u] = true
+		}
+	}
+	fmt.Fprintf(&b, "checked %d pages, %d with issues\n", len(r.pages), len(withIssues))
+	_, err := io.WriteString(w, b.String())
+	return err
+}
+
+// WriteJSON writes every page recorded, with its metadata and issues, and
+// the issues found, to w as JSON.
+func (r *SEOReport) WriteJSON(w io.Writer) error {
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	return enc.Encode(struct {
+		Pages  []*SEOPage `json:"pages"`
+		Issues []SEOIssue `json:"issues"`
+	}{r.Pages(), r.Issues()})
+}
@@ -0,0 +1,126 @@
+package crawler
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"reflect"
+	"testing"
+
+	"go-concurrency-exercises/pkg/leakcheck"
+)
+
+func TestRunMeta(t *testing.T) {
+	leakcheck.Check(t)
+
+	srv := newFixtureSite(t)
+	pages := collect(New(HostDelay(0), Depth(1), Assets(false)), srv.URL)
+
+	want := &Meta{
+		Title:       "Fixture site",
+		Description: "A site for testing the crawler.",
+		Canonical:   srv.URL + "/",
+		Lang:        "en",
+		Headings:    []Heading{{Level: 1, Text: "Fixture site"}, {Level: 2, Text: "Pages"}},
+		TextLength:  len("Fixture site Pages About Docs Install Missing Mail us"),
+	}
+	if got := pages[srv.URL+"/"].Meta; !reflect.DeepEqual(got, want) {
+		t.Errorf("got %+v, want %+v", got, want)
+	}
+	if text := pages[srv.URL+"/"].Text; text != "" {
+		t.Errorf("got text %q without KeepText", text)
+	}
+	pages = collect(New(HostDelay(0), Depth(0), KeepText()), srv.URL)
+	if text, want := pages[srv.URL+"/"].Text, "Fixture site Pages About Docs Install Missing Mail us"; text != want {
+		t.Errorf("got text %q, want %q", text, want)
+	}
+	for _, u := range []string{"/missing.html", "/style.css", "/logo.gif"} {
+		if m := pages[srv.URL+u].Meta; m != nil {
+			t.Errorf("%s: got %+v, want no Meta", u, m)
+		}
+	}
+}
+
+func TestSEOReport(t *testing.T) {
+	leakcheck.Check(t)
+
+	srv := newFixtureSite(t)
+	r := NewSEOReport()
+	for _, p := range collect(New(HostDelay(0), Depth(2)), srv.URL) {
+		r.Add(p)
+	}
+
+	want := "no title\n" +
+		"\t" + srv.URL + "/docs/\n" +
+		"duplicate description \"Guides to the fixture site.\"\n" +
+		"\t" + srv.URL + "/about.html\n" +
+		"\t" + srv.URL + "/docs/\n" +
+		"noindex\n" +
+		"\t" + srv.URL + "/docs/guide.html\n" +
+		"checked 4 pages, 3 with issues\n"
+	var buf bytes.Buffer
+	if err := r.WriteText(&buf); err != nil {
+		t.Fatal(err)
+	}
+	if buf.String() != want {
+		t.Errorf("got\n%s\nwant\n%s", buf.String(), want)
+	}
+
+	buf.Reset()
+	if err := r.WriteJSON(&buf); err != nil {
+		t.Fatal(err)
+	}
+	var report struct {
+		Pages  []SEOPage  `json:"pages"`
+		Issues []SEOIssue `json:"issues"`
+	}
+	if err := json.Unmarshal(buf.Bytes(), &report); err != nil {
+		t.Fatal(err)
+	}
+	if len(report.Pages) != 4 || len(report.Issues) != 3 {
+		t.Fatalf("got %d pages and %d issues, want 4 and 3", len(report.Pages), len(report.Issues))
+	}
+	if p := report.Pages[2]; p.URL != srv.URL+"/docs/" || !reflect.DeepEqual(p.Issues, []string{IssueNoTitle, IssueDuplicateDescription}) {
+		t.Errorf("got page %+v, want /docs/ with no title and a duplicate description", p)
+	}
+}
+
+func TestSEOReportPages(t *testing.T) {
+	r := NewSEOReport()
+	meta := func(title, description, canonical string) *Meta {
+		return &Meta{Title: title, Description: description, Canonical: canonical}
+	}
+	for _, p := range []Page{
+		{URL: "https://a/", Meta: meta("A", "Same", "")},
+		{URL: "https://a/b", Meta: meta("A", "Same", "https://a/b")},
+		// Pages that aren't their own canonical page, or can't be
+		// indexed, may repeat another's.
+		{URL: "https://a/print", Meta: meta("A", "Same", "https://a/")},
+		{URL: "https://a/draft", Meta: &Meta{Title: "A", Robots: []string{"noindex"}}},
+		// Redirects are recorded where they end up.
+		{URL: "http://a/c", Redirects: []string{"https://a/c"}, Meta: meta("", "", "")},
+		// Pages with nothing read aren't recorded.
+		{URL: "https://a/gone", Status: 404, Err: errors.New("404")},
+		{URL: "https://a/img.png", Kind: KindImage},
+		{URL: "https://elsewhere/", Checked: true},
+	} {
+		r.Add(p)
+	}
+
+	want := []SEOIssue{
+		{Issue: IssueNoTitle, URLs: []string{"https://a/c"}},
+		{Issue: IssueDuplicateTitle, Value: "A", URLs: []string{"https://a/", "https://a/b"}},
+		{Issue: IssueNoDescription, URLs: []string{"https://a/c"}},
+		{Issue: IssueDuplicateDescription, Value: "Same", URLs: []string{"https://a/", "https://a/b"}},
+		{Issue: IssueNoIndex, URLs: []string{"https://a/draft"}},
+	}
+	if got := r.Issues(); !reflect.DeepEqual(got, want) {
+		t.Errorf("got %+v, want %+v", got, want)
+	}
+	if got := len(r.Pages()); got != 5 {
+		t.Errorf("got %d pages, want 5", got)
+	}
+	if got := NewSEOReport().Issues(); got == nil || len(got) != 0 {
+		t.Errorf("got %#v for no pages, want an empty slice", got)
+	}
+}
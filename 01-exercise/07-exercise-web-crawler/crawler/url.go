@@ -0,0 +1,127 @@
+package crawler
+
+import (
+	"net/url"
+	"sort"
+	"strings"
+
+	"golang.org/x/net/html"
+)
+
+// defaultPorts are the ports that can be left out of a URL's host.
+var defaultPorts = map[string]string{
+	"http":  "80",
+	"https": "443",
+}
+
+// resolve resolves href, as found on a page, against the page's base URL
+// and normalises it, leaving the query alone. It reports false for links
+// that can't be crawled, such as mailto: and javascript: links.
+func resolve(base *url.URL, href string) (string, bool) {
+	return resolveSorted(base, href, false)
+}
+
+func resolveSorted(base *url.URL, href string, sortQuery bool) (string, bool) {
+	href = strings.TrimSpace(href)
+	if href == "" {
+		return "", false
+	}
+
+	u, err := base.Parse(href)
+	if err != nil {
+		return "", false
+	}
+	if _, ok := defaultPorts[strings.ToLower(u.Scheme)]; !ok {
+		return "", false
+	}
+	return normalize(u, sortQuery), true
+}
+
+// normalizeString parses and normalises an absolute URL, such as a seed
+// or a link a Fetcher returns.
+func normalizeString(raw string, sortQuery bool) (string, bool) {
+	return resolveSorted(new(url.URL), raw, sortQuery)
+}
+
+// normalize returns u in a canonical form, so the different ways of
+// writing one page's URL are fetched once: the scheme and host are lower
+// case, default ports and fragments are removed, an empty path becomes
+// "/", and with sortQuery the query parameters are sorted, so ?a=1&b=2
+// and ?b=2&a=1 count as the same page.
+func normalize(u *url.URL, sortQuery bool) string {
+	n := *u
+	n.Scheme = strings.ToLower(n.Scheme)
+	n.Host = strings.ToLower(n.Host)
+	n.Fragment = ""
+	n.RawFragment = ""
+	n.User = nil
+
+	if port := n.Port(); port != "" && port == defaultPorts[n.Scheme] {
+		n.Host = strings.TrimSuffix(n.Host, ":"+port)
+	}
+	if n.Path == "" {
+		n.Path = "/"
+		n.RawPath = ""
+	}
+	if sortQuery && n.RawQuery != "" {
+		n.RawQuery = sortedQuery(n.RawQuery)
+	}
+	// A bare "?" adds nothing.
+	n.ForceQuery = false
+
+	return n.String()
+}
+
+// sortedQuery sorts the parameters of a raw query by key, keeping the
+// order of repeated keys and their original encoding.
+func sortedQuery(raw string) string {
+	params := strings.Split(raw, "&")
+	sort.SliceStable(params, func(i, j int) bool {
+		return queryKey(params[i]) < queryKey(params[j])
+	})
+	return strings.Join(params, "&")
+}
+
+func queryKey(param string) string {
+	if i := strings.IndexByte(param, '='); i >= 0 {
+		param = param[:i]
+	}
+	key, err := url.QueryUnescape(param)
+	if err != nil {
+		return param
+	}
+	return key
+}
+
+// baseURL returns the URL that links in doc are relative to: the first
+// <base href> if there is one, resolved against the page's own URL, or
+// else the page's URL.
+func baseURL(page *url.URL, doc *html.Node) *url.URL {
+	var href string
+	var find func(n *html.Node) bool
+	find = func(n *html.Node) bool {
+		if n.Type == html.ElementNode && n.Data == "base" {
+			for _, a := range n.Attr {
+				if a.Key == "href" {
+					href = a.Val
+					return true
+				}
+			}
+		}
+		for c := n.FirstChild; c != nil; c = c.NextSibling {
+			if find(c) {
+				return true
+			}
+		}
+		return false
+	}
+
+	if !find(doc) {
+		return page
+	}
+	base, err := page.Parse(strings.TrimSpace(href))
+	if err != nil {
+		return page
+	}
+	return base
+}
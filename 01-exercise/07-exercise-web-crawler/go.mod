@@ -2,4 +2,9 @@ module 07-exercise-web-crawler
 
 go 1.18
 
-require golang.org/x/net v0.0.0-20220412020605-290c469a71a5
+require (
+	go-concurrency-exercises/pkg v0.0.0-00010101000000-000000000000
+	golang.org/x/net v0.0.0-20220412020605-290c469a71a5
+)
+
+replace go-concurrency-exercises/pkg => ../../pkg
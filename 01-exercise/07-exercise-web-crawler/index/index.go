@@ -0,0 +1,201 @@
+// Package index is a full-text index of crawled pages, for searching them
+// offline. Pages are tokenised concurrently into an inverted index, which
+// maps each term to its postings: the pages it is on, and where. The
+// index is split into shards by term, each with its own lock, so pages
+// can be added from many goroutines at once without all waiting on one
+// lock. It can be saved to and loaded from a directory, and searched with
+// AND, OR and phrase queries ranked by BM25.
+package index
+
+import (
+	"hash/fnv"
+	"runtime"
+	"strings"
+	"sync"
+	"unicode"
+)
+
+// DefaultShards is how many shards New splits an index into by default.
+const DefaultShards = 16
+
+// Document is a page to add to an index.
+type Document struct {
+	URL   string
+	Title string
+	Text  string
+}
+
+// Doc is a page in an index. Its ID is its position in the index's
+// docs.
+type Doc struct {
+	URL   string
+	Title string
+
+	// Length is the number of terms in the page's title and text.
+	Length int
+}
+
+// Posting is a page a term is on.
+type Posting struct {
+	Doc int
+
+	// Positions are where the term is in the page, counted in terms from
+	// the start of its title, in order. The term's frequency in the page
+	// is how many there are.
+	Positions []int
+}
+
+// TF is the term's frequency in the page.
+func (p Posting) TF() int {
+	return len(p.Positions)
+}
+
+// shard holds the postings of the terms that hash to it.
+type shard struct {
+	mu    sync.RWMutex
+	terms map[string][]Posting
+}
+
+// Index is an inverted index of pages. It is safe to add pages to, search
+// and save it from more than one goroutine at a time.
+type Index struct {
+	shards []*shard
+
+	// adding is held for reading by each Add, and for writing by Save,
+	// which waits for the pages being added and holds off any more, so
+	// the pages and shards it writes agree.
+	adding sync.RWMutex
+
+	mu     sync.RWMutex
+	docs   []Doc
+	length int
+}
+
+// New returns an empty index split into n shards, or DefaultShards if n
+// isn't positive.
+func New(n int) *Index {
+	if n <= 0 {
+		n = DefaultShards
+	}
+	ix := &Index{shards: make([]*shard, n)}
+	for i := range ix.shards {
+		ix.shards[i] = &shard{terms: make(map[string][]Posting)}
+	}
+	return ix
+}
+
+// shard returns the shard term is in.
+func (ix *Index) shard(term string) int {
+	h := fnv.New32a()
+	h.Write([]byte(term))
+	return int(h.Sum32() % uint32(len(ix.shards)))
+}
+
+// Add tokenises d and adds it to the index, and returns its ID.
+//
+// The page is tokenised and its terms grouped by shard before any lock is
+// taken. Then each shard it has terms in is locked in turn, once, to add
+// its postings, so two pages only wait on each other while they add terms
+// to the same shard.
+func (ix *Index) Add(d Document) int {
+	ix.adding.RLock()
+	defer ix.adding.RUnlock()
+
+	title := Tokenize(d.Title)
+	text := Tokenize(d.Text)
+
+	positions := make(map[string][]int)
+	for i, term := range title {
+		positions[term] = append(positions[term], i)
+	}
+	// A gap between the title and text keeps phrases from spanning them.
+	for i, term := range text {
+		positions[term] = append(positions[term], len(title)+1+i)
+	}
+
+	ix.mu.Lock()
+	id := len(ix.docs)
+	ix.docs = append(ix.docs, Doc{URL: d.URL, Title: d.Title, Length: len(title) + len(text)})
+	ix.length += len(title) + len(text)
+	ix.mu.Unlock()
+
+	byShard := make(map[int]map[string][]int)
+	for term, pos := range positions {
+		s := ix.shard(term)
+		if byShard[s] == nil {
+			byShard[s] = make(map[string][]int)
+		}
+		byShard[s][term] = pos
+	}
+	for s, terms := range byShard {
+		sh := ix.shards[s]
+		sh.mu.Lock()
+		for term, pos := range terms {
+			sh.terms[term] = append(sh.terms[term], Posting{Doc: id, Positions: pos})
+		}
+		sh.mu.Unlock()
+	}
+	return id
+}
+
+// AddAll adds the documents received from docs, tokenising them with
+// workers goroutines, or runtime.GOMAXPROCS(0) if workers isn't positive.
+// It returns once docs is closed and every document has been added.
+func (ix *Index) AddAll(docs <-chan Document, workers int) {
+	if workers <= 0 {
+		workers = runtime.GOMAXPROCS(0)
+	}
+	var wg sync.WaitGroup
+	for i := 0; i < workers; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for d := range docs {
+				ix.Add(d)
+			}
+		}()
+	}
+	wg.Wait()
+}
+
+// Len returns the number of pages in the index.
+func (ix *Index) Len() int {
+	ix.mu.RLock()
+	defer ix.mu.RUnlock()
+	return len(ix.docs)
+}
+
+// Doc returns the page with the given ID.
+func (ix *Index) Doc(id int) Doc {
+	ix.mu.RLock()
+	defer ix.mu.RUnlock()
+	return ix.docs[id]
+}
+
+// Postings returns the postings of term, which should be tokenised. The
+// slice is a copy, in no particular order.
+func (ix *Index) Postings(term string) []Posting {
+	sh := ix.shards[ix.shard(term)]
+	sh.mu.RLock()
+	defer sh.mu.RUnlock()
+	return append([]Posting(nil), sh.terms[term]...)
+}
+
+// stats returns the number of pages in the index and their average
+// length, for BM25.
+func (ix *Index) stats() (int, float64) {
+	ix.mu.RLock()
+	defer ix.mu.RUnlock()
+	if len(ix.docs) == 0 {
+		return 0, 0
+	}
+	return len(ix.docs), float64(ix.length) / float64(len(ix.docs))
+}
+
+// Tokenize splits text into terms: runs of letters and digits, in lower
+// case.
+func Tokenize(text string) []string {
+	return strings.FieldsFunc(strings.ToLower(text), func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsNumber(r)
+	})
+}
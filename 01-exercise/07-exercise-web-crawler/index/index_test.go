@@ -0,0 +1,66 @@
+package index
+
+import (
+	"fmt"
+	"reflect"
+	"testing"
+
+	"go-concurrency-exercises/pkg/leakcheck"
+)
+
+func TestTokenize(t *testing.T) {
+	got := Tokenize("Go's  go-routines, in Go 1.18 — Ünïcode!")
+	want := []string{"go", "s", "go", "routines", "in", "go", "1", "18", "ünïcode"}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("got %q, want %q", got, want)
+	}
+}
+
+func TestAdd(t *testing.T) {
+	ix := New(4)
+	id := ix.Add(Document{URL: "https://a/", Title: "Go Tour", Text: "A tour of Go. Go!"})
+
+	if got, want := ix.Doc(id), (Doc{URL: "https://a/", Title: "Go Tour", Length: 7}); got != want {
+		t.Errorf("got doc %+v, want %+v", got, want)
+	}
+	// The title is at 0 and 1, and the text starts at 3.
+	if got, want := ix.Postings("go"), []Posting{{Doc: id, Positions: []int{0, 6, 7}}}; !reflect.DeepEqual(got, want) {
+		t.Errorf("got postings %+v, want %+v", got, want)
+	}
+	if got := ix.Postings("go")[0].TF(); got != 3 {
+		t.Errorf("got tf %d, want 3", got)
+	}
+	if got := ix.Postings("missing"); len(got) != 0 {
+		t.Errorf("got postings %+v for a missing term", got)
+	}
+}
+
+func TestAddAll(t *testing.T) {
+	leakcheck.Check(t)
+
+	// Every page has the term "all", and its own number, so they land in
+	// every shard from many goroutines at once.
+	const n = 500
+	docs := make(chan Document)
+	go func() {
+		defer close(docs)
+		for i := 0; i < n; i++ {
+			docs <- Document{URL: fmt.Sprintf("https://a/%d", i), Text: fmt.Sprintf("all page %d", i)}
+		}
+	}()
+	ix := New(8)
+	ix.AddAll(docs, 8)
+
+	if ix.Len() != n {
+		t.Fatalf("got %d pages, want %d", ix.Len(), n)
+	}
+	if got := len(ix.Postings("all")); got != n {
+		t.Fatalf("got %d pages with \"all\", want %d", got, n)
+	}
+	for i := 0; i < n; i++ {
+		p := ix.Postings(fmt.Sprint(i))
+		if len(p) != 1 || ix.Doc(p[0].Doc).URL != fmt.Sprintf("https://a/%d", i) {
+			t.Fatalf("got postings %+v for %d", p, i)
+		}
+	}
+}
@@ -0,0 +1,371 @@
+package index
+
+import (
+	"fmt"
+	"math"
+	"sort"
+	"strings"
+)
+
+// BM25's parameters: k1 limits how much repeating a term raises a page's
+// score, and b how much a long page is marked down.
+const (
+	k1 = 1.2
+	b  = 0.75
+)
+
+// Result is a page that matched a query.
+type Result struct {
+	URL   string
+	Title string
+	Score float64
+}
+
+// Search returns the pages that match query, best first, and at most
+// limit of them if limit is positive.
+//
+// A query is terms and "quoted phrases". Terms next to each other must
+// all match, as if joined by AND, which may also be written; OR between
+// them matches either, and binds less tightly than AND, so a b OR c is (a
+// AND b) OR c. Parentheses group. Terms match whatever Tokenize makes of
+// them, so case is ignored, and a term such as go-lang is the phrase "go
+// lang". The pages that match are ranked by the BM25 scores of the terms
+// of the query, phrases included, that they have.
+func (ix *Index) Search(query string, limit int) ([]Result, error) {
+	n, err := parse(query)
+	if err != nil {
+		return nil, err
+	}
+
+	s := &searcher{ix: ix, postings: make(map[string]map[int]Posting)}
+	docs := n.match(s)
+
+	count, avgLen := ix.stats()
+	scores := make(map[int]float64, len(docs))
+	for _, term := range unique(n.terms(nil)) {
+		postings := s.get(term)
+		idf := math.Log(1 + (float64(count)-float64(len(postings))+0.5)/(float64(len(postings))+0.5))
+		for id := range docs {
+			p, ok := postings[id]
+			if !ok {
+				continue
+			}
+			tf := float64(p.TF())
+			length := float64(ix.Doc(id).Length)
+			scores[id] += idf * tf * (k1 + 1) / (tf + k1*(1-b+b*length/avgLen))
+		}
+	}
+
+	results := make([]Result, 0, len(docs))
+	for id := range docs {
+		d := ix.Doc(id)
+		results = append(results, Result{URL: d.URL, Title: d.Title, Score: scores[id]})
+	}
+	sort.Slice(results, func(i, j int) bool {
+		if results[i].Score != results[j].Score {
+			return results[i].Score > results[j].Score
+		}
+		return results[i].URL < results[j].URL
+	})
+	if limit > 0 && len(results) > limit {
+		results = results[:limit]
+	}
+	return results, nil
+}
+
+// searcher reads each term's postings from the index once per search.
+type searcher struct {
+	ix       *Index
+	postings map[string]map[int]Posting
+}
+
+// get returns the postings of term by page.
+func (s *searcher) get(term string) map[int]Posting {
+	if p, ok := s.postings[term]; ok {
+		return p
+	}
+	p := make(map[int]Posting)
+	for _, posting := range s.ix.Postings(term) {
+		p[posting.Doc] = posting
+	}
+	s.postings[term] = p
+	return p
+}
+
+// node is a parsed query, or part of one.
+type node interface {
+	// match returns the pages that match.
+	match(s *searcher) map[int]bool
+
+	// terms appends the terms in the node to terms, and returns the
+	// result.
+	terms(terms []string) []string
+}
+
+// termNode matches pages with the term.
+type termNode string
+
+func (n termNode) match(s *searcher) map[int]bool {
+	docs := make(map[int]bool)
+	for id := range s.get(string(n)) {
+		docs[id] = true
+	}
+	return docs
+}
+
+func (n termNode) terms(terms []string) []string {
+	return append(terms, string(n))
+}
+
+// phraseNode matches pages with its terms next to each other, in order.
+type phraseNode []string
+
+func (n phraseNode) match(s *searcher) map[int]bool {
+	docs := make(map[int]bool)
+	first := s.get(n[0])
+	for id, p := range first {
+		for _, start := range p.Positions {
+			if n.at(s, id, start) {
+				docs[id] = true
+				break
+			}
+		}
+	}
+	return docs
+}
+
+// at reports whether the phrase is in the page id, starting at start.
+func (n phraseNode) at(s *searcher, id, start int) bool {
+	for i, term := range n[1:] {
+		p, ok := s.get(term)[id]
+		if !ok {
+			return false
+		}
+		want := start + i + 1
+		if j := sort.SearchInts(p.Positions, want); j == len(p.Positions) || p.Positions[j] != want {
+			return false
+		}
+	}
+	return true
+}
+
+func (n phraseNode) terms(terms []string) []string {
+	return append(terms, n...)
+}
+
+// andNode matches pages that all its nodes match.
+type andNode []node
+
+func (n andNode) match(s *searcher) map[int]bool {
+	docs := n[0].match(s)
+	for _, c := range n[1:] {
+		if len(docs) == 0 {
+			break
+		}
+		other := c.match(s)
+		for id := range docs {
+			if !other[id] {
+				delete(docs, id)
+			}
+		}
+	}
+	return docs
+}
+
+func (n andNode) terms(terms []string) []string {
+	for _, c := range n {
+		terms = c.terms(terms)
+	}
+	return terms
+}
+
+// orNode matches pages that any of its nodes match.
+type orNode []node
+
+func (n orNode) match(s *searcher) map[int]bool {
+	docs := make(map[int]bool)
+	for _, c := range n {
+		for id := range c.match(s) {
+			docs[id] = true
+		}
+	}
+	return docs
+}
+
+func (n orNode) terms(terms []string) []string {
+	for _, c := range n {
+		terms = c.terms(terms)
+	}
+	return terms
+}
+
+// unique returns terms without repeats, in order.
+func unique(terms []string) []string {
+	seen := make(map[string]bool)
+	var out []string
+	for _, t := range terms {
+		if !seen[t] {
+			seen[t] = true
+			out = append(out, t)
+		}
+	}
+	return out
+}
+
+// token is a token of a query: "(", ")", "AND", "OR", a word, or a
+// phrase, which is quoted.
+type token struct {
+	text   string
+	phrase bool
+}
+
+// lex splits query into tokens.
+func lex(query string) ([]token, error) {
+	var tokens []token
+	for i := 0; i < len(query); {
+		switch c := query[i]; {
+		case c == ' ' || c == '\t' || c == '\n' || c == '\r':
+			i++
+		case c == '(' || c == ')':
+			tokens = append(tokens, token{text: string(c)})
+			i++
+		case c == '"':
+			end := strings.IndexByte(query[i+1:], '"')
+			if end < 0 {
+				return nil, fmt.Errorf("query %q: unterminated phrase", query)
+			}
+			tokens = append(tokens, token{text: query[i+1 : i+1+end], phrase: true})
+			i += end + 2
+		default:
+			end := strings.IndexAny(query[i:], " \t\n\r()\"")
+			if end < 0 {
+				end = len(query) - i
+			}
+			tokens = append(tokens, token{text: query[i : i+end]})
+			i += end
+		}
+	}
+	return tokens, nil
+}
+
+// parser parses a query:
+//
+//	or   = and { "OR" and }
+//	and  = unit { [ "AND" ] unit }
+//	unit = "(" or ")" | phrase | word
+type parser struct {
+	query  string
+	tokens []token
+}
+
+// parse parses query. Words and phrases with no terms, such as "-", are
+// left out; a query with nothing left is an error.
+func parse(query string) (node, error) {
+	tokens, err := lex(query)
+	if err != nil {
+		return nil, err
+	}
+	p := &parser{query: query, tokens: tokens}
+	n, err := p.or()
+	if err != nil {
+		return nil, err
+	}
+	if len(p.tokens) > 0 {
+		return nil, p.errorf("unexpected %q", p.tokens[0].text)
+	}
+	if n == nil {
+		return nil, p.errorf("no terms")
+	}
+	return n, nil
+}
+
+func (p *parser) errorf(format string, args ...interface{}) error {
+	return fmt.Errorf("query %q: %s", p.query, fmt.Sprintf(format, args...))
+}
+
+// peek reports whether the next token is the operator or bracket op.
+func (p *parser) peek(op string) bool {
+	return len(p.tokens) > 0 && !p.tokens[0].phrase && p.tokens[0].text == op
+}
+
+func (p *parser) or() (node, error) {
+	var n orNode
+	for {
+		c, err := p.and()
+		if err != nil {
+			return nil, err
+		}
+		if c != nil {
+			n = append(n, c)
+		}
+		if !p.peek("OR") {
+			break
+		}
+		p.tokens = p.tokens[1:]
+		if len(p.tokens) == 0 || p.peek(")") {
+			return nil, p.errorf("OR with nothing after it")
+		}
+	}
+	switch len(n) {
+	case 0:
+		return nil, nil
+	case 1:
+		return n[0], nil
+	}
+	return n, nil
+}
+
+func (p *parser) and() (node, error) {
+	if p.peek("OR") || p.peek("AND") {
+		return nil, p.errorf("%s with nothing before it", p.tokens[0].text)
+	}
+	var n andNode
+	for len(p.tokens) > 0 && !p.peek("OR") && !p.peek(")") {
+		if p.peek("AND") {
+			p.tokens = p.tokens[1:]
+			if len(p.tokens) == 0 || p.peek(")") || p.peek("OR") || p.peek("AND") {
+				return nil, p.errorf("AND with nothing after it")
+			}
+			continue
+		}
+		c, err := p.unit()
+		if err != nil {
+			return nil, err
+		}
+		if c != nil {
+			n = append(n, c)
+		}
+	}
+	switch len(n) {
+	case 0:
+		return nil, nil
+	case 1:
+		return n[0], nil
+	}
+	return n, nil
+}
+
+func (p *parser) unit() (node, error) {
+	t := p.tokens[0]
+	p.tokens = p.tokens[1:]
+	if t.phrase || t.text != "(" {
+		terms := Tokenize(t.text)
+		switch len(terms) {
+		case 0:
+			return nil, nil
+		case 1:
+			return termNode(terms[0]), nil
+		}
+		return phraseNode(terms), nil
+	}
+
+	n, err := p.or()
+	if err != nil {
+		return nil, err
+	}
+	if !p.peek(")") {
+		return nil, p.errorf("missing )")
+	}
+	p.tokens = p.tokens[1:]
+	return n, nil
+}
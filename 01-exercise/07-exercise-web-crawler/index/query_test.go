@@ -0,0 +1,124 @@
+package index
+
+import (
+	"math"
+	"reflect"
+	"sort"
+	"testing"
+)
+
+// docsIndex returns an index of a few small pages.
+func docsIndex() *Index {
+	ix := New(4)
+	for _, d := range []Document{
+		{URL: "https://a/go", Title: "Go", Text: "Go is a language. The go tool builds go programs."},
+		{URL: "https://a/rust", Title: "Rust", Text: "Rust is a language too."},
+		{URL: "https://a/tools", Title: "Tools", Text: "The tool go builds things. A language tool."},
+		{URL: "https://a/empty", Text: "Nothing here."},
+	} {
+		ix.Add(d)
+	}
+	return ix
+}
+
+func TestSearch(t *testing.T) {
+	ix := docsIndex()
+
+	tests := []struct {
+		query string
+		want  []string
+	}{
+		{"language", []string{"go", "rust", "tools"}},
+		{"LANGUAGE", []string{"go", "rust", "tools"}},
+		{"go tool", []string{"go", "tools"}},
+		{"go AND tool", []string{"go", "tools"}},
+		{`"go tool"`, []string{"go"}},
+		{`"tool go builds"`, []string{"tools"}},
+		{`"go tool builds go"`, []string{"go"}},
+		{`"builds go tool"`, nil},
+		{"go-tool", []string{"go"}},
+		{"rust OR nothing", []string{"empty", "rust"}},
+		{"go AND rust", nil},
+		{"go rust OR nothing", []string{"empty"}},
+		{"(go OR rust) language", []string{"go", "rust", "tools"}},
+		{"(go OR rust) (too OR programs)", []string{"go", "rust"}},
+		{`rust OR "go is"`, []string{"go", "rust"}},
+		// Words with no terms are left out.
+		{"language -", []string{"go", "rust", "tools"}},
+		// A page's title runs into its text only with a gap between.
+		{`"tools the"`, nil},
+		{"missing", nil},
+	}
+	for _, tt := range tests {
+		results, err := ix.Search(tt.query, 0)
+		if err != nil {
+			t.Errorf("Search(%s): %v", tt.query, err)
+			continue
+		}
+		var got []string
+		for _, r := range results {
+			got = append(got, r.URL[len("https://a/"):])
+		}
+		sort.Strings(got)
+		if !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("Search(%s) = %v, want %v", tt.query, got, tt.want)
+		}
+	}
+}
+
+func TestSearchErrors(t *testing.T) {
+	ix := docsIndex()
+	for _, query := range []string{"", "  ", "-", "()", "OR go", "go OR", "go OR OR rust", "AND go", "go AND", "go AND OR rust", "(go", "go)", "(go OR)", `"go`} {
+		if results, err := ix.Search(query, 0); err == nil {
+			t.Errorf("Search(%s) = %v, want an error", query, results)
+		}
+	}
+}
+
+func TestSearchRanking(t *testing.T) {
+	ix := docsIndex()
+
+	// The go page has "go" four times, the tools page once.
+	results, err := ix.Search("go", 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(results) != 2 || results[0].URL != "https://a/go" || results[0].Title != "Go" || results[0].Score <= results[1].Score {
+		t.Errorf("got %+v, want the go page first", results)
+	}
+
+	// A rare term is worth more than a common one.
+	results, err = ix.Search("too OR language", 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(results) != 3 || results[0].URL != "https://a/rust" {
+		t.Errorf("got %+v, want the rust page first", results)
+	}
+
+	results, err = ix.Search("language", 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(results) != 1 {
+		t.Errorf("got %d results, want the limit of 1", len(results))
+	}
+}
+
+func TestBM25(t *testing.T) {
+	ix := New(2)
+	ix.Add(Document{URL: "https://a/1", Text: "a b"})
+	ix.Add(Document{URL: "https://a/2", Text: "a a a c"})
+
+	// For b, on one page of two: idf = ln(1 + 1.5/1.5). The page has
+	// length 2, and the average is 3, so its score is
+	// idf * 1 * 2.2 / (1 + 1.2 * (0.25 + 0.75 * 2/3)).
+	results, err := ix.Search("b", 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := math.Log(2) * 2.2 / 1.9
+	if len(results) != 1 || math.Abs(results[0].Score-want) > 1e-12 {
+		t.Errorf("got %+v, want a score of %v", results, want)
+	}
+}
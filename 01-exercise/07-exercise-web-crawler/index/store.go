@@ -0,0 +1,193 @@
+package index
+
+import (
+	"encoding/gob"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"sync"
+)
+
+// version is the format of a saved index. Load refuses any other.
+const version = 2
+
+// header is the index file of a saved index, which names the directory its
+// shards were saved to.
+type header struct {
+	Version int
+	Shards  int
+	Dir     string
+	Docs    []Doc
+}
+
+const indexFile = "index.gob"
+
+// shardsPattern names the directories the shards of each save go in.
+const shardsPattern = "shards-*"
+
+func shardFile(i int) string {
+	return fmt.Sprintf("shard-%03d.gob", i)
+}
+
+// Save writes the index to dir, creating it if needed: each shard to a
+// file of its own, in a new directory in dir, then the pages and the name
+// of that directory to the index file. The shards are written at once,
+// each under its read lock, with its postings sorted by page. The index
+// file is written last, to a temporary file renamed into place, so a
+// failed save leaves the index that was saved before it whole. The
+// shards of earlier saves are then removed.
+//
+// Save waits for any pages being added to the index, and holds off adding
+// more until it returns, so the pages and postings it saves agree.
+func (ix *Index) Save(dir string) error {
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return err
+	}
+	shards, err := os.MkdirTemp(dir, shardsPattern)
+	if err != nil {
+		return err
+	}
+	if err := os.Chmod(shards, 0o755); err != nil {
+		os.RemoveAll(shards)
+		return err
+	}
+
+	ix.adding.Lock()
+	defer ix.adding.Unlock()
+
+	errs := make([]error, len(ix.shards))
+	var wg sync.WaitGroup
+	for i, sh := range ix.shards {
+		wg.Add(1)
+		go func(i int, sh *shard) {
+			defer wg.Done()
+
+			sh.mu.Lock()
+			for _, postings := range sh.terms {
+				sort.Slice(postings, func(a, b int) bool { return postings[a].Doc < postings[b].Doc })
+			}
+			sh.mu.Unlock()
+
+			sh.mu.RLock()
+			defer sh.mu.RUnlock()
+			errs[i] = writeFile(filepath.Join(shards, shardFile(i)), sh.terms)
+		}(i, sh)
+	}
+	wg.Wait()
+	for _, err := range errs {
+		if err != nil {
+			os.RemoveAll(shards)
+			return err
+		}
+	}
+
+	ix.mu.RLock()
+	h := header{Version: version, Shards: len(ix.shards), Dir: filepath.Base(shards), Docs: append([]Doc(nil), ix.docs...)}
+	ix.mu.RUnlock()
+	if err := writeFile(filepath.Join(dir, indexFile), h); err != nil {
+		os.RemoveAll(shards)
+		return err
+	}
+
+	old, err := filepath.Glob(filepath.Join(dir, shardsPattern))
+	if err != nil {
+		return err
+	}
+	for _, d := range old {
+		if d != shards {
+			if err := os.RemoveAll(d); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// writeFile gob encodes v to path, through a temporary file.
+func writeFile(path string, v interface{}) error {
+	f, err := os.CreateTemp(filepath.Dir(path), filepath.Base(path)+".*.tmp")
+	if err != nil {
+		return err
+	}
+	tmp := f.Name()
+	if err := gob.NewEncoder(f).Encode(v); err != nil {
+		f.Close()
+		os.Remove(tmp)
+		return fmt.Errorf("writing %s: %v", path, err)
+	}
+	if err := f.Close(); err != nil {
+		os.Remove(tmp)
+		return err
+	}
+	return os.Rename(tmp, path)
+}
+
+// Load reads an index saved by Save from dir. The shards are read at
+// once, and each posting checked to be of one of the pages.
+func Load(dir string) (*Index, error) {
+	var h header
+	if err := readFile(filepath.Join(dir, indexFile), &h); err != nil {
+		return nil, err
+	}
+	if h.Version != version {
+		return nil, fmt.Errorf("reading index %s: version %d, want %d", dir, h.Version, version)
+	}
+	if h.Shards <= 0 {
+		return nil, fmt.Errorf("reading index %s: %d shards", dir, h.Shards)
+	}
+	if ok, _ := filepath.Match(shardsPattern, h.Dir); !ok || filepath.Base(h.Dir) != h.Dir {
+		return nil, fmt.Errorf("reading index %s: shards in %q", dir, h.Dir)
+	}
+
+	ix := &Index{shards: make([]*shard, h.Shards), docs: h.Docs}
+	for _, d := range h.Docs {
+		ix.length += d.Length
+	}
+	errs := make([]error, h.Shards)
+	var wg sync.WaitGroup
+	for i := range ix.shards {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+
+			path := filepath.Join(dir, h.Dir, shardFile(i))
+			sh := &shard{}
+			if errs[i] = readFile(path, &sh.terms); errs[i] != nil {
+				return
+			}
+			if sh.terms == nil {
+				sh.terms = make(map[string][]Posting)
+			}
+			for term, postings := range sh.terms {
+				for _, p := range postings {
+					if p.Doc < 0 || p.Doc >= len(h.Docs) {
+						errs[i] = fmt.Errorf("reading %s: %q is on page %d of %d", path, term, p.Doc, len(h.Docs))
+						return
+					}
+				}
+			}
+			ix.shards[i] = sh
+		}(i)
+	}
+	wg.Wait()
+	for _, err := range errs {
+		if err != nil {
+			return nil, err
+		}
+	}
+	return ix, nil
+}
+
+// readFile gob decodes path into v.
+func readFile(path string, v interface{}) error {
+	f, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	if err := gob.NewDecoder(f).Decode(v); err != nil {
+		return fmt.Errorf("reading %s: %v", path, err)
+	}
+	return nil
+}
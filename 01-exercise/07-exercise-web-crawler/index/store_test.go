@@ -0,0 +1,161 @@
+package index
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+
+	"go-concurrency-exercises/pkg/leakcheck"
+)
+
+func TestSaveLoad(t *testing.T) {
+	leakcheck.Check(t)
+
+	ix := docsIndex()
+	dir := filepath.Join(t.TempDir(), "index")
+	if err := ix.Save(dir); err != nil {
+		t.Fatal(err)
+	}
+	checkFiles(t, dir, 4)
+
+	loaded, err := Load(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if loaded.Len() != ix.Len() {
+		t.Fatalf("got %d pages, want %d", loaded.Len(), ix.Len())
+	}
+	for _, query := range []string{"go", `"go tool"`, "rust OR nothing", "language"} {
+		want, _ := ix.Search(query, 0)
+		got, err := loaded.Search(query, 0)
+		if err != nil || !reflect.DeepEqual(got, want) {
+			t.Errorf("Search(%s) after loading = %+v, %v; want %+v", query, got, err, want)
+		}
+	}
+
+	// A loaded index can still be added to, and saved over.
+	loaded.Add(Document{URL: "https://a/new", Text: "go again"})
+	if err := loaded.Save(dir); err != nil {
+		t.Fatal(err)
+	}
+	checkFiles(t, dir, 4)
+	again, err := Load(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if results, _ := again.Search("again", 0); len(results) != 1 || results[0].URL != "https://a/new" {
+		t.Errorf("got %+v, want the new page", results)
+	}
+}
+
+// checkFiles checks that dir holds the index file and one directory of n
+// shards.
+func checkFiles(t *testing.T, dir string, n int) {
+	t.Helper()
+	files, err := filepath.Glob(filepath.Join(dir, "*"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	shards, err := filepath.Glob(filepath.Join(dir, shardsPattern, "*"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(files) != 2 || len(shards) != n {
+		t.Errorf("got files %v and shards %v, want the index file and %d shards", files, shards, n)
+	}
+}
+
+func TestSaveWhileAdding(t *testing.T) {
+	leakcheck.Check(t)
+
+	ix := New(4)
+	dir := t.TempDir()
+	docs := make(chan Document)
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		ix.AddAll(docs, 4)
+	}()
+	for i := 0; i < 200; i++ {
+		docs <- Document{URL: fmt.Sprintf("https://a/%d", i), Text: fmt.Sprintf("page %d of many", i)}
+		if i%50 == 0 {
+			if err := ix.Save(dir); err != nil {
+				t.Fatal(err)
+			}
+			// Every posting saved must be of a page saved with it.
+			if _, err := Load(dir); err != nil {
+				t.Fatal(err)
+			}
+		}
+	}
+	close(docs)
+	<-done
+
+	if err := ix.Save(dir); err != nil {
+		t.Fatal(err)
+	}
+	checkFiles(t, dir, 4)
+	loaded, err := Load(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if results, _ := loaded.Search("many", 0); loaded.Len() != 200 || len(results) != 200 {
+		t.Errorf("got %d pages, %d matching; want 200", loaded.Len(), len(results))
+	}
+}
+
+func TestLoadErrors(t *testing.T) {
+	if _, err := Load(t.TempDir()); err == nil {
+		t.Error("got no error loading an empty directory")
+	}
+
+	dir := t.TempDir()
+	ix := New(2)
+	ix.Add(Document{URL: "https://a/", Text: "go"})
+	if err := ix.Save(dir); err != nil {
+		t.Fatal(err)
+	}
+	var h header
+	if err := readFile(filepath.Join(dir, indexFile), &h); err != nil {
+		t.Fatal(err)
+	}
+
+	// A save that failed before writing the index file leaves the last
+	// one to load.
+	if err := os.MkdirAll(filepath.Join(dir, "shards-failed"), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	if loaded, err := Load(dir); err != nil || loaded.Len() != 1 {
+		t.Errorf("got %v loading an index beside a failed save", err)
+	}
+
+	shard := filepath.Join(dir, h.Dir, shardFile(ix.shard("go")))
+	bad := map[string][]Posting{"go": {{Doc: 1, Positions: []int{0}}}}
+	if err := writeFile(shard, bad); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := Load(dir); err == nil {
+		t.Error("got no error loading a posting of a page not in the index")
+	}
+
+	if err := os.Remove(shard); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := Load(dir); err == nil {
+		t.Error("got no error loading an index with a shard missing")
+	}
+
+	for _, h := range []header{
+		{Version: version + 1, Shards: 2, Dir: h.Dir},
+		{Version: version, Shards: 2, Dir: "../" + h.Dir},
+	} {
+		if err := writeFile(filepath.Join(dir, indexFile), h); err != nil {
+			t.Fatal(err)
+		}
+		if _, err := Load(dir); err == nil {
+			t.Errorf("got no error loading an index with header %+v", h)
+		}
+	}
+}
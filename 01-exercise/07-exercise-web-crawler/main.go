@@ -1,134 +1,311 @@
 package main
 
 import (
+	"context"
+	"errors"
+	"flag"
 	"fmt"
-	"net/http"
-	"runtime"
+	"io"
+	"log"
+	"os"
+	"os/signal"
+	"sort"
 	"time"
 
-	"golang.org/x/net/html"
+	"07-exercise-web-crawler/crawler"
+	"07-exercise-web-crawler/index"
 )
 
-var fetched map[string]bool
-
-// Crawl uses findLinks to recursively crawl
-// pages starting with url, to a maximum of depth.
-// Non-concurrent version.
-//func Crawl(url string, depth int) {
-//	// TODO: Fetch URLs in parallel.
-//
-//	if depth < 0 {
-//		return
-//	}
-//	urls, err := findLinks(url)
-//	if err != nil {
-//		// fmt.Println(err)
-//		return
-//	}
-//	fmt.Printf("found: %s\n", url)
-//	fetched[url] = true
-//	for _, u := range urls {
-//		if !fetched[u] {
-//			Crawl(u, depth-1)
-//		}
-//	}
-//	return
-//}
-
-// result holds data to be passed between goroutines
-type result struct {
-	url   string
-	urls  []string
-	err   error
-	depth int
+// fakeSite is the Go tour's site graph, for crawling without a network.
+var fakeSite = crawler.FakeFetcher{
+	"https://golang.org/": &crawler.FakeResult{
+		Body: "The Go Programming Language",
+		URLs: []string{
+			"https://golang.org/pkg/",
+			"https://golang.org/cmd/",
+		},
+	},
+	"https://golang.org/pkg/": &crawler.FakeResult{
+		Body: "Packages",
+		URLs: []string{
+			"https://golang.org/",
+			"https://golang.org/cmd/",
+			"https://golang.org/pkg/fmt/",
+			"https://golang.org/pkg/os/",
+		},
+	},
+	"https://golang.org/pkg/fmt/": &crawler.FakeResult{
+		Body: "Package fmt",
+		URLs: []string{
+			"https://golang.org/",
+			"https://golang.org/pkg/",
+		},
+	},
+	"https://golang.org/pkg/os/": &crawler.FakeResult{
+		Body: "Package os",
+		URLs: []string{
+			"https://golang.org/",
+			"https://golang.org/pkg/",
+		},
+	},
 }
 
-// Crawl uses findLinks to recursively crawl
-// pages starting with url, to a maximum of depth.
-// Concurrent version.
-func Crawl(url string, depth int) {
-	// channel to send the result struct on
-	results := make(chan *result)
+// crawl runs c from seeds, and writes each page found to w, with the
+// kind of any asset after it. If ctx ends the crawl early, it writes why,
+// and which urls were still being fetched.
+func crawl(ctx context.Context, c *crawler.Crawler, w io.Writer, seeds ...string) {
+	inFlight := run(ctx, c, seeds, func(p crawler.Page) {
+		switch {
+		case p.Err != nil:
+		case p.Kind.Asset():
+			fmt.Fprintf(w, "found %s (%s)\n", p.URL, p.Kind)
+		default:
+			fmt.Fprintf(w, "found %s\n", p.URL)
+		}
+	})
+	stopped(ctx, w, inFlight)
+}
 
-	// fetch function will call findLinks and send results to the results channel.
-	fetch := func(url string, depth int) {
-		urls, err := findLinks(url)
-		results <- &result{url, urls, err, depth}
+// checkLinks runs c, which should check links, from seeds, and writes a
+// report of the broken links to w, as JSON if asJSON is set. Anything
+// about the crawl being stopped early goes to errw. It reports whether
+// any links are broken.
+func checkLinks(ctx context.Context, c *crawler.Crawler, w, errw io.Writer, asJSON bool, seeds ...string) (bool, error) {
+	r := crawler.NewLinkReport()
+	inFlight := run(ctx, c, seeds, r.Add)
+	stopped(ctx, errw, inFlight)
+
+	write := r.WriteText
+	if asJSON {
+		write = r.WriteJSON
 	}
+	return len(r.Broken()) > 0, write(w)
+}
 
-	// start a goroutine to call fetch
-	go fetch(url, depth)
+// seo runs c from seeds, and writes a report of the SEO issues of the
+// pages it crawled to w, as JSON if asJSON is set. Anything about the
+// crawl being stopped early goes to errw.
+func seo(ctx context.Context, c *crawler.Crawler, w, errw io.Writer, asJSON bool, seeds ...string) error {
+	r := crawler.NewSEOReport()
+	inFlight := run(ctx, c, seeds, r.Add)
+	stopped(ctx, errw, inFlight)
 
-	// record that we've fetched the url passed to this function
-	// so we don't get it again
-	fetched[url] = true
+	write := r.WriteText
+	if asJSON {
+		write = r.WriteJSON
+	}
+	return write(w)
+}
 
-	for fetching := 1; fetching > 0; fetching-- {
-		// get the results off the channel
-		res := <-results
-		if res.err != nil {
-			continue
+// buildIndex runs c, which should keep text, from seeds, and tokenises
+// the text of the pages it crawls into a full-text index as they come,
+// which it saves to dir. Anything about the crawl being stopped early
+// goes to errw; the pages crawled before then are still indexed. It
+// returns how many pages were indexed.
+func buildIndex(ctx context.Context, c *crawler.Crawler, errw io.Writer, dir string, seeds ...string) (int, error) {
+	ix := index.New(index.DefaultShards)
+	docs := make(chan index.Document)
+	added := make(chan struct{})
+	go func() {
+		defer close(added)
+		ix.AddAll(docs, 0)
+	}()
+
+	inFlight := run(ctx, c, seeds, func(p crawler.Page) {
+		if p.Err == nil && p.Meta != nil {
+			docs <- index.Document{URL: p.URL, Title: p.Meta.Title, Text: p.Text}
 		}
+	})
+	close(docs)
+	<-added
+	stopped(ctx, errw, inFlight)
+	return ix.Len(), ix.Save(dir)
+}
+
+// graphFormats are the ways -graph can write a crawl's graph.
+var graphFormats = map[string]func(*crawler.Graph, io.Writer) error{
+	"json":    (*crawler.Graph).WriteJSON,
+	"dot":     (*crawler.Graph).WriteDOT,
+	"sitemap": (*crawler.Graph).WriteSitemap,
+}
 
-		fmt.Printf("found %s\n", res.url)
-
-		// Keep calling fetch until depth is 0.
-		// Depth is decremented each time fetch is called.
-		if res.depth > 0 {
-			for _, u := range res.urls {
-				// Only proceed if we haven't already seen this url
-				if !fetched[u] {
-					fetching++
-					go fetch(u, res.depth-1)
-					fetched[u] = true
-				}
-			}
+// graph runs c from seeds, and writes the graph of pages and links it
+// found to w in format. Anything about the crawl being stopped early
+// goes to errw.
+func graph(ctx context.Context, c *crawler.Crawler, w, errw io.Writer, format string, seeds ...string) error {
+	write, ok := graphFormats[format]
+	if !ok {
+		return fmt.Errorf("unknown graph format %q: want json, dot or sitemap", format)
+	}
+	g := crawler.NewGraph()
+	inFlight := run(ctx, c, seeds, g.Add)
+	stopped(ctx, errw, inFlight)
+	return write(g, w)
+}
+
+// run runs c from seeds, and passes each page to found. If ctx ends the
+// crawl early, it returns the urls that were still being fetched.
+func run(ctx context.Context, c *crawler.Crawler, seeds []string, found func(crawler.Page)) []string {
+	var inFlight []string
+	for p := range c.Run(ctx, seeds...) {
+		if p.Err != nil && ctx.Err() != nil && errors.Is(p.Err, ctx.Err()) {
+			inFlight = append(inFlight, p.URL)
+			continue
 		}
+		found(p)
 	}
+	sort.Strings(inFlight)
+	return inFlight
+}
 
-	close(results)
+// stopped writes why ctx ended a crawl early, if it did, and the urls
+// that were still being fetched.
+func stopped(ctx context.Context, w io.Writer, inFlight []string) {
+	if err := ctx.Err(); err != nil {
+		fmt.Fprintf(w, "stopped early: %v\n", err)
+		for _, u := range inFlight {
+			fmt.Fprintf(w, "in flight %s\n", u)
+		}
+	}
 }
 
 func main() {
-	// Set the max number of CPUs that can be executing
-	// to the number of CPUs on the machine.
-	// Redundant to set this as GOMAXPROCS defaults to max
-	// number of CPUs anyeay.
-	runtime.GOMAXPROCS(runtime.NumCPU())
-	fetched = make(map[string]bool)
-	now := time.Now()
-	Crawl("http://andcloud.io", 2)
-	fmt.Println("time taken:", time.Since(now))
-}
+	var scope crawler.Scope
+	scope.Mode = crawler.ScopeAny
+	sortQuery := flag.Bool("sort-query", false, "treat urls whose query parameters differ only in order as the same page")
+	flag.Var(&scope.Mode, "scope", "which links to follow: any, host, domain (the registrable domain, with subdomains) or prefix")
+	flag.StringVar(&scope.Prefix, "prefix", "", "the url prefix for -scope prefix (default the start urls)")
+	flag.Var(&scope.Allow, "allow", "only follow urls matching this regexp; may be repeated")
+	flag.Var(&scope.Deny, "deny", "don't follow urls matching this regexp; may be repeated")
+	userAgent := flag.String("user-agent", crawler.DefaultUserAgent, "the User-Agent to send, and to pick robots.txt rules by")
+	robots := flag.Bool("robots", true, "obey robots.txt")
+	debug := flag.Bool("debug", false, "log why urls are skipped")
+	concurrency := flag.Int("concurrency", crawler.DefaultConcurrency, "the most pages to fetch at once")
+	hostConcurrency := flag.Int("host-concurrency", crawler.DefaultHostConcurrency, "the most pages to fetch at once from one host")
+	delay := flag.Duration("delay", crawler.DefaultHostDelay, "the least time between requests to one host; a longer Crawl-delay in robots.txt wins")
+	connectTimeout := flag.Duration("connect-timeout", crawler.DefaultConnectTimeout, "how long connecting to a host may take; 0 for no limit")
+	headerTimeout := flag.Duration("header-timeout", crawler.DefaultHeaderTimeout, "how long a response's headers may take; 0 for no limit")
+	bodyTimeout := flag.Duration("body-timeout", crawler.DefaultBodyTimeout, "how long reading a response's body may take; 0 for no limit")
+	timeout := flag.Duration("timeout", 0, "how long the whole crawl may take; 0 for no limit")
+	depth := flag.Int("depth", crawler.DefaultDepth, "how many links deep to follow from the start urls")
+	assets := flag.Bool("assets", false, "fetch the images, scripts and stylesheets pages use, to list and check them")
+	followAssets := flag.Bool("follow-assets", false, "with -assets, crawl assets within -scope and -depth as pages rather than only checking them")
+	check := flag.Bool("check", false, "check for broken links, crawling only within -scope (default host) and checking the links out of it")
+	seoReport := flag.Bool("seo", false, "report pages with no title or description, titles and descriptions shared by more than one page, and noindex pages, crawling only within -scope (default host)")
+	indexDir := flag.String("index", "", "build a full-text index of the text of the pages crawled within -scope (default host), and save it to this directory for cmd/search")
+	asJSON := flag.Bool("json", false, "with -check or -seo, write the report as JSON")
+	graphFormat := flag.String("graph", "", "write the crawl's pages and links as json, dot or sitemap (sitemap.xml of the 200 OK pages crawled within -scope, default host)")
+	fake := flag.Bool("fake", false, "crawl an in-memory copy of the Go tour's fake site instead of the web")
+	flag.Usage = func() {
+		fmt.Fprintf(flag.CommandLine.Output(), "usage: %s [flags] [url...]\n\nCrawls from each url, http://andcloud.io by default.\n\nFlags:\n", os.Args[0])
+		flag.PrintDefaults()
+	}
+	flag.Parse()
 
-func findLinks(url string) ([]string, error) {
-	resp, err := http.Get(url)
-	if err != nil {
-		return nil, err
+	scopeSet := false
+	flag.Visit(func(f *flag.Flag) {
+		scopeSet = scopeSet || f.Name == "scope"
+	})
+	modes := 0
+	for _, set := range []bool{*check, *seoReport, *graphFormat != "", *indexDir != ""} {
+		if set {
+			modes++
+		}
 	}
-	if resp.StatusCode != http.StatusOK {
-		resp.Body.Close()
-		return nil, fmt.Errorf("getting %s: %s", url, resp.Status)
+	if modes > 1 {
+		log.Fatal("only one of -check, -seo, -graph and -index can be used")
 	}
-	doc, err := html.Parse(resp.Body)
-	resp.Body.Close()
-	if err != nil {
-		return nil, fmt.Errorf("parsing %s as HTML: %v", url, err)
+	if _, ok := graphFormats[*graphFormat]; *graphFormat != "" && !ok {
+		log.Fatalf("unknown -graph format %q: want json, dot or sitemap", *graphFormat)
+	}
+	if (*check || *seoReport || *indexDir != "" || *graphFormat == "sitemap") && !scopeSet {
+		scope.Mode = crawler.ScopeHost
 	}
-	return visit(nil, doc), nil
-}
 
-// visit appends to links each link found in n, and returns the result.
-func visit(links []string, n *html.Node) []string {
-	if n.Type == html.ElementNode && n.Data == "a" {
-		for _, a := range n.Attr {
-			if a.Key == "href" {
-				links = append(links, a.Val)
-			}
+	opts := []crawler.Option{
+		crawler.WithScope(scope),
+		crawler.UserAgent(*userAgent),
+		crawler.Concurrency(*concurrency),
+		crawler.HostConcurrency(*hostConcurrency),
+		crawler.HostDelay(*delay),
+		crawler.Timeouts(*connectTimeout, *headerTimeout, *bodyTimeout),
+		crawler.Depth(*depth),
+	}
+	if *sortQuery {
+		opts = append(opts, crawler.SortQuery())
+	}
+	if !*robots {
+		opts = append(opts, crawler.IgnoreRobots())
+	}
+	if *debug {
+		opts = append(opts, crawler.DebugLog(log.Default()))
+	}
+	if *check {
+		opts = append(opts, crawler.CheckLinks())
+	}
+	if *indexDir != "" {
+		opts = append(opts, crawler.KeepText())
+	}
+	if *assets {
+		opts = append(opts, crawler.Assets(*followAssets))
+	}
+
+	seeds := []string{"http://andcloud.io"}
+	if *fake {
+		opts = append(opts, crawler.WithFetcher(fakeSite))
+		seeds = []string{"https://golang.org/"}
+	}
+	if flag.NArg() > 0 {
+		seeds = flag.Args()
+	}
+
+	// The first interrupt stops the crawl cleanly; a second one kills it.
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt)
+	go func() {
+		<-ctx.Done()
+		stop()
+	}()
+	if *timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, *timeout)
+		defer cancel()
+	}
+
+	if *check {
+		broken, err := checkLinks(ctx, crawler.New(opts...), os.Stdout, os.Stderr, *asJSON, seeds...)
+		if err != nil {
+			log.Fatal(err)
 		}
+		if broken {
+			os.Exit(1)
+		}
+		return
+	}
+
+	if *seoReport {
+		if err := seo(ctx, crawler.New(opts...), os.Stdout, os.Stderr, *asJSON, seeds...); err != nil {
+			log.Fatal(err)
+		}
+		return
+	}
+
+	if *indexDir != "" {
+		n, err := buildIndex(ctx, crawler.New(opts...), os.Stderr, *indexDir, seeds...)
+		if err != nil {
+			log.Fatal(err)
+		}
+		fmt.Printf("indexed %d pages in %s\n", n, *indexDir)
+		return
 	}
-	for c := n.FirstChild; c != nil; c = c.NextSibling {
-		links = visit(links, c)
+
+	if *graphFormat != "" {
+		if err := graph(ctx, crawler.New(opts...), os.Stdout, os.Stderr, *graphFormat, seeds...); err != nil {
+			log.Fatal(err)
+		}
+		return
 	}
-	return links
+
+	now := time.Now()
+	crawl(ctx, crawler.New(opts...), os.Stdout, seeds...)
+	fmt.Println("time taken:", time.Since(now))
 }
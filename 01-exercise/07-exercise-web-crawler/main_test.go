@@ -0,0 +1,159 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"sort"
+	"strings"
+	"testing"
+	"time"
+
+	"07-exercise-web-crawler/crawler"
+	"07-exercise-web-crawler/index"
+
+	"go-concurrency-exercises/pkg/leakcheck"
+)
+
+func TestCrawl(t *testing.T) {
+	leakcheck.Check(t)
+
+	var out bytes.Buffer
+	c := crawler.New(crawler.WithFetcher(fakeSite), crawler.HostDelay(0), crawler.Depth(4))
+	crawl(context.Background(), c, &out, "https://golang.org/")
+
+	// Pages come in the order they are fetched; the missing /cmd/ page is
+	// not printed.
+	got := strings.Split(strings.TrimSpace(out.String()), "\n")
+	sort.Strings(got)
+	want := []string{
+		"found https://golang.org/",
+		"found https://golang.org/pkg/",
+		"found https://golang.org/pkg/fmt/",
+		"found https://golang.org/pkg/os/",
+	}
+	if strings.Join(got, "\n") != strings.Join(want, "\n") {
+		t.Errorf("got\n%s\nwant\n%s", strings.Join(got, "\n"), strings.Join(want, "\n"))
+	}
+}
+
+// stallingFetcher serves fakeSite, but never answers for /pkg/os/.
+type stallingFetcher struct{}
+
+func (stallingFetcher) Fetch(ctx context.Context, url string) ([]string, error) {
+	if url == "https://golang.org/pkg/os/" {
+		<-ctx.Done()
+		return nil, ctx.Err()
+	}
+	return fakeSite.Fetch(ctx, url)
+}
+
+func TestCrawlDeadline(t *testing.T) {
+	leakcheck.Check(t)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 200*time.Millisecond)
+	defer cancel()
+
+	var out bytes.Buffer
+	c := crawler.New(crawler.WithFetcher(stallingFetcher{}), crawler.HostDelay(0), crawler.HostConcurrency(4), crawler.Depth(4))
+	crawl(ctx, c, &out, "https://golang.org/")
+
+	want := "stopped early: context deadline exceeded\nin flight https://golang.org/pkg/os/\n"
+	if !strings.HasSuffix(out.String(), want) {
+		t.Errorf("got\n%s\nwant it to end\n%s", out.String(), want)
+	}
+	if !strings.Contains(out.String(), "found https://golang.org/pkg/fmt/\n") {
+		t.Errorf("got\n%s\nwant /pkg/fmt/ found", out.String())
+	}
+}
+
+func TestCheckLinks(t *testing.T) {
+	leakcheck.Check(t)
+
+	var out, errs bytes.Buffer
+	c := crawler.New(crawler.WithFetcher(fakeSite), crawler.HostDelay(0), crawler.CheckLinks())
+	broken, err := checkLinks(context.Background(), c, &out, &errs, false, "https://golang.org/")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	want := `https://golang.org/
+	404 https://golang.org/cmd/
+https://golang.org/pkg/
+	404 https://golang.org/cmd/
+checked 5 urls, 1 broken
+`
+	if !broken || out.String() != want || errs.Len() != 0 {
+		t.Errorf("got %v,\n%s\n%s\nwant true,\n%s", broken, out.String(), errs.String(), want)
+	}
+}
+
+func TestGraph(t *testing.T) {
+	leakcheck.Check(t)
+
+	var out, errs bytes.Buffer
+	c := crawler.New(crawler.WithFetcher(fakeSite), crawler.HostDelay(0), crawler.Depth(0))
+	if err := graph(context.Background(), c, &out, &errs, "dot", "https://golang.org/"); err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(out.String(), "\t\"https://golang.org/\" -> \"https://golang.org/pkg/\";\n") || errs.Len() != 0 {
+		t.Errorf("got\n%s\n%s\nwant a DOT graph", out.String(), errs.String())
+	}
+
+	if err := graph(context.Background(), c, &out, &errs, "png", "https://golang.org/"); err == nil {
+		t.Error("got no error for an unknown format")
+	}
+}
+
+func TestSEO(t *testing.T) {
+	leakcheck.Check(t)
+
+	var out, errs bytes.Buffer
+	c := crawler.New(crawler.WithFetcher(fakeSite), crawler.HostDelay(0), crawler.Depth(4))
+	if err := seo(context.Background(), c, &out, &errs, false, "https://golang.org/"); err != nil {
+		t.Fatal(err)
+	}
+
+	// The fake pages have titles, from their bodies, but no descriptions.
+	want := `no description
+	https://golang.org/
+	https://golang.org/pkg/
+	https://golang.org/pkg/fmt/
+	https://golang.org/pkg/os/
+checked 4 pages, 4 with issues
+`
+	if out.String() != want || errs.Len() != 0 {
+		t.Errorf("got\n%s\n%s\nwant\n%s", out.String(), errs.String(), want)
+	}
+}
+
+func TestBuildIndex(t *testing.T) {
+	leakcheck.Check(t)
+
+	dir := t.TempDir()
+	var errs bytes.Buffer
+	c := crawler.New(crawler.WithFetcher(fakeSite), crawler.HostDelay(0), crawler.Depth(4), crawler.KeepText())
+	n, err := buildIndex(context.Background(), c, &errs, dir, "https://golang.org/")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if n != 4 || errs.Len() != 0 {
+		t.Errorf("got %d pages indexed, %q; want the 4 found", n, errs.String())
+	}
+
+	ix, err := index.Load(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	results, err := ix.Search("package", 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var urls []string
+	for _, r := range results {
+		urls = append(urls, r.URL)
+	}
+	sort.Strings(urls)
+	if want := []string{"https://golang.org/pkg/fmt/", "https://golang.org/pkg/os/"}; strings.Join(urls, " ") != strings.Join(want, " ") {
+		t.Errorf("got %v, want %v", urls, want)
+	}
+}
@@ -4,45 +4,58 @@ import (
 	"context"
 	"fmt"
 	"time"
+
+	"go-concurrency-exercises/pkg/clock"
 )
 
 func main() {
-	gen := func(ctx context.Context) <-chan int {
-		dst := make(chan int)
-		n := 1
-		go func() {
-			defer close(dst)
-
-			numGenerator := func() error {
-				deadline, ok := ctx.Deadline()
-				if ok {
-					if deadline.Sub(time.Now().Add(10*time.Millisecond)) <= 0 {
-						return context.DeadlineExceeded
-					}
-				}
-				for {
-					select {
-					case <-ctx.Done():
-						return ctx.Err()
-					case dst <- n:
-						n++
-					}
-				}
-			}
-
-			err := numGenerator()
-			if err != nil {
-				fmt.Println(err)
-			}
-		}()
-		return dst
-	}
+	c := clock.New()
 
-	deadline := time.Now().Add(5 * time.Millisecond)
-	ctx, cancel := context.WithDeadline(context.Background(), deadline)
+	deadline := c.Now().Add(5 * time.Millisecond)
+	ctx, cancel := clock.WithDeadline(context.Background(), c, deadline)
 	defer cancel()
 
-	for n := range gen(ctx) {
+	nums, errc := gen(ctx, c)
+	for n := range nums {
 		fmt.Println(n)
 	}
+
+	if err := <-errc; err != nil {
+		fmt.Println(err)
+	}
+}
+
+// gen sends 1, 2, 3... on the returned channel until ctx is done. If
+// ctx's deadline is less than 10ms away it doesn't start at all. Once the
+// number channel is closed, the reason gen stopped is sent on the error
+// channel.
+func gen(ctx context.Context, c clock.Clock) (<-chan int, <-chan error) {
+	dst := make(chan int)
+	errc := make(chan error, 1)
+	n := 1
+
+	go func() {
+		defer close(dst)
+
+		numGenerator := func() error {
+			deadline, ok := ctx.Deadline()
+			if ok {
+				if deadline.Sub(c.Now().Add(10*time.Millisecond)) <= 0 {
+					return context.DeadlineExceeded
+				}
+			}
+			for {
+				select {
+				case <-ctx.Done():
+					return ctx.Err()
+				case dst <- n:
+					n++
+				}
+			}
+		}
+
+		errc <- numGenerator()
+	}()
+
+	return dst, errc
 }
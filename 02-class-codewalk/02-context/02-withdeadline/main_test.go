@@ -0,0 +1,50 @@
+package main
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"go-concurrency-exercises/pkg/clock"
+	"go-concurrency-exercises/pkg/leakcheck"
+)
+
+func TestGenNotEnoughTime(t *testing.T) {
+	leakcheck.Check(t)
+
+	c := clock.NewFake(time.Now())
+	ctx, cancel := clock.WithTimeout(context.Background(), c, 5*time.Millisecond)
+	defer cancel()
+
+	nums, errc := gen(ctx, c)
+	for n := range nums {
+		t.Errorf("got %d, want no numbers", n)
+	}
+	if err := <-errc; err != context.DeadlineExceeded {
+		t.Errorf("got %v, want %v", err, context.DeadlineExceeded)
+	}
+}
+
+func TestGenUntilDeadline(t *testing.T) {
+	leakcheck.Check(t)
+
+	c := clock.NewFake(time.Now())
+	ctx, cancel := clock.WithTimeout(context.Background(), c, time.Hour)
+	defer cancel()
+
+	nums, errc := gen(ctx, c)
+	for want := 1; want <= 3; want++ {
+		if n := <-nums; n != want {
+			t.Fatalf("got %d, want %d", n, want)
+		}
+	}
+
+	c.Advance(time.Hour)
+
+	// gen may send a few more before it sees the deadline.
+	for range nums {
+	}
+	if err := <-errc; err != context.DeadlineExceeded {
+		t.Errorf("got %v, want %v", err, context.DeadlineExceeded)
+	}
+}
@@ -1,16 +1,20 @@
 package main
 
 import (
+	"flag"
 	"fmt"
 	"image"
 	"log"
 	"net/http"
 	"os"
 	"path/filepath"
+	"strings"
 	"sync"
 	"time"
 
 	"github.com/disintegration/imaging"
+
+	"go-concurrency-exercises/pkg/checkpoint"
 )
 
 // Pipeline
@@ -26,12 +30,41 @@ type result struct {
 // Image processing - Pipeline
 // Input - directory with images.
 // output - thumbnail images
+//
+// With -journal, each image is recorded in the journal once its thumbnail
+// is saved. If the run is stopped part way through, running it again with
+// the same journal skips the images that are already done.
 func main() {
-	if len(os.Args) < 2 {
+	journalPath := flag.String("journal", "", "record finished images in this file and skip them when run again")
+	flag.Parse()
+
+	if flag.NArg() < 1 {
 		log.Fatal("need to send directory path of images")
 	}
+
+	var journal *checkpoint.Journal
+	if *journalPath != "" {
+		var err error
+		journal, err = checkpoint.Open(*journalPath)
+		if err != nil {
+			log.Fatal(err)
+		}
+		fmt.Printf("%d images already done\n", journal.Len())
+	}
+
 	start := time.Now()
-	err := setupPipeLine(os.Args[1])
+	err := setupPipeLine(flag.Arg(0), journal)
+
+	if journal != nil {
+		if err == nil {
+			// Keep the journal small by dropping images that
+			// have since been changed or deleted.
+			err = journal.Compact(current)
+		}
+		if cerr := journal.Close(); err == nil {
+			err = cerr
+		}
+	}
 
 	if err != nil {
 		log.Fatal(err)
@@ -39,12 +72,15 @@ func main() {
 	fmt.Printf("Time taken: %s\n", time.Since(start))
 }
 
-func setupPipeLine(root string) error {
+// setupPipeLine makes thumbnails of the images under root. If journal is
+// not nil, images it has recorded are skipped and new thumbnails are
+// recorded in it.
+func setupPipeLine(root string, journal *checkpoint.Journal) error {
 	done := make(chan struct{})
 	defer close(done)
 
 	// do the file walk
-	paths, errc := walkFiles(done, root)
+	paths, errc := walkFiles(done, root, journal)
 
 	// process the image
 	results := processImage(done, paths)
@@ -54,7 +90,19 @@ func setupPipeLine(root string) error {
 		if r.err != nil {
 			return r.err
 		}
-		saveThumbnail(r.srcImagePath, r.thumbnailImage)
+		if err := saveThumbnail(r.srcImagePath, r.thumbnailImage); err != nil {
+			return err
+		}
+
+		if journal != nil {
+			info, err := os.Stat(r.srcImagePath)
+			if err != nil {
+				return err
+			}
+			if err := journal.Mark(imageKey(r.srcImagePath, info)); err != nil {
+				return err
+			}
+		}
 	}
 
 	// check for error on the channel, from walkfiles stage.
@@ -64,7 +112,25 @@ func setupPipeLine(root string) error {
 	return nil
 }
 
-func walkFiles(done <-chan struct{}, root string) (<-chan string, <-chan error) {
+// imageKey is the key an image is recorded under in the journal. It
+// includes the size and modification time, so an image that is changed
+// after its thumbnail was made gets a new one.
+func imageKey(path string, info os.FileInfo) string {
+	return fmt.Sprintf("%d %d %s", info.Size(), info.ModTime().UnixNano(), path)
+}
+
+// current reports whether the image a journal key was recorded for is
+// still there and unchanged.
+func current(key string) bool {
+	parts := strings.SplitN(key, " ", 3)
+	if len(parts) != 3 {
+		return false
+	}
+	info, err := os.Stat(parts[2])
+	return err == nil && imageKey(parts[2], info) == key
+}
+
+func walkFiles(done <-chan struct{}, root string, journal *checkpoint.Journal) (<-chan string, <-chan error) {
 
 	// create output channels
 	paths := make(chan string)
@@ -90,6 +156,11 @@ func walkFiles(done <-chan struct{}, root string) (<-chan string, <-chan error)
 				return nil
 			}
 
+			// skip images an earlier run has already done
+			if journal != nil && journal.Completed(imageKey(path, info)) {
+				return nil
+			}
+
 			// send file path to next stage
 			select {
 			case paths <- path:
@@ -0,0 +1,49 @@
+package main
+
+import (
+	"testing"
+
+	"go-concurrency-exercises/pkg/leakcheck"
+)
+
+func TestPipelineCancelledAfterOneValue(t *testing.T) {
+	leakcheck.Check(t)
+
+	done := make(chan struct{})
+
+	in := generator(done, 2, 3)
+
+	c1 := square(done, in)
+	c2 := square(done, in)
+
+	out := merge(done, c1, c2)
+
+	if n := <-out; n != 4 && n != 9 {
+		t.Errorf("got %d, want 4 or 9", n)
+	}
+
+	// Closing done must stop every stage, even though the second
+	// value was never read.
+	close(done)
+}
+
+func TestPipelineDrained(t *testing.T) {
+	leakcheck.Check(t)
+
+	done := make(chan struct{})
+	defer close(done)
+
+	in := generator(done, 1, 2, 3, 4)
+
+	c1 := square(done, in)
+	c2 := square(done, in)
+
+	sum := 0
+	for n := range merge(done, c1, c2) {
+		sum += n
+	}
+
+	if sum != 30 {
+		t.Errorf("got sum %d, want 30", sum)
+	}
+}
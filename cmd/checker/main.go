@@ -0,0 +1,150 @@
+// checker tells you whether you have finished an exercise. It runs your
+// version with the race detector and checks what it printed, against the
+// solution's output or for properties such as a balance of 0, and gives a
+// hint for each check that fails. Run it from the repository root.
+//
+//	go run ./cmd/checker                   check every exercise
+//	go run ./cmd/checker 04-sync/11-atomic check one
+//	go run ./cmd/checker -solution         check the solutions, to test the specs
+//	go run ./cmd/checker -update           record golden files from the solutions
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"os"
+	"os/signal"
+	"path/filepath"
+
+	"go-concurrency-exercises/pkg/checker"
+	"go-concurrency-exercises/pkg/exercises"
+)
+
+const usage = `usage: checker [flags] [name...]
+
+Checks the named exercises, or all of them. A name is an exercise's path
+from the root, such as 01-exercise/04-sync/11-atomic, or the end of it.
+
+Flags:
+`
+
+func main() {
+	root := flag.String("root", ".", "the repository root")
+	golden := flag.String("golden", "", "the directory of golden files (default <root>/cmd/checker/golden)")
+	solution := flag.Bool("solution", false, "check the solutions rather than the exercises")
+	update := flag.Bool("update", false, "record the golden files by running the solutions")
+	timeout := flag.Duration("timeout", 0, "kill an exercise that runs for longer than this (default from its spec, or 30s)")
+	verbose := flag.Bool("v", false, "list the checks that passed too")
+	flag.Usage = func() {
+		fmt.Fprint(flag.CommandLine.Output(), usage)
+		flag.PrintDefaults()
+	}
+	flag.Parse()
+
+	if *golden == "" {
+		*golden = filepath.Join(*root, "cmd", "checker", "golden")
+	}
+
+	examples, err := exercises.Discover(*root)
+	if err != nil {
+		fatalf("finding exercises: %v", err)
+	}
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt)
+	defer stop()
+
+	c := &checker.Checker{GoldenDir: *golden}
+	failed := 0
+	for _, spec := range selected(examples, flag.Args()) {
+		if ctx.Err() != nil {
+			break
+		}
+		if *timeout > 0 {
+			s := *spec
+			s.Timeout = *timeout
+			spec = &s
+		}
+
+		kind := exercises.KindExercise
+		if *solution || *update {
+			kind = exercises.KindSolution
+		}
+		e := find(examples, spec.Stem, kind)
+		if e == nil {
+			fmt.Fprintf(os.Stderr, "SKIP %s  no %s\n", spec.Stem, kind)
+			continue
+		}
+
+		if *update {
+			if !spec.Golden {
+				continue
+			}
+			if err := c.Update(ctx, e, spec); err != nil {
+				fmt.Fprintf(os.Stderr, "FAIL %s  %v\n", e.Name, err)
+				failed++
+				continue
+			}
+			fmt.Printf("ok   %s\n", e.Name)
+			continue
+		}
+
+		report := c.Check(ctx, e, spec)
+		report.Write(os.Stdout, *verbose)
+		if !report.Passed() {
+			failed++
+		}
+	}
+
+	if failed > 0 {
+		os.Exit(1)
+	}
+}
+
+// selected returns the specs for the named exercises, or all of them if
+// none are named.
+func selected(examples []*exercises.Example, names []string) []*checker.Spec {
+	if len(names) == 0 {
+		return specs
+	}
+
+	byStem := make(map[string]*checker.Spec)
+	for _, spec := range specs {
+		byStem[spec.Stem] = spec
+	}
+
+	var picked []*checker.Spec
+	seen := make(map[string]bool)
+	for _, name := range names {
+		found := exercises.Find(examples, name)
+		if len(found) == 0 {
+			fatalf("no exercise called %s", name)
+		}
+		for _, e := range found {
+			spec := byStem[e.Stem]
+			if spec == nil {
+				fatalf("%s has no checks", e.Name)
+			}
+			if !seen[e.Stem] {
+				seen[e.Stem] = true
+				picked = append(picked, spec)
+			}
+		}
+	}
+	return picked
+}
+
+// find returns the first example of kind with the given stem.
+func find(examples []*exercises.Example, stem string, kind exercises.Kind) *exercises.Example {
+	for _, e := range examples {
+		if e.Stem == stem && e.Kind == kind {
+			return e
+		}
+	}
+	return nil
+}
+
+func fatalf(format string, args ...interface{}) {
+	fmt.Fprintf(os.Stderr, "checker: "+format+"\n", args...)
+	os.Exit(1)
+}
@@ -0,0 +1,226 @@
+package main
+
+import (
+	"fmt"
+	"regexp"
+	"time"
+
+	"go-concurrency-exercises/pkg/checker"
+)
+
+// specs say how each exercise is checked. Exercises whose output depends
+// on the network or that need a second program, such as the client and
+// server, are left out.
+var specs = []*checker.Spec{
+	// Goroutines
+	{
+		Stem:       "01-exercise/01-goroutines/01-hello",
+		Golden:     true,
+		Mode:       checker.Multiset,
+		Filter:     `^goroutine-\d+$`,
+		GoldenHint: "Call fun as a goroutine three ways, and wait for them all to finish before main returns.",
+		Checks: []checker.Check{
+			checker.Count("calls fun directly three times", `^direct call$`, 3, ""),
+		},
+	},
+	{
+		Stem: "01-exercise/01-goroutines/03-join",
+		Checks: []checker.Check{
+			checker.Contains("the value of data is 1", "Wait for the goroutine to increment data before printing it."),
+		},
+	},
+	{
+		Stem: "01-exercise/01-goroutines/04-add",
+		// Adding ten million numbers with the race detector is slow.
+		Timeout: 2 * time.Minute,
+		Checks: []checker.Check{
+			sameSums,
+		},
+	},
+	{
+		Stem: "01-exercise/01-goroutines/05-closure",
+		Checks: []checker.Check{
+			checker.Contains("value of i: 1", "The goroutine's closure shares i with incr; wait for the goroutine before returning."),
+		},
+	},
+	{
+		Stem:       "01-exercise/01-goroutines/06-closure",
+		Golden:     true,
+		Mode:       checker.Multiset,
+		GoldenHint: "Each goroutine should print its own value of i: pass i as an argument rather than closing over the loop variable.",
+	},
+
+	// Channels
+	{
+		Stem: "01-exercise/02-channel/01-channel",
+		Checks: []checker.Check{
+			checker.Contains("computed value 3", "Send the result of the computation on the channel and receive it in main."),
+		},
+	},
+	{
+		Stem: "01-exercise/02-channel/02-channel",
+		Checks: []checker.Check{
+			checker.Count("receives 0 to 5", `^(Received: )?[0-5]$`, 6, "Range over the channel until the sender closes it."),
+		},
+	},
+	{
+		Stem:   "01-exercise/02-channel/03-channel",
+		Golden: true,
+		Mode:   checker.Multiset,
+	},
+	{
+		Stem: "01-exercise/02-channel/04-channel",
+	},
+	{
+		Stem: "01-exercise/02-channel/05-channel",
+		Checks: []checker.Check{
+			checker.LastLine("says it is done last", "Done receiving!", "Range over the channel; the loop ends when the sender closes it."),
+		},
+	},
+
+	// Select
+	{
+		Stem:       "01-exercise/03-select/01-select",
+		Golden:     true,
+		Mode:       checker.Multiset,
+		GoldenHint: "select on both channels twice, so each message is received once.",
+	},
+	{
+		Stem: "01-exercise/03-select/02-select",
+		Checks: []checker.Check{
+			checker.Contains("timeout", "Add a case on time.After to the select."),
+		},
+	},
+	{
+		Stem: "01-exercise/03-select/03-select",
+	},
+
+	// Sync
+	{
+		Stem: "01-exercise/04-sync/01-mutex",
+		Checks: []checker.Check{
+			checker.LastLine("balance is 0", "0", "Every deposit and withdrawal must hold the mutex while it reads and writes the balance."),
+		},
+	},
+	{
+		Stem: "01-exercise/04-sync/02-mutex",
+		Checks: []checker.Check{
+			checker.LastLine("ends with the value 10", "10", "Read the balance under the read lock, and wait for the deposits to finish before the final read."),
+		},
+	},
+	{
+		Stem: "01-exercise/04-sync/11-atomic",
+		Checks: []checker.Check{
+			checker.Value("counter is 50000", `counter:\s*(\d+)`, "50000", "Increment the counter with atomic.AddUint64 rather than ++."),
+		},
+	},
+	{
+		Stem: "01-exercise/04-sync/21-cond",
+		Checks: []checker.Check{
+			checker.Contains("foo", "The goroutine should Wait on the condition until main has put foo in the map and called Signal."),
+		},
+	},
+	{
+		Stem: "01-exercise/04-sync/22-cond",
+		Checks: []checker.Check{
+			checker.Count("prints foo once", `foo$`, 1, "Wake both goroutines with Broadcast."),
+			checker.Count("prints bar once", `bar$`, 1, "Wake both goroutines with Broadcast."),
+		},
+	},
+	{
+		Stem: "01-exercise/04-sync/31-once",
+		Checks: []checker.Check{
+			checker.Count("loads once", `initialization function`, 1, "Call the initialisation through a sync.Once."),
+		},
+	},
+	{
+		Stem: "01-exercise/04-sync/41-pool",
+		Checks: []checker.Check{
+			checker.Count("logs debug-string1 once", `debug-string1$`, 1, ""),
+			checker.Count("logs debug-string2 once", `debug-string2$`, 1, ""),
+		},
+	},
+
+	// Race detection: the point is the race, which every spec checks for.
+	{
+		Stem: "01-exercise/05-race",
+	},
+
+	// Pipelines
+	{
+		Stem:   "02-exercise/02-pipeline/01-pipeline",
+		Golden: true,
+	},
+	{
+		Stem:   "02-exercise/02-pipeline/02-pipeline",
+		Golden: true,
+		Mode:   checker.Multiset,
+	},
+	{
+		Stem: "02-exercise/02-pipeline/03-pipeline",
+	},
+
+	// Context
+	{
+		Stem:       "02-exercise/03-context/01-withcancel",
+		Golden:     true,
+		GoldenHint: "Cancel the context once 5 values have been received, so the generator stops.",
+	},
+	{
+		Stem: "02-exercise/03-context/02-withdeadline",
+		Checks: []checker.Check{
+			checker.Contains("terminating", "Select on ctx.Done() and give up when the deadline passes."),
+		},
+	},
+	{
+		Stem:   "02-exercise/03-context/04-value",
+		Golden: true,
+	},
+
+	// Interfaces
+	{
+		Stem:       "03-exercise/01-Interfaces/01-density",
+		Golden:     true,
+		GoldenHint: "Add a Gas type with a Density method, make IsDenser take a Dense interface, and compare oxygen with hydrogen.",
+	},
+	{
+		Stem:       "03-exercise/01-Interfaces/03-byte-counter",
+		Golden:     true,
+		GoldenHint: "Give ByteCounter a Write method, so it is an io.Writer that counts bytes.",
+	},
+	{
+		Stem:       "03-exercise/01-Interfaces/04-String",
+		Golden:     true,
+		GoldenHint: "Give user a String method, so fmt prints it as a name and email address.",
+	},
+	{
+		Stem:       "03-exercise/01-Interfaces/09-triangle",
+		Golden:     true,
+		GoldenHint: "Create a circle, rectangle and triangle, print the area of each, then the angles of the triangle.",
+	},
+}
+
+// sameSums checks that adding the numbers concurrently gives the same sum
+// as adding them one at a time.
+var sameSums = checker.Check{
+	Name: "concurrent sum equals sequential sum",
+	Hint: "Each goroutine should add its own part of the numbers, and the parts be added together once they have all finished.",
+	Test: func(out *checker.Output) error {
+		re := regexp.MustCompile(`^(Sequential|Concurrent) Add, Sum: (\d+)`)
+		sums := make(map[string]string)
+		for _, l := range out.Lines {
+			if m := re.FindStringSubmatch(l); m != nil {
+				sums[m[1]] = m[2]
+			}
+		}
+		seq, ok1 := sums["Sequential"]
+		con, ok2 := sums["Concurrent"]
+		switch {
+		case !ok1 || !ok2:
+			return fmt.Errorf("want a sequential and a concurrent sum, got %v", sums)
+		case seq != con:
+			return fmt.Errorf("sequential sum is %s, concurrent sum is %s", seq, con)
+		}
+		return nil
+	},
+}
@@ -1,19 +1,58 @@
 package main
 
 import (
+	"context"
+	"flag"
 	"log"
+	"os"
+	"os/signal"
+	"strconv"
 	"sync"
 	"time"
+
+	"go-concurrency-exercises/pkg/wal"
+	"go-concurrency-exercises/pkg/workerpool"
 )
 
 func main() {
+	walDir := flag.String("wal", "", "keep the queue in a write-ahead log in this directory, so values not yet processed are picked up by the next run")
+	flag.Parse()
+
 	numberOfValues := 10
+	routinePool := numberOfValues / 2
+
+	// Interrupting cancels ctx, which cuts the work short.
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt)
+	defer stop()
+
+	work := func(ctx context.Context, v int) error {
+		select {
+		case <-time.After(1 * time.Second):
+			return nil
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+	}
+
+	if *walDir != "" {
+		if err := processDurable(ctx, *walDir, numberOfValues, routinePool, work); err != nil {
+			log.Fatal(err)
+		}
+	} else {
+		process(ctx, numberOfValues, routinePool, work)
+	}
+
+	log.Println("main finished")
+}
+
+// process starts routinePool goroutines that take values off a buffered
+// channel and pass each one to work with ctx. It sends numberOfValues
+// values, closes the channel and waits for the goroutines to finish.
+func process(ctx context.Context, numberOfValues, routinePool int, work func(ctx context.Context, v int) error) {
 	wg := sync.WaitGroup{}
 
 	values := make(chan int, numberOfValues)
 
-	routinePool := numberOfValues / 2
-
 	for i := 0; i < routinePool; i++ {
 		wg.Add(1)
 
@@ -23,7 +62,9 @@ func main() {
 			log.Printf("Goroutine %d waiting for value", i)
 			for v := range values {
 				log.Printf("Goroutine %d received value %d", i, v)
-				time.Sleep(1 * time.Second)
+				if err := work(ctx, v); err != nil {
+					log.Printf("Goroutine %d failed value %d: %v", i, v, err)
+				}
 			}
 			log.Printf("Goroutine %d terminating", i)
 		}(values, i, &wg)
@@ -35,6 +76,47 @@ func main() {
 
 	close(values)
 	wg.Wait()
+}
 
-	log.Println("main finished")
+// processDurable is process with the values channel replaced by a queue
+// kept in a write-ahead log in dir. Values left in the log by an earlier
+// run that was killed are processed first, then numberOfValues new ones.
+// A value is only removed from the log once work has returned nil for it,
+// so values whose work was cut short by ctx are picked up again too.
+func processDurable(ctx context.Context, dir string, numberOfValues, routinePool int, work func(ctx context.Context, v int) error) error {
+	l, err := wal.Open(dir)
+	if err != nil {
+		return err
+	}
+	defer l.Close()
+
+	if n := len(l.Pending()); n > 0 {
+		log.Printf("Picking up %d values from the last run", n)
+	}
+
+	pool, err := workerpool.NewDurable(ctx, routinePool, l, func(ctx context.Context, payload []byte) error {
+		v, err := strconv.Atoi(string(payload))
+		if err != nil {
+			return err
+		}
+		log.Printf("Received value %d", v)
+		return work(ctx, v)
+	}, workerpool.QueueSize(numberOfValues))
+	if err != nil {
+		return err
+	}
+
+	for i := 0; i < numberOfValues; i++ {
+		if _, err := pool.Enqueue(ctx, []byte(strconv.Itoa(i))); err != nil {
+			pool.Shutdown(ctx)
+			return err
+		}
+	}
+
+	if err := pool.Shutdown(ctx); err != nil {
+		return err
+	}
+
+	// Everything has been processed, so the log can shrink to nothing.
+	return l.Compact()
 }
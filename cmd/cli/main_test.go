@@ -0,0 +1,115 @@
+package main
+
+import (
+	"context"
+	"io"
+	"log"
+	"sync"
+	"sync/atomic"
+	"testing"
+
+	"go-concurrency-exercises/pkg/leakcheck"
+	"go-concurrency-exercises/pkg/wal"
+)
+
+func TestProcess(t *testing.T) {
+	leakcheck.Check(t)
+
+	w := log.Writer()
+	log.SetOutput(io.Discard)
+	defer log.SetOutput(w)
+
+	var count, sum int64
+	process(context.Background(), 100, 5, func(_ context.Context, v int) error {
+		atomic.AddInt64(&count, 1)
+		atomic.AddInt64(&sum, int64(v))
+		return nil
+	})
+
+	if count != 100 {
+		t.Errorf("processed %d values, want 100", count)
+	}
+	if sum != 4950 {
+		t.Errorf("got sum %d, want 4950", sum)
+	}
+}
+
+func TestProcessDurable(t *testing.T) {
+	leakcheck.Check(t)
+
+	w := log.Writer()
+	log.SetOutput(io.Discard)
+	defer log.SetOutput(w)
+
+	dir := t.TempDir()
+
+	// Leave two values in the log, as a run that was killed would.
+	l, err := wal.Open(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, p := range []string{"100", "200"} {
+		if _, err := l.Append([]byte(p)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	l.Close()
+
+	var mu sync.Mutex
+	seen := make(map[int]int)
+	err = processDurable(context.Background(), dir, 10, 3, func(_ context.Context, v int) error {
+		mu.Lock()
+		seen[v]++
+		mu.Unlock()
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, v := range []int{0, 1, 2, 3, 4, 5, 6, 7, 8, 9, 100, 200} {
+		if seen[v] != 1 {
+			t.Errorf("value %d processed %d times, want 1", v, seen[v])
+		}
+	}
+
+	l, err = wal.Open(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer l.Close()
+	if n := len(l.Pending()); n != 0 {
+		t.Errorf("got %d values left in the log, want 0", n)
+	}
+}
+
+func TestProcessDurableCancelled(t *testing.T) {
+	leakcheck.Check(t)
+
+	w := log.Writer()
+	log.SetOutput(io.Discard)
+	defer log.SetOutput(w)
+
+	dir := t.TempDir()
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	// The first value's work is interrupted, as by Ctrl-C.
+	err := processDurable(ctx, dir, 10, 1, func(ctx context.Context, v int) error {
+		cancel()
+		<-ctx.Done()
+		return ctx.Err()
+	})
+	if err == nil {
+		t.Error("got no error from an interrupted run")
+	}
+
+	l, err := wal.Open(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer l.Close()
+	if len(l.Pending()) == 0 {
+		t.Error("got no values left in the log, want the interrupted ones")
+	}
+}
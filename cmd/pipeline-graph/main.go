@@ -0,0 +1,74 @@
+// pipeline-graph builds the generator -> square -> merge pipeline from the
+// exercises with the pipeline package and writes its shape as a Graphviz
+// DOT graph.
+//
+//	go run . -stats | dot -Tpng -o pipeline.png
+package main
+
+import (
+	"flag"
+	"log"
+	"os"
+	"time"
+
+	"go-concurrency-exercises/pkg/pipeline"
+)
+
+func main() {
+	n := flag.Int("n", 1000, "how many numbers to send through the pipeline")
+	workers := flag.Int("workers", 2, "how many square stages to fan out to")
+	capacity := flag.Int("cap", 0, "buffer size of each square stage's output channel")
+	stats := flag.Bool("stats", false, "run the pipeline and annotate each stage with its throughput")
+	interval := flag.Duration("interval", 0, "with -stats, also write the graph to stderr at this interval while the pipeline runs")
+	flag.Parse()
+
+	done := make(chan struct{})
+	if *stats {
+		defer close(done)
+	} else {
+		// Cancel the pipeline before it starts. The stages still register
+		// themselves, so the graph shows the pipeline's shape without
+		// running it.
+		close(done)
+	}
+
+	nums := make([]int, *n)
+	for i := range nums {
+		nums[i] = i
+	}
+
+	p := pipeline.New(done)
+	in := pipeline.Generator(p, "generator", nums...)
+
+	// Fan out: every square stage reads from the same channel.
+	cs := make([]<-chan int, *workers)
+	for i := range cs {
+		cs[i] = pipeline.Stage(p, "square", 1, *capacity, in, func(n int) int {
+			return n * n
+		})
+	}
+
+	out := pipeline.Merge(p, "merge", cs...)
+
+	if *stats && *interval > 0 {
+		ticker := time.NewTicker(*interval)
+		defer ticker.Stop()
+
+		go func() {
+			for {
+				select {
+				case <-ticker.C:
+					p.Graph().WriteDOT(os.Stderr, true)
+				case <-done:
+					return
+				}
+			}
+		}()
+	}
+
+	pipeline.Sink(p, "print", out, func(int) {})
+
+	if err := p.Graph().WriteDOT(os.Stdout, *stats); err != nil {
+		log.Fatal(err)
+	}
+}
@@ -0,0 +1,54 @@
+package main
+
+import (
+	"testing"
+
+	"go-concurrency-exercises/pkg/leakcheck"
+)
+
+func TestBasicPipeline(t *testing.T) {
+	leakcheck.Check(t)
+
+	var got []int
+	for n := range sq(sq(gen(2, 3))) {
+		got = append(got, n)
+	}
+
+	if len(got) != 2 || got[0] != 16 || got[1] != 81 {
+		t.Errorf("got %v, want [16 81]", got)
+	}
+}
+
+func TestFanoutFanin(t *testing.T) {
+	leakcheck.Check(t)
+
+	in := gen(2, 3)
+	c1 := sq(in)
+	c2 := sq(in)
+
+	sum := 0
+	for n := range merge(c1, c2) {
+		sum += n
+	}
+
+	if sum != 13 {
+		t.Errorf("got sum %d, want 13", sum)
+	}
+}
+
+func TestFanoutFaninWithDone(t *testing.T) {
+	leakcheck.Check(t)
+
+	done := make(chan struct{})
+
+	in := genWithDone(done, 2, 3)
+	c1 := sqWithDone(done, in)
+	c2 := sqWithDone(done, in)
+
+	out := mergeWithDone(done, c1, c2)
+	if n := <-out; n != 4 && n != 9 {
+		t.Errorf("got %d, want 4 or 9", n)
+	}
+
+	close(done)
+}
@@ -0,0 +1,270 @@
+// runner lists and runs the examples, exercises and solutions in the
+// repository, so they don't each have to be found and started by hand
+// with go run. Run it from the repository root.
+//
+//	go run ./cmd/runner list -kind exercise
+//	go run ./cmd/runner run -timeout 5s 04-sync/02-mutex
+//	go run ./cmd/runner compare 02-pipeline/03-pipeline
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"os"
+	"os/signal"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"go-concurrency-exercises/pkg/exercises"
+)
+
+const usage = `usage: runner [-root dir] <command> [flags] [args]
+
+Commands:
+  list [-kind k]                   list the examples with their README titles
+  run [flags] <name> [args...]     run an example, passing it args
+  compare [flags] <name> [args...] run an exercise and its solution side by side
+
+A name is an example's path from the root, such as 01-exercise/04-sync/02-mutex,
+or the end of it, such as 04-sync/02-mutex. When the end of a path names both
+an exercise and its solution, run picks the exercise unless -solution is given.
+`
+
+func main() {
+	root := flag.String("root", ".", "the repository root")
+	flag.Usage = func() {
+		fmt.Fprint(flag.CommandLine.Output(), usage)
+	}
+	flag.Parse()
+
+	if flag.NArg() == 0 {
+		flag.Usage()
+		os.Exit(2)
+	}
+
+	examples, err := exercises.Discover(*root)
+	if err != nil {
+		fatalf("finding examples: %v", err)
+	}
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt)
+	defer stop()
+
+	cmd, args := flag.Arg(0), flag.Args()[1:]
+	switch cmd {
+	case "list":
+		list(examples, args)
+	case "run":
+		os.Exit(run(ctx, examples, args))
+	case "compare":
+		os.Exit(compare(ctx, examples, args))
+	default:
+		flag.Usage()
+		os.Exit(2)
+	}
+}
+
+func list(examples []*exercises.Example, args []string) {
+	fs := flag.NewFlagSet("list", flag.ExitOnError)
+	kind := fs.String("kind", "", "only list examples of this kind: codewalk, exercise, solution or own")
+	fs.Parse(args)
+
+	tw := tabwriter.NewWriter(os.Stdout, 0, 4, 2, ' ', 0)
+	fmt.Fprintln(tw, "NAME\tKIND\tTITLE")
+	for _, e := range examples {
+		if *kind != "" && e.Kind.String() != *kind {
+			continue
+		}
+		fmt.Fprintf(tw, "%s\t%s\t%s\n", e.Name, e.Kind, e.Title)
+	}
+	tw.Flush()
+}
+
+func run(ctx context.Context, examples []*exercises.Example, args []string) int {
+	fs := flag.NewFlagSet("run", flag.ExitOnError)
+	timeout := fs.Duration("timeout", 30*time.Second, "kill the example if it runs for longer than this; 0 for no limit")
+	solution := fs.Bool("solution", false, "run the solution rather than the exercise")
+	race := fs.Bool("race", false, "build with the race detector")
+	fs.Parse(args)
+	if fs.NArg() == 0 {
+		fatalf("run: no example named")
+	}
+
+	e := pick(examples, fs.Arg(0), *solution)
+	fmt.Fprintf(os.Stderr, "== %s (%s): %s\n", e.Name, e.Kind, e.Title)
+
+	opts := []exercises.RunOption{
+		exercises.Args(fs.Args()[1:]...),
+		exercises.Timeout(*timeout),
+		exercises.Tee(os.Stdout, os.Stderr),
+	}
+	if *race {
+		opts = append(opts, exercises.Race())
+	}
+
+	r := exercises.Run(ctx, e, opts...)
+	fmt.Fprintf(os.Stderr, "== %s\n", status(r))
+	if !r.OK() {
+		return 1
+	}
+	return 0
+}
+
+func compare(ctx context.Context, examples []*exercises.Example, args []string) int {
+	fs := flag.NewFlagSet("compare", flag.ExitOnError)
+	timeout := fs.Duration("timeout", 30*time.Second, "kill either side if it runs for longer than this; 0 for no limit")
+	width := fs.Int("width", 160, "width of the side by side output")
+	fs.Parse(args)
+	if fs.NArg() == 0 {
+		fatalf("compare: no example named")
+	}
+
+	exercise, solution := pair(examples, fs.Arg(0))
+
+	// Run both at once; they are independent programs.
+	opts := []exercises.RunOption{exercises.Args(fs.Args()[1:]...), exercises.Timeout(*timeout)}
+	results := make(chan *exercises.Result)
+	for _, e := range []*exercises.Example{exercise, solution} {
+		go func(e *exercises.Example) {
+			results <- exercises.Run(ctx, e, opts...)
+		}(e)
+	}
+	r1, r2 := <-results, <-results
+	if r1.Example != exercise {
+		r1, r2 = r2, r1
+	}
+
+	fmt.Printf("%s: %s\n\n", exercise.Stem, exercise.Title)
+	same, _ := exercises.SideBySide(os.Stdout, *width,
+		exercises.Column{Title: exercise.Name + "\n" + status(r1), Text: output(r1)},
+		exercises.Column{Title: solution.Name + "\n" + status(r2), Text: output(r2)},
+	)
+
+	if len(r1.Stderr) > 0 || len(r2.Stderr) > 0 {
+		fmt.Println()
+		exercises.SideBySide(os.Stdout, *width,
+			exercises.Column{Title: "stderr", Text: r1.Stderr},
+			exercises.Column{Title: "stderr", Text: r2.Stderr},
+		)
+	}
+
+	fmt.Println()
+	switch {
+	case !same:
+		fmt.Println("Outputs differ.")
+		return 1
+	case r1.OK() != r2.OK() || r1.ExitCode != r2.ExitCode:
+		// The same output from a run that failed, timed out or exited
+		// differently is no match.
+		fmt.Println("Outputs match, but the exit statuses differ.")
+		return 1
+	}
+	fmt.Println("Outputs match.")
+	return 0
+}
+
+// pick returns the one example called name, preferring the exercise to
+// its solutions unless solution is true.
+func pick(examples []*exercises.Example, name string, solution bool) *exercises.Example {
+	found := exercises.Find(examples, name)
+	if len(found) == 1 {
+		return found[0]
+	}
+	ambiguous(name, found)
+
+	want := exercises.KindExercise
+	if solution {
+		want = exercises.KindSolution
+	}
+	for _, e := range found {
+		if e.Kind == want {
+			return e
+		}
+	}
+	fatalf("no %s called %s", want, name)
+	return nil
+}
+
+// pair returns the exercise and solution called name. If name is a
+// solution, it is compared with its exercise; otherwise the exercise is
+// compared with its first solution.
+func pair(examples []*exercises.Example, name string) (exercise, solution *exercises.Example) {
+	found := exercises.Find(examples, name)
+	if len(found) > 1 {
+		ambiguous(name, found)
+	}
+
+	for _, e := range found {
+		switch e.Kind {
+		case exercises.KindSolution:
+			if solution == nil {
+				solution = e
+			}
+		case exercises.KindExercise:
+			if exercise == nil {
+				exercise = e
+			}
+		}
+	}
+
+	switch {
+	case exercise == nil && solution == nil:
+		fatalf("no exercise or solution called %s", name)
+	case exercise == nil:
+		if cs := exercises.Counterparts(examples, solution); len(cs) > 0 {
+			exercise = cs[0]
+		}
+	case solution == nil:
+		if cs := exercises.Counterparts(examples, exercise); len(cs) > 0 {
+			solution = cs[0]
+		}
+	}
+	if exercise == nil || solution == nil {
+		fatalf("%s has no exercise and solution to compare", name)
+	}
+	return exercise, solution
+}
+
+// ambiguous exits if found holds more than one exercise: name is only
+// allowed to match an exercise and its solutions.
+func ambiguous(name string, found []*exercises.Example) {
+	if len(found) == 0 {
+		fatalf("no example called %s; see runner list", name)
+	}
+
+	for _, e := range found[1:] {
+		if e.Stem != found[0].Stem {
+			var names []string
+			for _, e := range found {
+				names = append(names, "  "+e.Name)
+			}
+			fatalf("%s could be any of:\n%s", name, strings.Join(names, "\n"))
+		}
+	}
+}
+
+// output is what the example wrote to stdout, or why it couldn't be run.
+func output(r *exercises.Result) []byte {
+	if r.Err != nil {
+		return []byte(r.Err.Error())
+	}
+	return r.Stdout
+}
+
+func status(r *exercises.Result) string {
+	switch {
+	case r.Err != nil:
+		return "failed to run"
+	case r.TimedOut:
+		return fmt.Sprintf("timed out after %v", r.Duration.Round(time.Millisecond))
+	default:
+		return fmt.Sprintf("exit status %d in %v", r.ExitCode, r.Duration.Round(time.Millisecond))
+	}
+}
+
+func fatalf(format string, args ...interface{}) {
+	fmt.Fprintf(os.Stderr, "runner: "+format+"\n", args...)
+	os.Exit(1)
+}
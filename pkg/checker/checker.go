@@ -0,0 +1,312 @@
+// Package checker tells learners whether they have finished an exercise.
+//
+// It runs the exercise with the race detector and tests what it printed,
+// either against a golden file recorded from the solution or with property
+// checks such as "the counter is 50000" or "load is printed once". Output
+// is normalised first, so timestamps, durations and addresses don't cause
+// failures, and output whose order depends on scheduling, such as the
+// order values leave a merge, is compared as a multiset of lines. Each
+// failed check comes with a hint pointing at what to fix.
+package checker
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"go-concurrency-exercises/pkg/exercises"
+)
+
+// raceExitCode is the exit status of a program in which the race
+// detector found a race.
+const raceExitCode = 66
+
+// DefaultTimeout is how long an exercise may run when its Spec doesn't
+// say.
+const DefaultTimeout = 30 * time.Second
+
+// Mode is how output is compared with a golden file.
+type Mode int
+
+const (
+	// Exact compares the lines in order.
+	Exact Mode = iota
+
+	// Multiset compares the lines in any order, for output whose order
+	// depends on how goroutines are scheduled.
+	Multiset
+)
+
+// Spec says how to check one exercise.
+type Spec struct {
+	// Stem names the exercise and its solutions; see exercises.Example.
+	Stem string
+
+	Args    []string
+	Timeout time.Duration
+
+	// Golden compares the output with a golden file recorded from the
+	// solution, in the given mode.
+	Golden bool
+	Mode   Mode
+
+	// Filter, if set, keeps only the lines matching it, before comparing
+	// with the golden file. Use it to ignore lines that the exercise and
+	// solution word differently.
+	Filter string
+
+	// GoldenHint is shown when the golden comparison fails.
+	GoldenHint string
+
+	Checks []Check
+}
+
+// Check is a property of an exercise's output.
+type Check struct {
+	// Name says what is checked, such as "balance is 0".
+	Name string
+
+	// Hint is shown when the check fails.
+	Hint string
+
+	// Test returns an error saying what is wrong with the output, or
+	// nil if it passes.
+	Test func(out *Output) error
+}
+
+// Output is what an exercise printed, normalised.
+type Output struct {
+	// Lines are the lines of stdout, normalised.
+	Lines []string
+
+	// Stderr is stderr, normalised.
+	Stderr []string
+}
+
+// Outcome is the result of one check.
+type Outcome struct {
+	Name string
+	Err  error
+	Hint string
+}
+
+// Passed reports whether the check passed.
+func (o Outcome) Passed() bool {
+	return o.Err == nil
+}
+
+// Report is the result of checking an exercise.
+type Report struct {
+	Example  *exercises.Example
+	Result   *exercises.Result
+	Outcomes []Outcome
+}
+
+// Passed reports whether every check passed.
+func (r *Report) Passed() bool {
+	for _, o := range r.Outcomes {
+		if !o.Passed() {
+			return false
+		}
+	}
+	return true
+}
+
+// Write writes the report: a PASS or FAIL line, followed by each check
+// that failed with its hint. With verbose, passed checks are listed too.
+func (r *Report) Write(w io.Writer, verbose bool) error {
+	status := "PASS"
+	if !r.Passed() {
+		status = "FAIL"
+	}
+	if _, err := fmt.Fprintf(w, "%s %s  %s\n", status, r.Example.Name, r.Example.Title); err != nil {
+		return err
+	}
+
+	for _, o := range r.Outcomes {
+		if o.Passed() {
+			if verbose {
+				fmt.Fprintf(w, "     ok  %s\n", o.Name)
+			}
+			continue
+		}
+		fmt.Fprintf(w, "     no  %s\n", o.Name)
+		for _, line := range strings.Split(o.Err.Error(), "\n") {
+			fmt.Fprintf(w, "           %s\n", line)
+		}
+		if o.Hint != "" {
+			fmt.Fprintf(w, "         hint: %s\n", o.Hint)
+		}
+	}
+	return nil
+}
+
+// Checker checks exercises against Specs.
+type Checker struct {
+	// GoldenDir holds the golden files, at <stem>.golden.
+	GoldenDir string
+}
+
+func (c *Checker) goldenPath(spec *Spec) string {
+	return filepath.Join(c.GoldenDir, filepath.FromSlash(spec.Stem)+".golden")
+}
+
+// Check runs e with the race detector and checks it against spec.
+func (c *Checker) Check(ctx context.Context, e *exercises.Example, spec *Spec) *Report {
+	r := exercises.Run(ctx, e, run(spec)...)
+	report := &Report{Example: e, Result: r}
+	add := func(name, hint string, err error) {
+		report.Outcomes = append(report.Outcomes, Outcome{Name: name, Err: err, Hint: hint})
+	}
+
+	// Nothing else can be checked if it doesn't build or run.
+	if r.Err != nil {
+		var berr *exercises.BuildError
+		if errors.As(r.Err, &berr) {
+			add("builds", "Fix the compile errors above, then check again.", errors.New(strings.TrimSpace(string(berr.Output))))
+		} else {
+			add("runs", "", r.Err)
+		}
+		return report
+	}
+
+	out := &Output{
+		Lines:  lines(r.Stdout),
+		Stderr: lines(r.Stderr),
+	}
+
+	raceErr := races(r.Stderr)
+	add("has no data races",
+		"Two goroutines touch the same variable without synchronisation. Guard it with a mutex, use an atomic, or pass it over a channel.",
+		raceErr)
+
+	switch {
+	case raceErr != nil && r.ExitCode == raceExitCode:
+		// The race detector set the exit status; that is reported above.
+	case r.TimedOut:
+		add("finishes in time",
+			"It may be deadlocked, waiting on a channel no one sends on or a WaitGroup that never reaches zero.",
+			fmt.Errorf("still running after %v", timeout(spec)))
+	case r.ExitCode != 0:
+		add("exits with status 0", "", fmt.Errorf("exit status %d%s", r.ExitCode, tail(out.Stderr)))
+	default:
+		add("exits with status 0", "", nil)
+	}
+
+	if spec.Golden {
+		name := "prints the same as the solution"
+		if spec.Mode == Multiset {
+			name = "prints the same lines as the solution, in any order"
+		}
+		add(name, spec.GoldenHint, c.compareGolden(spec, out))
+	}
+
+	for _, check := range spec.Checks {
+		add(check.Name, check.Hint, check.Test(out))
+	}
+
+	return report
+}
+
+// Update runs solution and records its output as spec's golden file.
+func (c *Checker) Update(ctx context.Context, solution *exercises.Example, spec *Spec) error {
+	r := exercises.Run(ctx, solution, run(spec)...)
+	switch {
+	case r.Err != nil:
+		return r.Err
+	case r.TimedOut:
+		return fmt.Errorf("%s timed out", solution.Name)
+	case r.ExitCode != 0:
+		return fmt.Errorf("%s exited with status %d", solution.Name, r.ExitCode)
+	}
+
+	golden, err := filter(spec, lines(r.Stdout))
+	if err != nil {
+		return err
+	}
+
+	path := c.goldenPath(spec)
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		return err
+	}
+	return os.WriteFile(path, []byte(strings.Join(golden, "\n")+"\n"), 0o644)
+}
+
+func (c *Checker) compareGolden(spec *Spec, out *Output) error {
+	data, err := os.ReadFile(c.goldenPath(spec))
+	if err != nil {
+		return fmt.Errorf("no golden file; record one from the solution with -update: %v", err)
+	}
+
+	want := lines(data)
+	got, err := filter(spec, out.Lines)
+	if err != nil {
+		return err
+	}
+
+	if spec.Mode == Multiset {
+		return compareMultiset(got, want)
+	}
+	return compareExact(got, want)
+}
+
+func run(spec *Spec) []exercises.RunOption {
+	return []exercises.RunOption{
+		exercises.Race(),
+		exercises.Args(spec.Args...),
+		exercises.Timeout(timeout(spec)),
+	}
+}
+
+func timeout(spec *Spec) time.Duration {
+	if spec.Timeout > 0 {
+		return spec.Timeout
+	}
+	return DefaultTimeout
+}
+
+// races returns an error quoting the first data race the race detector
+// reported, if any.
+func races(stderr []byte) error {
+	const marker = "WARNING: DATA RACE"
+
+	s := string(stderr)
+	i := strings.Index(s, marker)
+	if i < 0 {
+		return nil
+	}
+
+	n := strings.Count(s, marker)
+	report := s[i:]
+	if end := strings.Index(report, "=================="); end >= 0 {
+		report = report[:end]
+	}
+
+	// The first access of each goroutine is enough to point at the
+	// variable.
+	var keep []string
+	for _, line := range strings.Split(strings.TrimSpace(report), "\n") {
+		keep = append(keep, line)
+		if len(keep) == 12 {
+			keep = append(keep, "...")
+			break
+		}
+	}
+	return fmt.Errorf("the race detector found %d data race(s); the first:\n%s", n, strings.Join(keep, "\n"))
+}
+
+// tail returns the last few lines of stderr, to show why a program failed.
+func tail(stderr []string) string {
+	if len(stderr) == 0 {
+		return ""
+	}
+	if len(stderr) > 5 {
+		stderr = stderr[len(stderr)-5:]
+	}
+	return ":\n" + strings.Join(stderr, "\n")
+}
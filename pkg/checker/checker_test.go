@@ -0,0 +1,262 @@
+package checker
+
+import (
+	"bytes"
+	"context"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"go-concurrency-exercises/pkg/exercises"
+	"go-concurrency-exercises/pkg/leakcheck"
+)
+
+const racy = `package main
+
+import (
+	"fmt"
+	"sync"
+)
+
+func main() {
+	var wg sync.WaitGroup
+	counter := 0
+	for i := 0; i < 2; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for j := 0; j < 1000; j++ {
+				counter++
+			}
+		}()
+	}
+	wg.Wait()
+	fmt.Println("counter:", counter)
+}
+`
+
+const fixed = `package main
+
+import (
+	"fmt"
+	"sync"
+)
+
+func main() {
+	var (
+		wg      sync.WaitGroup
+		mu      sync.Mutex
+		counter int
+	)
+	for i := 0; i < 2; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for j := 0; j < 1000; j++ {
+				mu.Lock()
+				counter++
+				mu.Unlock()
+			}
+		}()
+	}
+	wg.Wait()
+	fmt.Println("counter:", counter)
+}
+`
+
+// merge prints 1 to 3 in an order given by its arguments, as a merge of
+// channels might.
+const merge = `package main
+
+import (
+	"fmt"
+	"os"
+	"time"
+)
+
+func main() {
+	fmt.Println("start", time.Now().Format("15:04:05"))
+	for _, a := range os.Args[1:] {
+		fmt.Println(a)
+	}
+}
+`
+
+// fixture discovers an exercise and its solution written from the given
+// sources.
+func fixture(t *testing.T, exercise, solution string) (*exercises.Example, *exercises.Example) {
+	t.Helper()
+
+	root := t.TempDir()
+	for dir, src := range map[string]string{
+		"01-exercise/04-sync/11-atomic":          exercise,
+		"01-exercise-solution/04-sync/11-atomic": solution,
+	} {
+		p := filepath.Join(root, filepath.FromSlash(dir), "main.go")
+		if err := os.MkdirAll(filepath.Dir(p), 0o755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(p, []byte(src), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	examples, err := exercises.Discover(root)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var e, s *exercises.Example
+	for _, x := range examples {
+		switch x.Kind {
+		case exercises.KindExercise:
+			e = x
+		case exercises.KindSolution:
+			s = x
+		}
+	}
+	if e == nil || s == nil {
+		t.Fatalf("got %d examples, want an exercise and a solution", len(examples))
+	}
+	return e, s
+}
+
+func outcome(t *testing.T, r *Report, name string) Outcome {
+	t.Helper()
+
+	for _, o := range r.Outcomes {
+		if o.Name == name {
+			return o
+		}
+	}
+	t.Fatalf("no check named %q in %v", name, r.Outcomes)
+	return Outcome{}
+}
+
+func TestCheckRace(t *testing.T) {
+	leakcheck.Check(t)
+
+	e, s := fixture(t, racy, fixed)
+	spec := &Spec{
+		Stem:   "01-exercise/04-sync/11-atomic",
+		Checks: []Check{Value("counter is 2000", `counter: (\d+)`, "2000", "")},
+	}
+	c := &Checker{GoldenDir: t.TempDir()}
+
+	r := c.Check(context.Background(), e, spec)
+	if r.Passed() {
+		t.Fatal("the racy exercise passed")
+	}
+	if o := outcome(t, r, "has no data races"); o.Passed() || !strings.Contains(o.Err.Error(), "DATA RACE") {
+		t.Errorf("got %v, want the race report", o.Err)
+	}
+
+	var buf bytes.Buffer
+	if err := r.Write(&buf, false); err != nil {
+		t.Fatal(err)
+	}
+	if !strings.HasPrefix(buf.String(), "FAIL 01-exercise/04-sync/11-atomic") || !strings.Contains(buf.String(), "hint:") {
+		t.Errorf("got report\n%s", buf.String())
+	}
+
+	r = c.Check(context.Background(), s, spec)
+	if !r.Passed() {
+		var buf bytes.Buffer
+		r.Write(&buf, true)
+		t.Errorf("the solution failed:\n%s", buf.String())
+	}
+}
+
+func TestCheckGolden(t *testing.T) {
+	leakcheck.Check(t)
+
+	e, s := fixture(t, merge, merge)
+	c := &Checker{GoldenDir: t.TempDir()}
+
+	exact := &Spec{Stem: "01-exercise/04-sync/11-atomic", Args: []string{"1", "2", "3"}, Golden: true}
+	if err := c.Update(context.Background(), s, exact); err != nil {
+		t.Fatal(err)
+	}
+	golden, err := os.ReadFile(filepath.Join(c.GoldenDir, "01-exercise", "04-sync", "11-atomic.golden"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got, want := string(golden), "start <time>\n1\n2\n3\n"; got != want {
+		t.Errorf("recorded %q, want %q", got, want)
+	}
+
+	tests := []struct {
+		mode   Mode
+		args   []string
+		passed bool
+	}{
+		{Exact, []string{"1", "2", "3"}, true},
+		{Exact, []string{"3", "1", "2"}, false},
+		{Multiset, []string{"3", "1", "2"}, true},
+		{Multiset, []string{"3", "1", "1"}, false},
+	}
+	for _, tt := range tests {
+		spec := &Spec{Stem: exact.Stem, Args: tt.args, Golden: true, Mode: tt.mode}
+		r := c.Check(context.Background(), e, spec)
+		if r.Passed() != tt.passed {
+			var buf bytes.Buffer
+			r.Write(&buf, true)
+			t.Errorf("mode %d, args %v: got passed %v, want %v:\n%s", tt.mode, tt.args, r.Passed(), tt.passed, buf.String())
+		}
+	}
+}
+
+func TestCheckTimeout(t *testing.T) {
+	leakcheck.Check(t)
+
+	e, _ := fixture(t, "package main\n\nfunc main() { select {} }\n", fixed)
+	spec := &Spec{Stem: "01-exercise/04-sync/11-atomic", Timeout: time.Second}
+
+	r := (&Checker{}).Check(context.Background(), e, spec)
+	if r.Passed() {
+		t.Fatal("a program that never ends passed")
+	}
+	// A program blocked forever is either killed or found deadlocked by
+	// the runtime.
+	o := r.Outcomes[len(r.Outcomes)-1]
+	if o.Passed() {
+		t.Errorf("got %q passed", o.Name)
+	}
+}
+
+func TestCheckBuildError(t *testing.T) {
+	leakcheck.Check(t)
+
+	e, _ := fixture(t, "package main\n\nfunc main() { undefined() }\n", fixed)
+	r := (&Checker{}).Check(context.Background(), e, &Spec{Stem: "01-exercise/04-sync/11-atomic"})
+
+	if len(r.Outcomes) != 1 || r.Outcomes[0].Name != "builds" || r.Passed() {
+		t.Errorf("got %v, want a failed build", r.Outcomes)
+	}
+}
+
+func TestChecks(t *testing.T) {
+	out := &Output{Lines: []string{"load", "balance: 0", "counter: 50000", "done"}}
+
+	tests := []struct {
+		check  Check
+		passed bool
+	}{
+		{Contains("balance", ""), true},
+		{Contains("missing", ""), false},
+		{Count("load once", `^load$`, 1, ""), true},
+		{Count("load twice", `^load$`, 2, ""), false},
+		{Value("balance is 0", `balance: (-?\d+)`, "0", ""), true},
+		{Value("counter is 1", `counter: (\d+)`, "1", ""), false},
+		{Value("no such line", `total: (\d+)`, "1", ""), false},
+		{LastLine("ends with done", "done", ""), true},
+		{LastLine("ends with load", "load", ""), false},
+	}
+	for _, tt := range tests {
+		err := tt.check.Test(out)
+		if (err == nil) != tt.passed {
+			t.Errorf("%s: got %v, want passed %v", tt.check.Name, err, tt.passed)
+		}
+	}
+}
@@ -0,0 +1,101 @@
+package checker
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+)
+
+// Contains checks that a line of output contains s.
+func Contains(s, hint string) Check {
+	return Check{
+		Name: fmt.Sprintf("prints %q", s),
+		Hint: hint,
+		Test: func(out *Output) error {
+			for _, l := range out.Lines {
+				if strings.Contains(l, s) {
+					return nil
+				}
+			}
+			return fmt.Errorf("no line contains %q%s", s, quote(out.Lines))
+		},
+	}
+}
+
+// Count checks that exactly n lines of output match the regular
+// expression re, such as a message that must be printed only once.
+func Count(name, re string, n int, hint string) Check {
+	r := regexp.MustCompile(re)
+	return Check{
+		Name: name,
+		Hint: hint,
+		Test: func(out *Output) error {
+			got := 0
+			for _, l := range out.Lines {
+				if r.MatchString(l) {
+					got++
+				}
+			}
+			if got != n {
+				return fmt.Errorf("%d lines match %q, want %d%s", got, re, n, quote(out.Lines))
+			}
+			return nil
+		},
+	}
+}
+
+// Value checks that the output has a line matching the regular expression
+// re, and that the text its first group captures is want on every line it
+// matches, such as the final value of a counter.
+func Value(name, re, want, hint string) Check {
+	r := regexp.MustCompile(re)
+	return Check{
+		Name: name,
+		Hint: hint,
+		Test: func(out *Output) error {
+			found := false
+			for _, l := range out.Lines {
+				m := r.FindStringSubmatch(l)
+				if m == nil {
+					continue
+				}
+				found = true
+				if m[1] != want {
+					return fmt.Errorf("got %s, want %s (from the line %q)", m[1], want, l)
+				}
+			}
+			if !found {
+				return fmt.Errorf("no line matches %q%s", re, quote(out.Lines))
+			}
+			return nil
+		},
+	}
+}
+
+// LastLine checks that the last line of output is want.
+func LastLine(name, want, hint string) Check {
+	return Check{
+		Name: name,
+		Hint: hint,
+		Test: func(out *Output) error {
+			if len(out.Lines) == 0 {
+				return fmt.Errorf("printed nothing, want a last line of %q", want)
+			}
+			if got := out.Lines[len(out.Lines)-1]; got != want {
+				return fmt.Errorf("last line is %q, want %q", got, want)
+			}
+			return nil
+		},
+	}
+}
+
+// quote shows the output in an error message, if it is short.
+func quote(ls []string) string {
+	switch {
+	case len(ls) == 0:
+		return "; it printed nothing"
+	case len(ls) > 10:
+		return ""
+	}
+	return "; it printed:\n" + strings.Join(ls, "\n")
+}
@@ -0,0 +1,130 @@
+package checker
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+)
+
+var (
+	// logPrefixRE matches the date and time the log package puts at the
+	// start of a line.
+	logPrefixRE = regexp.MustCompile(`^\d{4}/\d{2}/\d{2} \d{2}:\d{2}:\d{2}(\.\d+)? `)
+
+	clockRE    = regexp.MustCompile(`\b\d{2}:\d{2}:\d{2}(\.\d+)?\b`)
+	durationRE = regexp.MustCompile(`\b(\d+(\.\d+)?(ns|µs|us|ms|s|m|h))+\b`)
+	addressRE  = regexp.MustCompile(`\b0x[0-9a-fA-F]+\b`)
+)
+
+// Normalize rewrites a line of output so it is the same from run to run:
+// log timestamps are removed, and times of day, durations and hex
+// addresses are replaced by <time>, <duration> and <addr>. Trailing space
+// is trimmed.
+func Normalize(line string) string {
+	line = strings.TrimRight(line, " \t\r")
+	line = logPrefixRE.ReplaceAllString(line, "")
+	line = clockRE.ReplaceAllString(line, "<time>")
+	line = durationRE.ReplaceAllString(line, "<duration>")
+	line = addressRE.ReplaceAllString(line, "<addr>")
+	return line
+}
+
+// lines splits output into normalised lines, dropping blank lines at the
+// start and end.
+func lines(output []byte) []string {
+	s := strings.Trim(strings.ReplaceAll(string(output), "\r\n", "\n"), "\n")
+	if strings.TrimSpace(s) == "" {
+		return nil
+	}
+
+	ls := strings.Split(s, "\n")
+	for i, l := range ls {
+		ls[i] = Normalize(l)
+	}
+	return ls
+}
+
+// filter keeps the lines matching spec's Filter, if it has one.
+func filter(spec *Spec, ls []string) ([]string, error) {
+	if spec.Filter == "" {
+		return ls, nil
+	}
+
+	re, err := regexp.Compile(spec.Filter)
+	if err != nil {
+		return nil, fmt.Errorf("bad filter for %s: %v", spec.Stem, err)
+	}
+
+	var kept []string
+	for _, l := range ls {
+		if re.MatchString(l) {
+			kept = append(kept, l)
+		}
+	}
+	return kept, nil
+}
+
+// compareExact returns an error describing the first difference between
+// got and want.
+func compareExact(got, want []string) error {
+	for i := 0; i < len(got) || i < len(want); i++ {
+		switch {
+		case len(got) == 0:
+			return fmt.Errorf("printed nothing; line 1 should be %q", want[0])
+		case i >= len(got):
+			return fmt.Errorf("output stops after %d lines; line %d should be %q", len(got), i+1, want[i])
+		case i >= len(want):
+			return fmt.Errorf("output goes on after the expected %d lines; line %d is %q", len(want), i+1, got[i])
+		case got[i] != want[i]:
+			return fmt.Errorf("line %d is %q, want %q", i+1, got[i], want[i])
+		}
+	}
+	return nil
+}
+
+// compareMultiset returns an error listing the lines missing from got and
+// the extra lines in it, ignoring order.
+func compareMultiset(got, want []string) error {
+	counts := make(map[string]int)
+	for _, l := range want {
+		counts[l]++
+	}
+	for _, l := range got {
+		counts[l]--
+	}
+
+	var missing, extra []string
+	// Walk want then got so the lines are listed in a stable order.
+	for _, l := range append(append([]string(nil), want...), got...) {
+		n := counts[l]
+		switch {
+		case n > 0:
+			missing = append(missing, fmt.Sprintf("%q ×%d", l, n))
+		case n < 0:
+			extra = append(extra, fmt.Sprintf("%q ×%d", l, -n))
+		}
+		delete(counts, l)
+	}
+
+	if len(missing) == 0 && len(extra) == 0 {
+		return nil
+	}
+
+	var msg []string
+	if len(missing) > 0 {
+		msg = append(msg, "missing lines: "+strings.Join(limit(missing), ", "))
+	}
+	if len(extra) > 0 {
+		msg = append(msg, "unexpected lines: "+strings.Join(limit(extra), ", "))
+	}
+	return fmt.Errorf("%s", strings.Join(msg, "\n"))
+}
+
+// limit shortens a list of lines for a message.
+func limit(ls []string) []string {
+	const max = 5
+	if len(ls) <= max {
+		return ls
+	}
+	return append(ls[:max:max], fmt.Sprintf("and %d more", len(ls)-max))
+}
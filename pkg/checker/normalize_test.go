@@ -0,0 +1,90 @@
+package checker
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestNormalize(t *testing.T) {
+	tests := []struct {
+		line, want string
+	}{
+		{"2024/01/02 15:04:05 starting  ", "starting"},
+		{"2024/01/02 15:04:05.123456 done", "done"},
+		{"at 15:04:05 it ended", "at <time> it ended"},
+		{"took 1.5s", "took <duration>"},
+		{"elapsed: 842.514µs", "elapsed: <duration>"},
+		{"waited 1m30s, then 20ms", "waited <duration>, then <duration>"},
+		{"stored at 0xc000012345", "stored at <addr>"},
+		{"counter: 50000", "counter: 50000"},
+	}
+	for _, tt := range tests {
+		if got := Normalize(tt.line); got != tt.want {
+			t.Errorf("Normalize(%q) = %q, want %q", tt.line, got, tt.want)
+		}
+	}
+}
+
+func TestLines(t *testing.T) {
+	got := lines([]byte("\none\r\ntwo 3ms\n\n"))
+	if want := []string{"one", "two <duration>"}; strings.Join(got, "|") != strings.Join(want, "|") {
+		t.Errorf("got %q, want %q", got, want)
+	}
+	if got := lines([]byte(" \n\n")); got != nil {
+		t.Errorf("got %q for blank output, want nil", got)
+	}
+}
+
+func TestCompareExact(t *testing.T) {
+	want := []string{"a", "b"}
+	tests := []struct {
+		got []string
+		err string
+	}{
+		{[]string{"a", "b"}, ""},
+		{[]string{"a"}, `output stops after 1 lines; line 2 should be "b"`},
+		{[]string{"a", "b", "c"}, `output goes on after the expected 2 lines; line 3 is "c"`},
+		{[]string{"a", "x"}, `line 2 is "x", want "b"`},
+	}
+	for _, tt := range tests {
+		err := compareExact(tt.got, want)
+		if got := errString(err); got != tt.err {
+			t.Errorf("compareExact(%q) = %q, want %q", tt.got, got, tt.err)
+		}
+	}
+}
+
+func TestCompareMultiset(t *testing.T) {
+	want := []string{"1", "2", "2", "3"}
+
+	if err := compareMultiset([]string{"3", "2", "1", "2"}, want); err != nil {
+		t.Errorf("got %v for a reordering", err)
+	}
+
+	err := compareMultiset([]string{"3", "2", "1", "4", "4"}, want)
+	if got, want := errString(err), "missing lines: \"2\" ×1\nunexpected lines: \"4\" ×2"; got != want {
+		t.Errorf("got %q, want %q", got, want)
+	}
+}
+
+func TestFilter(t *testing.T) {
+	spec := &Spec{Stem: "x", Filter: `^\d+$`}
+	got, err := filter(spec, []string{"Received 1", "1", "2", "done"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if strings.Join(got, ",") != "1,2" {
+		t.Errorf("got %q, want [1 2]", got)
+	}
+
+	if _, err := filter(&Spec{Stem: "x", Filter: "("}, nil); err == nil {
+		t.Error("got no error for a bad filter")
+	}
+}
+
+func errString(err error) string {
+	if err == nil {
+		return ""
+	}
+	return err.Error()
+}
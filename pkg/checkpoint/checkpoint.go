@@ -0,0 +1,408 @@
+// Package checkpoint records which items a pipeline has finished, so a run
+// that crashes or is stopped can carry on where it left off instead of
+// starting again from scratch.
+//
+// Completed item keys are appended to a journal file. Writes are batched:
+// Mark adds a key to the current batch, and the batch is written and
+// synced to disk once it is full, when the flush interval passes, or when
+// Flush or Close is called. A key marked but not yet flushed when the
+// process dies is simply processed again on the next run, so items are
+// processed at least once.
+//
+// Each line of the journal holds a checksum followed by the quoted key.
+// A line that was only partly written when the process died fails its
+// checksum, and it and anything after it is dropped when the journal is
+// opened again.
+package checkpoint
+
+import (
+	"bufio"
+	"bytes"
+	"errors"
+	"fmt"
+	"hash/crc32"
+	"io"
+	"os"
+	"path/filepath"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// Default batching settings.
+const (
+	DefaultBatchSize     = 64
+	DefaultFlushInterval = time.Second
+)
+
+// ErrClosed is returned when using a journal after Close.
+var ErrClosed = errors.New("checkpoint: journal closed")
+
+// Option configures a Journal.
+type Option func(*Journal)
+
+// BatchSize sets how many keys are collected before they are written to
+// disk together.
+func BatchSize(n int) Option {
+	return func(j *Journal) {
+		if n > 0 {
+			j.batchSize = n
+		}
+	}
+}
+
+// FlushInterval sets how long a key can wait in a batch before it is
+// written to disk. Zero means keys are only written when the batch is full
+// or Flush is called.
+func FlushInterval(d time.Duration) Option {
+	return func(j *Journal) {
+		j.interval = d
+	}
+}
+
+// Journal is an append-only record of completed item keys. It is safe for
+// concurrent use.
+type Journal struct {
+	path      string
+	batchSize int
+	interval  time.Duration
+
+	mu        sync.Mutex
+	f         *os.File
+	completed map[string]bool
+	batch     []string
+	records   int
+	err       error
+	closed    bool
+
+	stop    chan struct{}
+	stopped chan struct{}
+}
+
+// Open opens the journal at path, creating it if it does not exist, and
+// loads the keys already recorded in it.
+func Open(path string, opts ...Option) (*Journal, error) {
+	j := &Journal{
+		path:      path,
+		batchSize: DefaultBatchSize,
+		interval:  DefaultFlushInterval,
+		completed: make(map[string]bool),
+		stop:      make(chan struct{}),
+		stopped:   make(chan struct{}),
+	}
+	for _, opt := range opts {
+		opt(j)
+	}
+
+	f, err := os.OpenFile(path, os.O_RDWR|os.O_CREATE, 0o644)
+	if err != nil {
+		return nil, err
+	}
+
+	good, err := j.load(f)
+	if err != nil {
+		f.Close()
+		return nil, err
+	}
+
+	// Drop anything after the last good record, such as a line that
+	// was only partly written, so new records follow on from it.
+	if err := f.Truncate(good); err != nil {
+		f.Close()
+		return nil, err
+	}
+	if _, err := f.Seek(good, io.SeekStart); err != nil {
+		f.Close()
+		return nil, err
+	}
+	j.f = f
+
+	if j.interval > 0 {
+		go j.flusher()
+	} else {
+		close(j.stopped)
+	}
+
+	return j, nil
+}
+
+// load reads the records in f and returns the offset just after the
+// last good one.
+func (j *Journal) load(f *os.File) (int64, error) {
+	r := bufio.NewReader(f)
+
+	var good int64
+	for {
+		line, err := r.ReadBytes('\n')
+		if err == io.EOF {
+			// A line without a newline was cut short.
+			return good, nil
+		}
+		if err != nil {
+			return 0, err
+		}
+
+		key, ok := decode(line)
+		if !ok {
+			return good, nil
+		}
+
+		j.completed[key] = true
+		j.records++
+		good += int64(len(line))
+	}
+}
+
+// Completed reports whether key has been marked as completed, in this run
+// or an earlier one.
+func (j *Journal) Completed(key string) bool {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+
+	return j.completed[key]
+}
+
+// Len returns the number of completed keys.
+func (j *Journal) Len() int {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+
+	return len(j.completed)
+}
+
+// Mark records key as completed. The key is written to disk with the rest
+// of its batch, and if writing an earlier batch failed, Mark returns that
+// error.
+func (j *Journal) Mark(key string) error {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+
+	if j.closed {
+		return ErrClosed
+	}
+	if j.err != nil {
+		return j.err
+	}
+	if j.completed[key] {
+		return nil
+	}
+
+	j.completed[key] = true
+	j.batch = append(j.batch, key)
+	if len(j.batch) >= j.batchSize {
+		return j.flush()
+	}
+	return nil
+}
+
+// Flush writes the current batch to disk and waits for it to be synced.
+func (j *Journal) Flush() error {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+
+	if j.closed {
+		return ErrClosed
+	}
+	return j.flush()
+}
+
+// flush writes the batch in a single write and syncs the file.
+// j.mu must be held.
+func (j *Journal) flush() error {
+	if j.err != nil {
+		return j.err
+	}
+	if len(j.batch) == 0 {
+		return nil
+	}
+
+	var buf bytes.Buffer
+	for _, key := range j.batch {
+		buf.Write(encode(key))
+	}
+
+	if _, err := j.f.Write(buf.Bytes()); err != nil {
+		j.err = fmt.Errorf("checkpoint: writing %s: %w", j.path, err)
+		return j.err
+	}
+	if err := j.f.Sync(); err != nil {
+		j.err = fmt.Errorf("checkpoint: syncing %s: %w", j.path, err)
+		return j.err
+	}
+
+	j.records += len(j.batch)
+	j.batch = j.batch[:0]
+	return nil
+}
+
+// flusher writes the batch every flush interval until the journal is
+// closed.
+func (j *Journal) flusher() {
+	defer close(j.stopped)
+
+	ticker := time.NewTicker(j.interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ticker.C:
+			j.mu.Lock()
+			// Errors are kept in j.err and returned by the next
+			// call to Mark, Flush or Close.
+			j.flush()
+			j.mu.Unlock()
+		case <-j.stop:
+			return
+		}
+	}
+}
+
+// Compact rewrites the journal so it only holds the completed keys for
+// which keep returns true, once each. Use it to drop keys for items that
+// no longer exist. A nil keep keeps every key.
+//
+// The new journal is written to a temporary file and synced before it
+// replaces the old one, so a crash during Compact leaves one or the other
+// in place. An error syncing the directory after the rename is returned,
+// but the journal carries on with the new file.
+func (j *Journal) Compact(keep func(key string) bool) error {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+
+	if j.closed {
+		return ErrClosed
+	}
+	if err := j.flush(); err != nil {
+		return err
+	}
+
+	completed := make(map[string]bool, len(j.completed))
+	var buf bytes.Buffer
+	for key := range j.completed {
+		if keep != nil && !keep(key) {
+			continue
+		}
+		completed[key] = true
+		buf.Write(encode(key))
+	}
+
+	tmp := j.path + ".tmp"
+	if err := writeFile(tmp, buf.Bytes()); err != nil {
+		os.Remove(tmp)
+		return fmt.Errorf("checkpoint: compacting %s: %w", j.path, err)
+	}
+	if err := os.Rename(tmp, j.path); err != nil {
+		os.Remove(tmp)
+		return fmt.Errorf("checkpoint: compacting %s: %w", j.path, err)
+	}
+
+	// The open file is the old journal, which has just been
+	// replaced, so switch to the new one before anything else can
+	// fail, or later keys would be written to the old file and lost.
+	f, err := os.OpenFile(j.path, os.O_WRONLY|os.O_APPEND, 0o644)
+	if err != nil {
+		j.err = fmt.Errorf("checkpoint: reopening %s: %w", j.path, err)
+		return j.err
+	}
+	j.f.Close()
+	j.f = f
+	j.completed = completed
+	j.records = len(completed)
+
+	// The journal is usable either way; a crash before the rename
+	// reaches the disk just leaves the old journal in place.
+	if err := syncDir(filepath.Dir(j.path)); err != nil {
+		return fmt.Errorf("checkpoint: compacting %s: %w", j.path, err)
+	}
+	return nil
+}
+
+// Records returns the number of records in the journal file, which is
+// more than Len if keys were written more than once.
+func (j *Journal) Records() int {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+
+	return j.records
+}
+
+// Close writes any keys still in the batch and closes the journal.
+func (j *Journal) Close() error {
+	j.mu.Lock()
+	if j.closed {
+		j.mu.Unlock()
+		return ErrClosed
+	}
+	j.closed = true
+	j.mu.Unlock()
+
+	close(j.stop)
+	<-j.stopped
+
+	j.mu.Lock()
+	defer j.mu.Unlock()
+
+	err := j.flush()
+	if cerr := j.f.Close(); err == nil {
+		err = cerr
+	}
+	return err
+}
+
+// encode returns the journal line for key.
+func encode(key string) []byte {
+	q := strconv.Quote(key)
+	return []byte(fmt.Sprintf("%08x %s\n", crc32.ChecksumIEEE([]byte(q)), q))
+}
+
+// decode returns the key in a journal line, and false if the line is
+// damaged.
+func decode(line []byte) (string, bool) {
+	line = bytes.TrimSuffix(line, []byte("\n"))
+	if len(line) < 10 || line[8] != ' ' {
+		return "", false
+	}
+
+	sum, err := strconv.ParseUint(string(line[:8]), 16, 32)
+	if err != nil {
+		return "", false
+	}
+	q := line[9:]
+	if crc32.ChecksumIEEE(q) != uint32(sum) {
+		return "", false
+	}
+
+	key, err := strconv.Unquote(string(q))
+	if err != nil {
+		return "", false
+	}
+	return key, true
+}
+
+// writeFile writes data to a new file at path and syncs it.
+func writeFile(path string, data []byte) error {
+	f, err := os.OpenFile(path, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, 0o644)
+	if err != nil {
+		return err
+	}
+	if _, err := f.Write(data); err != nil {
+		f.Close()
+		return err
+	}
+	if err := f.Sync(); err != nil {
+		f.Close()
+		return err
+	}
+	return f.Close()
+}
+
+// syncDir syncs the directory at path, so a rename in it is on disk.
+func syncDir(path string) error {
+	d, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer d.Close()
+
+	return d.Sync()
+}
@@ -0,0 +1,230 @@
+package checkpoint
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"go-concurrency-exercises/pkg/leakcheck"
+)
+
+func open(t *testing.T, path string, opts ...Option) *Journal {
+	t.Helper()
+
+	j, err := Open(path, opts...)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return j
+}
+
+func mark(t *testing.T, j *Journal, keys ...string) {
+	t.Helper()
+
+	for _, key := range keys {
+		if err := j.Mark(key); err != nil {
+			t.Fatal(err)
+		}
+	}
+}
+
+func lines(t *testing.T, path string) int {
+	t.Helper()
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return strings.Count(string(data), "\n")
+}
+
+func TestReopen(t *testing.T) {
+	leakcheck.Check(t)
+
+	path := filepath.Join(t.TempDir(), "journal")
+
+	j := open(t, path)
+	mark(t, j, "a.jpg", "b.jpg", "odd\nname.jpg", "a.jpg")
+	if err := j.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	j = open(t, path)
+	defer j.Close()
+
+	for _, key := range []string{"a.jpg", "b.jpg", "odd\nname.jpg"} {
+		if !j.Completed(key) {
+			t.Errorf("%q not completed after reopening", key)
+		}
+	}
+	if j.Completed("c.jpg") {
+		t.Error("c.jpg completed but never marked")
+	}
+	if j.Len() != 3 || j.Records() != 3 {
+		t.Errorf("got %d keys in %d records, want 3 in 3", j.Len(), j.Records())
+	}
+}
+
+func TestBatching(t *testing.T) {
+	leakcheck.Check(t)
+
+	path := filepath.Join(t.TempDir(), "journal")
+
+	j := open(t, path, BatchSize(3), FlushInterval(0))
+	defer j.Close()
+
+	mark(t, j, "1", "2")
+	if n := lines(t, path); n != 0 {
+		t.Errorf("got %d records on disk before the batch is full, want 0", n)
+	}
+
+	mark(t, j, "3")
+	if n := lines(t, path); n != 3 {
+		t.Errorf("got %d records on disk after the batch is full, want 3", n)
+	}
+
+	mark(t, j, "4")
+	if err := j.Flush(); err != nil {
+		t.Fatal(err)
+	}
+	if n := lines(t, path); n != 4 {
+		t.Errorf("got %d records on disk after Flush, want 4", n)
+	}
+}
+
+func TestFlushInterval(t *testing.T) {
+	leakcheck.Check(t)
+
+	path := filepath.Join(t.TempDir(), "journal")
+
+	j := open(t, path, BatchSize(100), FlushInterval(5*time.Millisecond))
+	defer j.Close()
+
+	mark(t, j, "1")
+
+	deadline := time.Now().Add(time.Second)
+	for lines(t, path) != 1 {
+		if time.Now().After(deadline) {
+			t.Fatal("batch was not flushed by the flush interval")
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+}
+
+func TestTornRecord(t *testing.T) {
+	leakcheck.Check(t)
+
+	path := filepath.Join(t.TempDir(), "journal")
+
+	j := open(t, path)
+	mark(t, j, "a", "b")
+	if err := j.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	// Simulate a crash part way through writing a record.
+	record := encode("c")
+	f, err := os.OpenFile(path, os.O_WRONLY|os.O_APPEND, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	f.Write(record[:len(record)-3])
+	f.Close()
+
+	j = open(t, path)
+	if j.Completed("c") || !j.Completed("a") || !j.Completed("b") {
+		t.Error("torn record was not dropped")
+	}
+	mark(t, j, "d")
+	if err := j.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	j = open(t, path)
+	defer j.Close()
+	if !j.Completed("d") || j.Len() != 3 {
+		t.Errorf("record written after the torn one was lost, got %d keys", j.Len())
+	}
+}
+
+func TestCorruptRecord(t *testing.T) {
+	leakcheck.Check(t)
+
+	path := filepath.Join(t.TempDir(), "journal")
+
+	data := append(encode("a"), encode("b")...)
+	data[len(data)-3] = 'x'
+	if err := os.WriteFile(path, data, 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	j := open(t, path)
+	defer j.Close()
+	if !j.Completed("a") || j.Completed("b") {
+		t.Error("record with a bad checksum was not dropped")
+	}
+}
+
+func TestCompact(t *testing.T) {
+	leakcheck.Check(t)
+
+	path := filepath.Join(t.TempDir(), "journal")
+
+	// Write the same key twice, as two runs sharing a
+	// journal file could.
+	data := append(encode("a"), encode("a")...)
+	if err := os.WriteFile(path, data, 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	j := open(t, path)
+	mark(t, j, "b", "gone")
+	if j.Records() != 2 {
+		t.Fatalf("got %d records, want 2 before flushing", j.Records())
+	}
+
+	if err := j.Compact(func(key string) bool { return key != "gone" }); err != nil {
+		t.Fatal(err)
+	}
+	if j.Completed("gone") || j.Len() != 2 || j.Records() != 2 {
+		t.Errorf("got %d keys in %d records, want 2 in 2", j.Len(), j.Records())
+	}
+	if n := lines(t, path); n != 2 {
+		t.Errorf("got %d records on disk, want 2", n)
+	}
+
+	// The journal keeps working after compaction.
+	mark(t, j, "c")
+	if err := j.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	j = open(t, path)
+	defer j.Close()
+	for _, key := range []string{"a", "b", "c"} {
+		if !j.Completed(key) {
+			t.Errorf("%q not completed after compacting and reopening", key)
+		}
+	}
+	if _, err := os.Stat(path + ".tmp"); !os.IsNotExist(err) {
+		t.Error("temporary file left behind")
+	}
+}
+
+func TestClosed(t *testing.T) {
+	leakcheck.Check(t)
+
+	j := open(t, filepath.Join(t.TempDir(), "journal"))
+	if err := j.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := j.Mark("a"); err != ErrClosed {
+		t.Errorf("Mark after Close: got %v, want ErrClosed", err)
+	}
+	if err := j.Close(); err != ErrClosed {
+		t.Errorf("second Close: got %v, want ErrClosed", err)
+	}
+}
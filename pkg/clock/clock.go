@@ -0,0 +1,92 @@
+// Package clock lets code that waits on time.After, time.Sleep and timers
+// be tested without waiting.
+//
+// Code takes a Clock instead of calling the time package directly. In
+// main it is given the real clock from New. In tests it is given a Fake,
+// which only moves forward when the test calls Advance, so a test of a
+// three second timeout runs in microseconds and always gives the same
+// result.
+package clock
+
+import (
+	"time"
+)
+
+// Clock is the part of the time package that code waiting on time uses.
+type Clock interface {
+	// Now returns the current time.
+	Now() time.Time
+
+	// After waits for d to pass and then sends the current time on the
+	// returned channel, like time.After.
+	After(d time.Duration) <-chan time.Time
+
+	// NewTimer creates a Timer that sends the current time on its
+	// channel after at least d, like time.NewTimer.
+	NewTimer(d time.Duration) Timer
+
+	// AfterFunc waits for d to pass and then calls f in its own
+	// goroutine, like time.AfterFunc.
+	AfterFunc(d time.Duration, f func()) Timer
+
+	// Sleep pauses the current goroutine for at least d, like time.Sleep.
+	Sleep(d time.Duration)
+}
+
+// Timer is a single event, like time.Timer.
+type Timer interface {
+	// C returns the channel the time is sent on when the timer fires.
+	// It is nil for timers created by AfterFunc.
+	C() <-chan time.Time
+
+	// Stop prevents the timer from firing. It returns false if the
+	// timer has already fired or been stopped.
+	Stop() bool
+
+	// Reset changes the timer to fire after d. It returns true if the
+	// timer had been active.
+	Reset(d time.Duration) bool
+}
+
+// New returns the real clock, which uses the time package.
+func New() Clock {
+	return realClock{}
+}
+
+type realClock struct{}
+
+func (realClock) Now() time.Time {
+	return time.Now()
+}
+
+func (realClock) After(d time.Duration) <-chan time.Time {
+	return time.After(d)
+}
+
+func (realClock) NewTimer(d time.Duration) Timer {
+	return realTimer{time.NewTimer(d)}
+}
+
+func (realClock) AfterFunc(d time.Duration, f func()) Timer {
+	return realTimer{time.AfterFunc(d, f)}
+}
+
+func (realClock) Sleep(d time.Duration) {
+	time.Sleep(d)
+}
+
+type realTimer struct {
+	t *time.Timer
+}
+
+func (t realTimer) C() <-chan time.Time {
+	return t.t.C
+}
+
+func (t realTimer) Stop() bool {
+	return t.t.Stop()
+}
+
+func (t realTimer) Reset(d time.Duration) bool {
+	return t.t.Reset(d)
+}
@@ -0,0 +1,70 @@
+package clock
+
+import (
+	"context"
+	"sync"
+	"time"
+)
+
+// WithDeadline is context.WithDeadline for a Clock. The returned context
+// is cancelled with context.DeadlineExceeded when c reaches deadline, so
+// with a Fake clock the deadline passes when the test advances the clock.
+func WithDeadline(parent context.Context, c Clock, deadline time.Time) (context.Context, context.CancelFunc) {
+	ctx, cancel := context.WithCancel(parent)
+	dc := &deadlineCtx{Context: ctx, deadline: deadline}
+
+	d := deadline.Sub(c.Now())
+	if d <= 0 {
+		dc.expire(cancel)
+		return dc, cancel
+	}
+
+	t := c.AfterFunc(d, func() {
+		dc.expire(cancel)
+	})
+
+	return dc, func() {
+		t.Stop()
+		cancel()
+	}
+}
+
+// WithTimeout is context.WithTimeout for a Clock.
+func WithTimeout(parent context.Context, c Clock, timeout time.Duration) (context.Context, context.CancelFunc) {
+	return WithDeadline(parent, c, c.Now().Add(timeout))
+}
+
+// deadlineCtx is a cancellable context that reports a deadline, and
+// reports context.DeadlineExceeded once it has passed.
+type deadlineCtx struct {
+	context.Context
+	deadline time.Time
+
+	mu  sync.Mutex
+	err error
+}
+
+func (c *deadlineCtx) Deadline() (time.Time, bool) {
+	return c.deadline, true
+}
+
+func (c *deadlineCtx) Err() error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if c.err != nil {
+		return c.err
+	}
+	return c.Context.Err()
+}
+
+// expire records that the deadline has passed, then cancels the context.
+func (c *deadlineCtx) expire(cancel context.CancelFunc) {
+	c.mu.Lock()
+	if c.Context.Err() == nil {
+		c.err = context.DeadlineExceeded
+	}
+	c.mu.Unlock()
+
+	cancel()
+}
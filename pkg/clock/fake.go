@@ -0,0 +1,197 @@
+package clock
+
+import (
+	"sort"
+	"sync"
+	"time"
+)
+
+// Fake is a Clock whose time only moves when Advance is called. Timers,
+// sleeps and After channels fire, in order, as Advance moves the time past
+// them. It is safe for concurrent use.
+type Fake struct {
+	mu sync.Mutex
+
+	// changed is broadcast whenever a timer is added or removed,
+	// for BlockUntil.
+	changed *sync.Cond
+
+	now    time.Time
+	timers []*fakeTimer
+}
+
+// NewFake returns a fake clock set to now.
+func NewFake(now time.Time) *Fake {
+	f := &Fake{now: now}
+	f.changed = sync.NewCond(&f.mu)
+	return f
+}
+
+// Now returns the fake clock's current time.
+func (f *Fake) Now() time.Time {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	return f.now
+}
+
+// After returns a channel that receives the fake time once the clock
+// has been advanced by d, or at once if d isn't positive.
+func (f *Fake) After(d time.Duration) <-chan time.Time {
+	return f.NewTimer(d).C()
+}
+
+// NewTimer returns a timer that fires once the clock has been advanced
+// by d, or at once if d isn't positive.
+func (f *Fake) NewTimer(d time.Duration) Timer {
+	t := &fakeTimer{fake: f, c: make(chan time.Time, 1)}
+	t.Reset(d)
+	return t
+}
+
+// AfterFunc returns a timer that calls fn in its own goroutine once the
+// clock has been advanced by d.
+func (f *Fake) AfterFunc(d time.Duration, fn func()) Timer {
+	t := &fakeTimer{fake: f, fn: fn}
+	t.Reset(d)
+	return t
+}
+
+// Sleep blocks until another goroutine advances the clock by d. It
+// returns at once if d isn't positive.
+func (f *Fake) Sleep(d time.Duration) {
+	<-f.After(d)
+}
+
+// Advance moves the clock forward by d, firing every timer that falls due
+// on the way in the order they are due. While each timer fires, Now
+// returns the time it was due.
+func (f *Fake) Advance(d time.Duration) {
+	f.mu.Lock()
+	end := f.now.Add(d)
+
+	for len(f.timers) > 0 && !f.timers[0].when.After(end) {
+		t := f.timers[0]
+		f.timers = f.timers[1:]
+		t.active = false
+		f.now = t.when
+		f.changed.Broadcast()
+
+		f.mu.Unlock()
+		t.fire(t.when)
+		f.mu.Lock()
+	}
+
+	f.now = end
+	f.mu.Unlock()
+}
+
+// Set moves the clock to t, firing the timers due on the way. It does
+// nothing if t is before the clock's current time.
+func (f *Fake) Set(t time.Time) {
+	if d := t.Sub(f.Now()); d > 0 {
+		f.Advance(d)
+	}
+}
+
+// Waiters returns the number of timers, sleeps and After channels waiting
+// for the clock to move.
+func (f *Fake) Waiters() int {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	return len(f.timers)
+}
+
+// BlockUntil waits until at least n timers, sleeps or After channels are
+// waiting for the clock to move. Tests call it before Advance to be sure
+// the code under test has reached the point where it waits.
+func (f *Fake) BlockUntil(n int) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	for len(f.timers) < n {
+		f.changed.Wait()
+	}
+}
+
+// add schedules t. f.mu must be held.
+func (f *Fake) add(t *fakeTimer) {
+	i := sort.Search(len(f.timers), func(i int) bool {
+		return f.timers[i].when.After(t.when)
+	})
+	f.timers = append(f.timers, nil)
+	copy(f.timers[i+1:], f.timers[i:])
+	f.timers[i] = t
+	t.active = true
+	f.changed.Broadcast()
+}
+
+// remove unschedules t, returning false if it wasn't scheduled.
+// f.mu must be held.
+func (f *Fake) remove(t *fakeTimer) bool {
+	if !t.active {
+		return false
+	}
+	for i, other := range f.timers {
+		if other == t {
+			f.timers = append(f.timers[:i], f.timers[i+1:]...)
+			break
+		}
+	}
+	t.active = false
+	f.changed.Broadcast()
+	return true
+}
+
+type fakeTimer struct {
+	fake *Fake
+	c    chan time.Time
+	fn   func()
+
+	// Guarded by fake.mu.
+	when   time.Time
+	active bool
+}
+
+func (t *fakeTimer) C() <-chan time.Time {
+	return t.c
+}
+
+func (t *fakeTimer) Stop() bool {
+	t.fake.mu.Lock()
+	defer t.fake.mu.Unlock()
+
+	return t.fake.remove(t)
+}
+
+func (t *fakeTimer) Reset(d time.Duration) bool {
+	t.fake.mu.Lock()
+	defer t.fake.mu.Unlock()
+
+	active := t.fake.remove(t)
+	t.when = t.fake.now.Add(d)
+	if d <= 0 {
+		// Like a real timer, one that is already due fires at once,
+		// without waiting for the clock to move.
+		t.fire(t.when)
+		return active
+	}
+	t.fake.add(t)
+	return active
+}
+
+// fire sends now on the timer's channel, or starts its function.
+func (t *fakeTimer) fire(now time.Time) {
+	if t.fn != nil {
+		go t.fn()
+		return
+	}
+
+	// Like a real timer the channel has room for one value, and a
+	// value nobody has received yet is not replaced.
+	select {
+	case t.c <- now:
+	default:
+	}
+}
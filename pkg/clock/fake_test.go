@@ -0,0 +1,218 @@
+package clock
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"go-concurrency-exercises/pkg/leakcheck"
+)
+
+var start = time.Date(2022, 6, 1, 12, 0, 0, 0, time.UTC)
+
+func TestFakeAfter(t *testing.T) {
+	c := NewFake(start)
+	ch := c.After(time.Second)
+
+	c.Advance(999 * time.Millisecond)
+	select {
+	case <-ch:
+		t.Fatal("After fired early")
+	default:
+	}
+
+	c.Advance(time.Millisecond)
+	select {
+	case now := <-ch:
+		if !now.Equal(start.Add(time.Second)) {
+			t.Errorf("got %v, want %v", now, start.Add(time.Second))
+		}
+	default:
+		t.Fatal("After did not fire")
+	}
+}
+
+func TestFakeFiresInOrder(t *testing.T) {
+	c := NewFake(start)
+
+	order := make(chan int, 3)
+	for _, d := range []int{3, 1, 2} {
+		d := d
+		timer := c.NewTimer(time.Duration(d) * time.Second)
+		go func() {
+			<-timer.C()
+			order <- d
+		}()
+	}
+
+	for i := 1; i <= 3; i++ {
+		c.Advance(time.Second)
+		if got := <-order; got != i {
+			t.Fatalf("got timer %d, want %d", got, i)
+		}
+	}
+	if got := c.Now(); !got.Equal(start.Add(3 * time.Second)) {
+		t.Errorf("got now %v, want %v", got, start.Add(3*time.Second))
+	}
+}
+
+func TestFakeTimerStopReset(t *testing.T) {
+	c := NewFake(start)
+	timer := c.NewTimer(time.Second)
+
+	if !timer.Stop() {
+		t.Error("Stop on an active timer returned false")
+	}
+	if timer.Stop() {
+		t.Error("second Stop returned true")
+	}
+	c.Advance(time.Hour)
+	select {
+	case <-timer.C():
+		t.Fatal("stopped timer fired")
+	default:
+	}
+
+	if timer.Reset(time.Second) {
+		t.Error("Reset on a stopped timer returned true")
+	}
+	c.Advance(time.Second)
+	<-timer.C()
+}
+
+func TestFakeAfterFunc(t *testing.T) {
+	leakcheck.Check(t)
+
+	c := NewFake(start)
+	called := make(chan time.Time)
+	c.AfterFunc(time.Minute, func() {
+		called <- c.Now()
+	})
+
+	c.Advance(time.Hour)
+	if got := <-called; !got.Equal(start.Add(time.Hour)) {
+		t.Errorf("got %v, want %v", got, start.Add(time.Hour))
+	}
+}
+
+func TestFakeSleep(t *testing.T) {
+	leakcheck.Check(t)
+
+	c := NewFake(start)
+	woke := make(chan struct{})
+	go func() {
+		c.Sleep(5 * time.Second)
+		close(woke)
+	}()
+
+	c.BlockUntil(1)
+	c.Advance(5 * time.Second)
+	<-woke
+
+	if n := c.Waiters(); n != 0 {
+		t.Errorf("got %d waiters, want 0", n)
+	}
+}
+
+func TestFakeDue(t *testing.T) {
+	leakcheck.Check(t)
+
+	c := NewFake(start)
+	for _, d := range []time.Duration{0, -time.Second} {
+		c.Sleep(d)
+		if now := <-c.After(d); !now.Equal(start.Add(d)) {
+			t.Errorf("After(%v) got %v, want %v", d, now, start.Add(d))
+		}
+
+		called := make(chan struct{})
+		c.AfterFunc(d, func() { close(called) })
+		<-called
+	}
+
+	timer := c.NewTimer(time.Hour)
+	if !timer.Reset(0) {
+		t.Error("Reset on an active timer returned false")
+	}
+	<-timer.C()
+	if n := c.Waiters(); n != 0 {
+		t.Errorf("got %d waiters, want 0", n)
+	}
+	if got := c.Now(); !got.Equal(start) {
+		t.Errorf("got now %v, want %v", got, start)
+	}
+}
+
+func TestWithDeadline(t *testing.T) {
+	leakcheck.Check(t)
+
+	c := NewFake(start)
+	ctx, cancel := WithTimeout(context.Background(), c, 5*time.Millisecond)
+	defer cancel()
+
+	if deadline, ok := ctx.Deadline(); !ok || !deadline.Equal(start.Add(5*time.Millisecond)) {
+		t.Errorf("got deadline %v, %v", deadline, ok)
+	}
+
+	c.Advance(4 * time.Millisecond)
+	if ctx.Err() != nil {
+		t.Fatalf("context done before its deadline: %v", ctx.Err())
+	}
+
+	c.Advance(time.Millisecond)
+	<-ctx.Done()
+	if ctx.Err() != context.DeadlineExceeded {
+		t.Errorf("got %v, want %v", ctx.Err(), context.DeadlineExceeded)
+	}
+
+	// Contexts derived from it see the same error.
+	child, cancelChild := context.WithCancel(ctx)
+	defer cancelChild()
+	<-child.Done()
+	if child.Err() != context.DeadlineExceeded {
+		t.Errorf("child got %v, want %v", child.Err(), context.DeadlineExceeded)
+	}
+}
+
+func TestWithDeadlineCancelled(t *testing.T) {
+	c := NewFake(start)
+	ctx, cancel := WithDeadline(context.Background(), c, start.Add(time.Second))
+
+	cancel()
+	if ctx.Err() != context.Canceled {
+		t.Errorf("got %v, want %v", ctx.Err(), context.Canceled)
+	}
+	if n := c.Waiters(); n != 0 {
+		t.Errorf("cancel left %d timers waiting", n)
+	}
+
+	c.Advance(time.Second)
+	if ctx.Err() != context.Canceled {
+		t.Errorf("got %v after the deadline, want %v", ctx.Err(), context.Canceled)
+	}
+}
+
+func TestWithDeadlinePassed(t *testing.T) {
+	c := NewFake(start)
+	ctx, cancel := WithDeadline(context.Background(), c, start.Add(-time.Second))
+	defer cancel()
+
+	if ctx.Err() != context.DeadlineExceeded {
+		t.Errorf("got %v, want %v", ctx.Err(), context.DeadlineExceeded)
+	}
+}
+
+func TestReal(t *testing.T) {
+	c := New()
+
+	before := time.Now()
+	<-c.After(time.Millisecond)
+	c.Sleep(time.Millisecond)
+	if d := c.Now().Sub(before); d < 2*time.Millisecond {
+		t.Errorf("real clock only moved %v", d)
+	}
+
+	timer := c.NewTimer(time.Hour)
+	if !timer.Stop() {
+		t.Error("Stop on an active timer returned false")
+	}
+}
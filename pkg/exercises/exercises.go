@@ -0,0 +1,235 @@
+// Package exercises finds the runnable examples in the repository: the
+// class code walks, the exercises and their solutions, and the programs
+// under cmd. Each is a directory holding a main package, and is named by
+// its path from the repository root, such as "01-exercise/04-sync/02-mutex".
+//
+// An exercise and its solutions share a stem, the path with the
+// "-solution" suffixes dropped, so "01-exercise-solution/04-sync/02-mutex"
+// is the solution to "01-exercise/04-sync/02-mutex". Titles come from the
+// README, so the runner can show what each one is about.
+package exercises
+
+import (
+	"fmt"
+	"go/parser"
+	"go/token"
+	"io/fs"
+	"os"
+	"path"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+// Kind says what an example is for.
+type Kind int
+
+const (
+	// KindCodewalk is code shown in class, from the *-class-codewalk
+	// directories.
+	KindCodewalk Kind = iota
+
+	// KindExercise is an exercise to complete, from the *-exercise
+	// directories.
+	KindExercise
+
+	// KindSolution is the answer to an exercise, from the
+	// *-exercise-solution directories or a directory ending -solution.
+	KindSolution
+
+	// KindOwn is one of the programs under cmd.
+	KindOwn
+)
+
+func (k Kind) String() string {
+	switch k {
+	case KindCodewalk:
+		return "codewalk"
+	case KindExercise:
+		return "exercise"
+	case KindSolution:
+		return "solution"
+	case KindOwn:
+		return "own"
+	}
+	return fmt.Sprintf("Kind(%d)", int(k))
+}
+
+// ParseKind returns the Kind with the given name.
+func ParseKind(s string) (Kind, error) {
+	for k := KindCodewalk; k <= KindOwn; k++ {
+		if k.String() == s {
+			return k, nil
+		}
+	}
+	return 0, fmt.Errorf("unknown kind %q", s)
+}
+
+// Example is a directory holding a main package.
+type Example struct {
+	// Name is the directory's slash-separated path from the root.
+	Name string
+
+	// Dir is the directory's path on disk.
+	Dir string
+
+	Kind  Kind
+	Title string
+
+	// Module is true if the directory has its own go.mod.
+	Module bool
+
+	// Files are the package's Go files, excluding tests.
+	Files []string
+
+	// Stem is the Name with "-solution" suffixes dropped, shared by an
+	// exercise and its solutions.
+	Stem string
+}
+
+// Discover walks root for directories holding a main package, and
+// returns them sorted by name. Titles are taken from root's README.md if
+// there is one.
+func Discover(root string) ([]*Example, error) {
+	var examples []*Example
+	err := filepath.WalkDir(root, func(p string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if !d.IsDir() {
+			return nil
+		}
+		name := d.Name()
+		if p != root && (strings.HasPrefix(name, ".") || name == "testdata" || name == "vendor") {
+			return filepath.SkipDir
+		}
+
+		files, err := mainFiles(p)
+		if err != nil || len(files) == 0 {
+			return err
+		}
+
+		rel, err := filepath.Rel(root, p)
+		if err != nil {
+			return err
+		}
+		e := &Example{
+			Name:  filepath.ToSlash(rel),
+			Dir:   p,
+			Files: files,
+		}
+		_, err = os.Stat(filepath.Join(p, "go.mod"))
+		e.Module = err == nil
+		e.Kind, e.Stem = classify(e.Name)
+		examples = append(examples, e)
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	sort.Slice(examples, func(i, j int) bool { return examples[i].Name < examples[j].Name })
+
+	readme, err := os.ReadFile(filepath.Join(root, "README.md"))
+	if err != nil && !os.IsNotExist(err) {
+		return nil, err
+	}
+	titles := parseReadme(readme)
+	for _, e := range examples {
+		e.Title = titles.title(e.Name)
+	}
+
+	return examples, nil
+}
+
+// mainFiles returns the names of the Go files in dir, excluding tests,
+// if they make up a main package.
+func mainFiles(dir string) ([]string, error) {
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return nil, err
+	}
+
+	var files []string
+	isMain := false
+	for _, e := range entries {
+		name := e.Name()
+		if e.IsDir() || !strings.HasSuffix(name, ".go") || strings.HasSuffix(name, "_test.go") {
+			continue
+		}
+		f, err := parser.ParseFile(token.NewFileSet(), filepath.Join(dir, name), nil, parser.PackageClauseOnly)
+		if err != nil {
+			continue
+		}
+		if f.Name.Name == "main" {
+			isMain = true
+			files = append(files, name)
+		}
+	}
+
+	if !isMain {
+		return nil, nil
+	}
+	return files, nil
+}
+
+// classify returns the kind of the example with the given name, and its
+// stem.
+func classify(name string) (Kind, string) {
+	parts := strings.Split(name, "/")
+	top, leaf := parts[0], parts[len(parts)-1]
+
+	var kind Kind
+	switch {
+	case strings.HasSuffix(top, "-exercise-solution"):
+		kind = KindSolution
+		parts[0] = strings.TrimSuffix(top, "-solution")
+	case strings.HasSuffix(top, "-exercise"):
+		kind = KindExercise
+	case strings.HasSuffix(top, "-class-codewalk"):
+		kind = KindCodewalk
+	default:
+		kind = KindOwn
+	}
+
+	if len(parts) > 1 && strings.HasSuffix(leaf, "-solution") {
+		kind = KindSolution
+		parts[len(parts)-1] = strings.TrimSuffix(leaf, "-solution")
+	}
+
+	return kind, strings.Join(parts, "/")
+}
+
+// Find returns the examples called name, or whose names end with name,
+// such as "04-sync/02-mutex" for both the exercise and its solution.
+func Find(examples []*Example, name string) []*Example {
+	name = strings.Trim(path.Clean(filepath.ToSlash(name)), "/")
+	for _, e := range examples {
+		if e.Name == name {
+			return []*Example{e}
+		}
+	}
+
+	var found []*Example
+	for _, e := range examples {
+		if strings.HasSuffix(e.Name, "/"+name) {
+			found = append(found, e)
+		}
+	}
+	return found
+}
+
+// Counterparts returns the solutions to e if it is an exercise, or the
+// exercise if it is a solution.
+func Counterparts(examples []*Example, e *Example) []*Example {
+	var found []*Example
+	for _, o := range examples {
+		if o == e || o.Stem != e.Stem {
+			continue
+		}
+		if (e.Kind == KindExercise && o.Kind == KindSolution) || (e.Kind == KindSolution && o.Kind == KindExercise) {
+			found = append(found, o)
+		}
+	}
+	return found
+}
@@ -0,0 +1,170 @@
+package exercises
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+const readme = "# Exercises\n" +
+	"## Sync Package\n" +
+	"### Mutex\n" +
+	"## Code Examples\n" +
+	"### [Pool of workers](https://github.com/someone/repo/blob/abc123/cmd/pool/main.go)\n" +
+	"### Hashing files\n" +
+	"Run at command line with `go run main.go .` in the `cmd/hash` path.\n"
+
+const hello = `package main
+
+import "fmt"
+
+func main() {
+	fmt.Println("hello")
+}
+`
+
+// writeTree creates the files, given by slash-separated path, under root.
+func writeTree(t *testing.T, root string, files map[string]string) {
+	t.Helper()
+
+	for name, content := range files {
+		p := filepath.Join(root, filepath.FromSlash(name))
+		if err := os.MkdirAll(filepath.Dir(p), 0o755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(p, []byte(content), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+}
+
+func discover(t *testing.T) []*Example {
+	t.Helper()
+
+	root := t.TempDir()
+	writeTree(t, root, map[string]string{
+		"README.md":                                             readme,
+		"01-class-codewalk/03-channel/main.go":                  hello,
+		"01-exercise/04-sync/02-mutex/main.go":                  hello,
+		"01-exercise-solution/04-sync/02-mutex/main.go":         hello,
+		"01-exercise/04-sync/02-mutex/main_test.go":             "package main\n",
+		"01-exercise/07-crawler/main.go":                        hello,
+		"01-exercise/07-crawler/go.mod":                         "module crawler\n",
+		"01-exercise/07-crawler-solution/main.go":               hello,
+		"01-exercise/09-tests-only/main_test.go":                "package main\n",
+		"cmd/pool/main.go":                                      hello,
+		"cmd/hash/main.go":                                      hello,
+		"pkg/lib/lib.go":                                        "package lib\n",
+		"pkg/lib/testdata/main.go":                              hello,
+		".hidden/main.go":                                       hello,
+		"02-exercise-solution/02-pipeline/01-pipeline/main.go":  hello,
+		"02-exercise-solution/02-pipeline/01-pipeline/extra.go": "package main\n",
+	})
+
+	examples, err := Discover(root)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return examples
+}
+
+func TestDiscover(t *testing.T) {
+	examples := discover(t)
+
+	want := []struct {
+		name, title, stem string
+		kind              Kind
+		module            bool
+		files             int
+	}{
+		{"01-class-codewalk/03-channel", "channel", "01-class-codewalk/03-channel", KindCodewalk, false, 1},
+		{"01-exercise-solution/04-sync/02-mutex", "Sync Package: mutex", "01-exercise/04-sync/02-mutex", KindSolution, false, 1},
+		{"01-exercise/04-sync/02-mutex", "Sync Package: mutex", "01-exercise/04-sync/02-mutex", KindExercise, false, 1},
+		{"01-exercise/07-crawler", "crawler", "01-exercise/07-crawler", KindExercise, true, 1},
+		{"01-exercise/07-crawler-solution", "crawler solution", "01-exercise/07-crawler", KindSolution, false, 1},
+		{"02-exercise-solution/02-pipeline/01-pipeline", "pipeline: pipeline", "02-exercise/02-pipeline/01-pipeline", KindSolution, false, 2},
+		{"cmd/hash", "Hashing files", "cmd/hash", KindOwn, false, 1},
+		{"cmd/pool", "Pool of workers", "cmd/pool", KindOwn, false, 1},
+	}
+
+	if len(examples) != len(want) {
+		for _, e := range examples {
+			t.Log(e.Name)
+		}
+		t.Fatalf("found %d examples, want %d", len(examples), len(want))
+	}
+	for i, w := range want {
+		e := examples[i]
+		if e.Name != w.name || e.Title != w.title || e.Stem != w.stem || e.Kind != w.kind || e.Module != w.module || len(e.Files) != w.files {
+			t.Errorf("example %d: got %s %q stem %s %s module %v files %v, want %s %q stem %s %s module %v %d files",
+				i, e.Name, e.Title, e.Stem, e.Kind, e.Module, e.Files, w.name, w.title, w.stem, w.kind, w.module, w.files)
+		}
+	}
+}
+
+func names(examples []*Example) []string {
+	var ns []string
+	for _, e := range examples {
+		ns = append(ns, e.Name)
+	}
+	return ns
+}
+
+func sameNames(got []*Example, want ...string) bool {
+	ns := names(got)
+	if len(ns) != len(want) {
+		return false
+	}
+	for i := range ns {
+		if ns[i] != want[i] {
+			return false
+		}
+	}
+	return true
+}
+
+func TestFind(t *testing.T) {
+	examples := discover(t)
+
+	tests := []struct {
+		name string
+		want []string
+	}{
+		{"01-exercise/04-sync/02-mutex", []string{"01-exercise/04-sync/02-mutex"}},
+		{"04-sync/02-mutex", []string{"01-exercise-solution/04-sync/02-mutex", "01-exercise/04-sync/02-mutex"}},
+		{"02-mutex/", []string{"01-exercise-solution/04-sync/02-mutex", "01-exercise/04-sync/02-mutex"}},
+		{"pool", []string{"cmd/pool"}},
+		{"mutex", nil},
+	}
+
+	for _, tt := range tests {
+		if got := Find(examples, tt.name); !sameNames(got, tt.want...) {
+			t.Errorf("Find(%q) = %v, want %v", tt.name, names(got), tt.want)
+		}
+	}
+}
+
+func TestCounterparts(t *testing.T) {
+	examples := discover(t)
+
+	find := func(name string) *Example {
+		return Find(examples, name)[0]
+	}
+
+	tests := []struct {
+		name string
+		want []string
+	}{
+		{"01-exercise/04-sync/02-mutex", []string{"01-exercise-solution/04-sync/02-mutex"}},
+		{"01-exercise-solution/04-sync/02-mutex", []string{"01-exercise/04-sync/02-mutex"}},
+		{"01-exercise/07-crawler", []string{"01-exercise/07-crawler-solution"}},
+		{"02-exercise-solution/02-pipeline/01-pipeline", nil},
+		{"cmd/pool", nil},
+	}
+
+	for _, tt := range tests {
+		if got := Counterparts(examples, find(tt.name)); !sameNames(got, tt.want...) {
+			t.Errorf("Counterparts(%s) = %v, want %v", tt.name, names(got), tt.want)
+		}
+	}
+}
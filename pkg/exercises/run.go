@@ -0,0 +1,184 @@
+package exercises
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"time"
+)
+
+// RunOption configures Run.
+type RunOption func(*runConfig)
+
+type runConfig struct {
+	args    []string
+	timeout time.Duration
+	stdout  io.Writer
+	stderr  io.Writer
+	race    bool
+}
+
+// Args sets the command line arguments to run the example with.
+func Args(args ...string) RunOption {
+	return func(c *runConfig) {
+		c.args = args
+	}
+}
+
+// Timeout kills the example if it runs for longer than d. Building it
+// doesn't count.
+func Timeout(d time.Duration) RunOption {
+	return func(c *runConfig) {
+		c.timeout = d
+	}
+}
+
+// Tee copies the example's output to stdout and stderr as it runs, as
+// well as capturing it.
+func Tee(stdout, stderr io.Writer) RunOption {
+	return func(c *runConfig) {
+		c.stdout = stdout
+		c.stderr = stderr
+	}
+}
+
+// Race builds the example with the race detector.
+func Race() RunOption {
+	return func(c *runConfig) {
+		c.race = true
+	}
+}
+
+// BuildError is the error when an example doesn't compile.
+type BuildError struct {
+	Name   string
+	Output []byte
+}
+
+func (e *BuildError) Error() string {
+	return fmt.Sprintf("building %s:\n%s", e.Name, e.Output)
+}
+
+// Result is the outcome of running an example.
+type Result struct {
+	Example *Example
+	Args    []string
+
+	Stdout []byte
+	Stderr []byte
+
+	// ExitCode is the example's exit status, or -1 if it didn't exit by
+	// itself.
+	ExitCode int
+
+	// TimedOut is true if the example was killed for running too long.
+	TimedOut bool
+
+	Duration time.Duration
+
+	// Err is set if the example couldn't be built or started. A
+	// *BuildError holds the compiler's output.
+	Err error
+}
+
+// OK reports whether the example ran and exited with status 0.
+func (r *Result) OK() bool {
+	return r.Err == nil && !r.TimedOut && r.ExitCode == 0
+}
+
+// Run builds e and runs it in its directory, capturing what it writes to
+// stdout and stderr. It builds a binary rather than using go run, so that
+// a timeout kills the example itself rather than the go command.
+func Run(ctx context.Context, e *Example, opts ...RunOption) *Result {
+	var c runConfig
+	for _, opt := range opts {
+		opt(&c)
+	}
+
+	r := &Result{Example: e, Args: c.args, ExitCode: -1}
+
+	tmp, err := os.MkdirTemp("", "exercise-")
+	if err != nil {
+		r.Err = err
+		return r
+	}
+	defer os.RemoveAll(tmp)
+
+	bin := filepath.Join(tmp, filepath.Base(e.Dir))
+	if err := build(ctx, e, bin, c.race); err != nil {
+		r.Err = err
+		return r
+	}
+
+	runCtx := ctx
+	if c.timeout > 0 {
+		var cancel context.CancelFunc
+		runCtx, cancel = context.WithTimeout(ctx, c.timeout)
+		defer cancel()
+	}
+
+	var stdout, stderr bytes.Buffer
+	cmd := exec.CommandContext(runCtx, bin, c.args...)
+	cmd.Dir = e.Dir
+	cmd.Stdout = tee(&stdout, c.stdout)
+	cmd.Stderr = tee(&stderr, c.stderr)
+
+	start := time.Now()
+	err = cmd.Run()
+	r.Duration = time.Since(start)
+	r.Stdout = stdout.Bytes()
+	r.Stderr = stderr.Bytes()
+
+	var exitErr *exec.ExitError
+	switch {
+	case err != nil && runCtx.Err() == context.DeadlineExceeded && ctx.Err() == nil:
+		// Only a run the timeout cut short timed out; one that
+		// finished just before it did not.
+		r.TimedOut = true
+	case errors.As(err, &exitErr):
+		r.ExitCode = exitErr.ExitCode()
+	case err != nil:
+		r.Err = err
+	default:
+		r.ExitCode = 0
+	}
+	return r
+}
+
+// build compiles e into bin. An example with its own go.mod is built as
+// its module's package; the rest are built from their files, as the
+// README's go run main.go instructions do.
+func build(ctx context.Context, e *Example, bin string, race bool) error {
+	args := []string{"build", "-o", bin}
+	if race {
+		args = append(args, "-race")
+	}
+	if e.Module {
+		args = append(args, ".")
+	} else {
+		args = append(args, e.Files...)
+	}
+
+	cmd := exec.CommandContext(ctx, "go", args...)
+	cmd.Dir = e.Dir
+	out, err := cmd.CombinedOutput()
+	if err != nil {
+		if len(out) == 0 {
+			out = []byte(err.Error())
+		}
+		return &BuildError{Name: e.Name, Output: out}
+	}
+	return nil
+}
+
+func tee(buf *bytes.Buffer, w io.Writer) io.Writer {
+	if w == nil {
+		return buf
+	}
+	return io.MultiWriter(buf, w)
+}
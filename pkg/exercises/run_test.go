@@ -0,0 +1,122 @@
+package exercises
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"go-concurrency-exercises/pkg/leakcheck"
+)
+
+const echo = `package main
+
+import (
+	"fmt"
+	"os"
+	"time"
+)
+
+func main() {
+	if len(os.Args) > 1 && os.Args[1] == "sleep" {
+		time.Sleep(time.Hour)
+	}
+	fmt.Println("args:", os.Args[1:])
+	fmt.Fprintln(os.Stderr, "to stderr")
+	os.Exit(3)
+}
+`
+
+func example(t *testing.T, files map[string]string) *Example {
+	t.Helper()
+
+	root := t.TempDir()
+	writeTree(t, root, files)
+
+	examples, err := Discover(root)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(examples) != 1 {
+		t.Fatalf("found %d examples, want 1", len(examples))
+	}
+	return examples[0]
+}
+
+func TestRun(t *testing.T) {
+	leakcheck.Check(t)
+
+	e := example(t, map[string]string{"cmd/echo/main.go": echo})
+
+	var tee bytes.Buffer
+	r := Run(context.Background(), e, Args("a", "b"), Timeout(time.Minute), Tee(&tee, nil))
+
+	if r.Err != nil {
+		t.Fatal(r.Err)
+	}
+	if got, want := string(r.Stdout), "args: [a b]\n"; got != want {
+		t.Errorf("got stdout %q, want %q", got, want)
+	}
+	if got, want := string(r.Stderr), "to stderr\n"; got != want {
+		t.Errorf("got stderr %q, want %q", got, want)
+	}
+	if got := tee.String(); got != string(r.Stdout) {
+		t.Errorf("teed %q, want %q", got, r.Stdout)
+	}
+	if r.ExitCode != 3 || r.TimedOut || r.OK() {
+		t.Errorf("got exit code %d, timed out %v, OK %v, want 3, false, false", r.ExitCode, r.TimedOut, r.OK())
+	}
+}
+
+func TestRunTimeout(t *testing.T) {
+	leakcheck.Check(t)
+
+	e := example(t, map[string]string{"cmd/echo/main.go": echo})
+
+	start := time.Now()
+	r := Run(context.Background(), e, Args("sleep"), Timeout(100*time.Millisecond))
+
+	if !r.TimedOut {
+		t.Errorf("got exit code %d, err %v, want a timeout", r.ExitCode, r.Err)
+	}
+	if d := time.Since(start); d > 30*time.Second {
+		t.Errorf("took %v to time out", d)
+	}
+}
+
+func TestRunModule(t *testing.T) {
+	leakcheck.Check(t)
+
+	e := example(t, map[string]string{
+		"01-exercise/07-crawler/go.mod":  "module crawler\n\ngo 1.18\n",
+		"01-exercise/07-crawler/main.go": "package main\n\nfunc main() { greet() }\n",
+		"01-exercise/07-crawler/greet.go": "package main\n\nimport \"fmt\"\n\n" +
+			"func greet() { fmt.Println(\"hello\") }\n",
+	})
+
+	r := Run(context.Background(), e)
+	if !r.OK() || string(r.Stdout) != "hello\n" {
+		t.Errorf("got %q, exit code %d, err %v, want hello", r.Stdout, r.ExitCode, r.Err)
+	}
+}
+
+func TestRunBuildError(t *testing.T) {
+	leakcheck.Check(t)
+
+	e := example(t, map[string]string{"cmd/broken/main.go": "package main\n\nfunc main() { undefined() }\n"})
+
+	r := Run(context.Background(), e)
+
+	var berr *BuildError
+	if !errors.As(r.Err, &berr) {
+		t.Fatalf("got %v, want a *BuildError", r.Err)
+	}
+	if !bytes.Contains(berr.Output, []byte("undefined")) {
+		t.Errorf("build output doesn't mention the error:\n%s", berr.Output)
+	}
+	if berr.Name != filepath.ToSlash("cmd/broken") {
+		t.Errorf("got name %s, want cmd/broken", berr.Name)
+	}
+}
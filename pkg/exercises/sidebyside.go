@@ -0,0 +1,115 @@
+package exercises
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"strings"
+	"unicode/utf8"
+)
+
+// Column is one side of SideBySide.
+type Column struct {
+	// Title is shown above the column, one row for each line.
+	Title string
+	Text  []byte
+}
+
+// SideBySide writes left and right next to each other in two columns,
+// fitting the whole thing in width characters. Lines too long for their
+// column are wrapped. Lines that differ from the line at the same place on
+// the other side are marked with a | between the columns, as diff -y does.
+// It returns whether the two sides were the same.
+func SideBySide(w io.Writer, width int, left, right Column) (bool, error) {
+	col := (width - 3) / 2
+	if col < 10 {
+		col = 10
+	}
+
+	bw := bufio.NewWriter(w)
+	row := func(l, gutter, r string) {
+		line := l + strings.Repeat(" ", col-utf8.RuneCountInString(l)) + " " + gutter + " " + r
+		fmt.Fprintln(bw, strings.TrimRight(line, " "))
+	}
+
+	lt, rt := strings.Split(left.Title, "\n"), strings.Split(right.Title, "\n")
+	for i := 0; i < len(lt) || i < len(rt); i++ {
+		var l, r string
+		if i < len(lt) {
+			l = lt[i]
+		}
+		if i < len(rt) {
+			r = rt[i]
+		}
+		row(clip(l, col), " ", clip(r, col))
+	}
+	row(strings.Repeat("-", col), " ", strings.Repeat("-", col))
+
+	ls, rs := lines(left.Text), lines(right.Text)
+	same := len(ls) == len(rs)
+	for i := 0; i < len(ls) || i < len(rs); i++ {
+		var l, r string
+		if i < len(ls) {
+			l = ls[i]
+		}
+		if i < len(rs) {
+			r = rs[i]
+		}
+
+		gutter := " "
+		if l != r || i >= len(ls) || i >= len(rs) {
+			gutter = "|"
+			same = false
+		}
+
+		lw, rw := wrap(l, col), wrap(r, col)
+		for j := 0; j < len(lw) || j < len(rw); j++ {
+			var lp, rp string
+			if j < len(lw) {
+				lp = lw[j]
+			}
+			if j < len(rw) {
+				rp = rw[j]
+			}
+			row(lp, gutter, rp)
+		}
+	}
+
+	return same, bw.Flush()
+}
+
+// lines splits text into lines, expanding tabs, without a trailing empty
+// line for the final newline.
+func lines(text []byte) []string {
+	s := strings.TrimSuffix(string(text), "\n")
+	if s == "" {
+		return nil
+	}
+	s = strings.ReplaceAll(s, "\t", "    ")
+	s = strings.ReplaceAll(s, "\r", "")
+	return strings.Split(s, "\n")
+}
+
+// wrap splits s into pieces at most width characters long.
+func wrap(s string, width int) []string {
+	if s == "" {
+		return []string{""}
+	}
+
+	var pieces []string
+	r := []rune(s)
+	for len(r) > width {
+		pieces = append(pieces, string(r[:width]))
+		r = r[width:]
+	}
+	return append(pieces, string(r))
+}
+
+// clip shortens s to width characters, ending with … if it was cut.
+func clip(s string, width int) string {
+	r := []rune(s)
+	if len(r) <= width {
+		return s
+	}
+	return string(r[:width-1]) + "…"
+}
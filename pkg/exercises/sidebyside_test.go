@@ -0,0 +1,46 @@
+package exercises
+
+import (
+	"bytes"
+	"testing"
+)
+
+func TestSideBySide(t *testing.T) {
+	var buf bytes.Buffer
+	same, err := SideBySide(&buf, 33,
+		Column{Title: "exercise\nexit status 0", Text: []byte("one\ntwo\na line too long for a column\n")},
+		Column{Title: "solution", Text: []byte("one\n2\n")},
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if same {
+		t.Error("got same for different text")
+	}
+
+	want := "" +
+		"exercise          solution\n" +
+		"exit status 0\n" +
+		"---------------   ---------------\n" +
+		"one               one\n" +
+		"two             | 2\n" +
+		"a line too long |\n" +
+		" for a column   |\n"
+	if got := buf.String(); got != want {
+		t.Errorf("got\n%s\nwant\n%s", got, want)
+	}
+}
+
+func TestSideBySideSame(t *testing.T) {
+	var buf bytes.Buffer
+	same, err := SideBySide(&buf, 40,
+		Column{Title: "a", Text: []byte("x\n\ty\n")},
+		Column{Title: "b", Text: []byte("x\n\ty")},
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !same {
+		t.Errorf("got different for the same text:\n%s", buf.String())
+	}
+}
@@ -0,0 +1,148 @@
+package exercises
+
+import (
+	"bufio"
+	"bytes"
+	"path"
+	"regexp"
+	"strings"
+	"unicode"
+)
+
+var (
+	headingRE  = regexp.MustCompile(`^(#+)\s+(.*)$`)
+	linkRE     = regexp.MustCompile(`\[([^\]]+)\]\(([^)\s]+)\)`)
+	backtickRE = regexp.MustCompile("`([^`]+)`")
+
+	// blobRE matches the path in a GitHub link to a file or directory.
+	blobRE = regexp.MustCompile(`/(?:blob|tree)/[^/]+/(.+)$`)
+)
+
+// titles are the titles found in the README.
+type titles struct {
+	// links maps the paths of directories linked to from the README to
+	// the link text.
+	links map[string]string
+
+	// mentions are the code spans in the README, each with the heading
+	// it comes under.
+	mentions []mention
+
+	// headings are the README's headings in order.
+	headings []string
+}
+
+type mention struct {
+	text    string
+	heading string
+}
+
+// parseReadme collects the headings, links and code spans in readme.
+func parseReadme(readme []byte) *titles {
+	t := &titles{links: make(map[string]string)}
+
+	heading := ""
+	s := bufio.NewScanner(bytes.NewReader(readme))
+	for s.Scan() {
+		line := s.Text()
+
+		if m := headingRE.FindStringSubmatch(line); m != nil {
+			heading = linkRE.ReplaceAllString(m[2], "$1")
+			t.headings = append(t.headings, heading)
+		}
+
+		for _, m := range linkRE.FindAllStringSubmatch(line, -1) {
+			b := blobRE.FindStringSubmatch(m[2])
+			if b == nil {
+				continue
+			}
+			p := b[1]
+			if strings.HasSuffix(p, ".go") {
+				p = path.Dir(p)
+			}
+			if _, ok := t.links[p]; !ok {
+				t.links[p] = m[1]
+			}
+		}
+
+		for _, m := range backtickRE.FindAllStringSubmatch(line, -1) {
+			t.mentions = append(t.mentions, mention{text: m[1], heading: heading})
+		}
+	}
+
+	return t
+}
+
+// title returns the title for the example called name. In order of
+// preference it is the text of a README link to the example, the heading
+// of the README section that first mentions its path, the README heading
+// for its topic followed by the rest of its path, or just its path made
+// readable.
+func (t *titles) title(name string) string {
+	if title, ok := t.links[name]; ok {
+		return title
+	}
+
+	for _, m := range t.mentions {
+		if mentions(m.text, name) && m.heading != "" {
+			return m.heading
+		}
+	}
+
+	parts := strings.Split(name, "/")
+	if len(parts) == 1 {
+		return readable(parts[0])
+	}
+
+	rest := make([]string, 0, len(parts)-2)
+	for _, p := range parts[2:] {
+		rest = append(rest, readable(p))
+	}
+
+	topic := readable(parts[1])
+	if h := t.topicHeading(topic); h != "" {
+		topic = h
+	}
+	if len(rest) == 0 {
+		return topic
+	}
+	return topic + ": " + strings.Join(rest, " / ")
+}
+
+// mentions reports whether text, a code span, contains name as a path, on
+// its own or as the start of a longer path such as name/main.go.
+func mentions(text, name string) bool {
+	for _, word := range strings.Fields(text) {
+		word = strings.TrimPrefix(word, "./")
+		if word == name || strings.HasPrefix(word, name+"/") {
+			return true
+		}
+	}
+	return false
+}
+
+// topicHeading returns the first README heading whose first word matches
+// topic, allowing for plurals, so "sync" finds "Sync Package" and
+// "channel" finds "Channels".
+func (t *titles) topicHeading(topic string) string {
+	topic = strings.ToLower(topic)
+	for _, h := range t.headings {
+		words := strings.Fields(strings.ToLower(h))
+		if len(words) == 0 {
+			continue
+		}
+		w := strings.TrimFunc(words[0], func(r rune) bool { return !unicode.IsLetter(r) })
+		if w == topic || w == topic+"s" || w+"s" == topic {
+			return h
+		}
+	}
+	return ""
+}
+
+// readable turns a directory name such as "02-client-server" into
+// "client server".
+func readable(dir string) string {
+	dir = strings.TrimLeftFunc(dir, unicode.IsDigit)
+	dir = strings.TrimLeft(dir, "-_")
+	return strings.ReplaceAll(dir, "-", " ")
+}
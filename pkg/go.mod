@@ -0,0 +1,3 @@
+module go-concurrency-exercises/pkg
+
+go 1.18
@@ -0,0 +1,217 @@
+// Package leakcheck finds goroutines that are still running after a test
+// has finished with them.
+//
+// Printing runtime.NumGoroutine() after a short sleep only tells us that
+// something is still running, and only if we remember to look. Check takes a
+// snapshot of the goroutines running before the test body starts, and when the
+// test finishes it polls until every goroutine started since then has exited.
+// If some are still running when the deadline passes, the test fails and
+// their stacks are printed.
+package leakcheck
+
+import (
+	"bytes"
+	"fmt"
+	"runtime"
+	"sort"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+)
+
+// DefaultTimeout is how long Check waits for goroutines to exit
+// before reporting them as leaked.
+const DefaultTimeout = time.Second
+
+// ignored holds stack fragments of goroutines the runtime and the
+// standard library start on their own, which are not leaks.
+var ignored = []string{
+	"os/signal.signal_recv",
+	"os/signal.loop",
+	"runtime.ensureSigM",
+	"testing.(*F).Fuzz",
+	"testing.runFuzzing",
+}
+
+// Option configures Check and Snapshot.Leaked.
+type Option func(*config)
+
+type config struct {
+	timeout time.Duration
+	ignore  []string
+}
+
+// Timeout sets how long to wait for goroutines to exit.
+func Timeout(d time.Duration) Option {
+	return func(c *config) {
+		c.timeout = d
+	}
+}
+
+// Ignore skips goroutines whose stack contains any of the given strings,
+// for example a function name like "net/http.(*persistConn).readLoop".
+func Ignore(fragments ...string) Option {
+	return func(c *config) {
+		c.ignore = append(c.ignore, fragments...)
+	}
+}
+
+// Goroutine is a single goroutine taken from a runtime stack dump.
+type Goroutine struct {
+	ID    int
+	State string
+	Stack string
+}
+
+// String returns the goroutine's stack as printed by the runtime.
+func (g Goroutine) String() string {
+	return g.Stack
+}
+
+// Snapshot records the goroutines running at a point in time.
+type Snapshot struct {
+	ids map[int]bool
+}
+
+// Take records the goroutines that are running now.
+func Take() Snapshot {
+	s := Snapshot{ids: make(map[int]bool)}
+	for _, g := range stacks() {
+		s.ids[g.ID] = true
+	}
+	return s
+}
+
+// Leaked polls until every goroutine started since s was taken has exited,
+// or until the timeout passes. It returns the goroutines still running.
+func (s Snapshot) Leaked(opts ...Option) []Goroutine {
+	c := config{timeout: DefaultTimeout, ignore: ignored}
+	for _, opt := range opts {
+		opt(&c)
+	}
+
+	deadline := time.Now().Add(c.timeout)
+	wait := time.Millisecond
+	for {
+		extra := s.extra(c.ignore)
+		if len(extra) == 0 || time.Now().After(deadline) {
+			return extra
+		}
+
+		// Back off so we don't burn the CPU the goroutines we are
+		// waiting for need in order to finish.
+		time.Sleep(wait)
+		if wait < 100*time.Millisecond {
+			wait *= 2
+		}
+	}
+}
+
+// extra returns the running goroutines that were not in s.
+func (s Snapshot) extra(ignore []string) []Goroutine {
+	var extra []Goroutine
+	for _, g := range stacks() {
+		if s.ids[g.ID] || matches(g.Stack, ignore) {
+			continue
+		}
+		extra = append(extra, g)
+	}
+	return extra
+}
+
+// Check snapshots the running goroutines and registers a cleanup on t that
+// fails the test if goroutines started after the snapshot are still running.
+//
+// Call it at the top of the test so the check runs after all other
+// cleanups, such as closing test servers:
+//
+//	func TestPipeline(t *testing.T) {
+//		leakcheck.Check(t)
+//		...
+//	}
+func Check(t testing.TB, opts ...Option) {
+	t.Helper()
+
+	s := Take()
+	t.Cleanup(func() {
+		if leaked := s.Leaked(opts...); len(leaked) > 0 {
+			t.Errorf("found %d leaked goroutine(s):\n\n%s", len(leaked), format(leaked))
+		}
+	})
+}
+
+// format joins the stacks of gs, separated by blank lines.
+func format(gs []Goroutine) string {
+	stacks := make([]string, len(gs))
+	for i, g := range gs {
+		stacks[i] = g.Stack
+	}
+	return strings.Join(stacks, "\n\n")
+}
+
+// matches reports whether stack contains any of fragments.
+func matches(stack string, fragments []string) bool {
+	for _, f := range fragments {
+		if strings.Contains(stack, f) {
+			return true
+		}
+	}
+	return false
+}
+
+// stacks returns every goroutine except the calling one, sorted by ID.
+func stacks() []Goroutine {
+	buf := make([]byte, 64<<10)
+	for {
+		n := runtime.Stack(buf, true)
+		if n < len(buf) {
+			buf = buf[:n]
+			break
+		}
+		buf = make([]byte, 2*len(buf))
+	}
+
+	// The first stack in the dump is always the calling goroutine.
+	dumps := bytes.Split(buf, []byte("\n\n"))
+	var gs []Goroutine
+	for _, d := range dumps[1:] {
+		g, err := parse(string(d))
+		if err != nil {
+			continue
+		}
+		gs = append(gs, g)
+	}
+
+	sort.Slice(gs, func(i, j int) bool { return gs[i].ID < gs[j].ID })
+	return gs
+}
+
+// parse reads a single stack from a runtime stack dump, which starts
+// with a header line like "goroutine 18 [chan receive]:".
+func parse(stack string) (Goroutine, error) {
+	stack = strings.TrimSpace(stack)
+	header := stack
+	if i := strings.IndexByte(stack, '\n'); i >= 0 {
+		header = stack[:i]
+	}
+
+	rest := strings.TrimPrefix(header, "goroutine ")
+	if rest == header {
+		return Goroutine{}, fmt.Errorf("bad goroutine header %q", header)
+	}
+
+	i := strings.IndexByte(rest, ' ')
+	if i < 0 {
+		return Goroutine{}, fmt.Errorf("bad goroutine header %q", header)
+	}
+	id, err := strconv.Atoi(rest[:i])
+	if err != nil {
+		return Goroutine{}, fmt.Errorf("bad goroutine id in %q: %v", header, err)
+	}
+
+	state := strings.TrimSuffix(strings.TrimSpace(rest[i:]), ":")
+	state = strings.TrimSuffix(strings.TrimPrefix(state, "["), "]")
+
+	return Goroutine{ID: id, State: state, Stack: stack}, nil
+}
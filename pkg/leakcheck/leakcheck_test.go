@@ -0,0 +1,128 @@
+package leakcheck
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+	"time"
+)
+
+// fakeT records failures instead of failing the real test.
+type fakeT struct {
+	testing.TB
+	cleanups []func()
+	errors   []string
+}
+
+func (f *fakeT) Helper() {}
+
+func (f *fakeT) Cleanup(fn func()) {
+	f.cleanups = append(f.cleanups, fn)
+}
+
+func (f *fakeT) Errorf(format string, args ...interface{}) {
+	f.errors = append(f.errors, fmt.Sprintf(format, args...))
+}
+
+func (f *fakeT) finish() {
+	for i := len(f.cleanups) - 1; i >= 0; i-- {
+		f.cleanups[i]()
+	}
+}
+
+func blockedForever(block <-chan struct{}) {
+	<-block
+}
+
+func TestLeakedFindsBlockedGoroutine(t *testing.T) {
+	s := Take()
+
+	block := make(chan struct{})
+	go blockedForever(block)
+	defer close(block)
+
+	leaked := s.Leaked(Timeout(50 * time.Millisecond))
+	if len(leaked) != 1 {
+		t.Fatalf("got %d leaked goroutines, want 1", len(leaked))
+	}
+	if !strings.Contains(leaked[0].Stack, "blockedForever") {
+		t.Errorf("leaked stack does not mention blockedForever:\n%s", leaked[0].Stack)
+	}
+	if leaked[0].State != "chan receive" {
+		t.Errorf("got state %q, want %q", leaked[0].State, "chan receive")
+	}
+}
+
+func TestLeakedWaitsForGoroutinesToExit(t *testing.T) {
+	s := Take()
+
+	go func() {
+		time.Sleep(20 * time.Millisecond)
+	}()
+
+	if leaked := s.Leaked(Timeout(time.Second)); len(leaked) != 0 {
+		t.Errorf("got %d leaked goroutines, want 0:\n%s", len(leaked), format(leaked))
+	}
+}
+
+func TestLeakedIgnore(t *testing.T) {
+	s := Take()
+
+	block := make(chan struct{})
+	go blockedForever(block)
+	defer close(block)
+
+	if leaked := s.Leaked(Timeout(20*time.Millisecond), Ignore("blockedForever")); len(leaked) != 0 {
+		t.Errorf("got %d leaked goroutines, want 0:\n%s", len(leaked), format(leaked))
+	}
+}
+
+func TestCheckReportsLeak(t *testing.T) {
+	ft := &fakeT{TB: t}
+	Check(ft, Timeout(20*time.Millisecond))
+
+	block := make(chan struct{})
+	go blockedForever(block)
+	defer close(block)
+
+	ft.finish()
+	if len(ft.errors) != 1 {
+		t.Fatalf("got %d errors, want 1", len(ft.errors))
+	}
+	if !strings.Contains(ft.errors[0], "found 1 leaked goroutine(s)") {
+		t.Errorf("unexpected error: %s", ft.errors[0])
+	}
+}
+
+func TestCheckPasses(t *testing.T) {
+	ft := &fakeT{TB: t}
+	Check(ft)
+
+	done := make(chan struct{})
+	go func() {
+		close(done)
+	}()
+	<-done
+
+	ft.finish()
+	if len(ft.errors) != 0 {
+		t.Errorf("got errors, want none: %v", ft.errors)
+	}
+}
+
+func TestParse(t *testing.T) {
+	g, err := parse("goroutine 18 [chan receive, 2 minutes]:\nmain.main()\n")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if g.ID != 18 {
+		t.Errorf("got id %d, want 18", g.ID)
+	}
+	if g.State != "chan receive, 2 minutes" {
+		t.Errorf("got state %q", g.State)
+	}
+
+	if _, err := parse("not a goroutine"); err == nil {
+		t.Error("expected error for bad header")
+	}
+}
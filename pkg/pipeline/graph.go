@@ -0,0 +1,225 @@
+package pipeline
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// Kind says what a node in the graph does.
+type Kind string
+
+// The kinds of stage the package provides.
+const (
+	KindSource Kind = "source"
+	KindStage  Kind = "stage"
+	KindMerge  Kind = "merge"
+	KindSink   Kind = "sink"
+)
+
+// Graph records the stages of a pipeline and the channels between them.
+// Stages register themselves when they are created, and each counts the
+// values it sends so the graph can report throughput while it runs.
+type Graph struct {
+	mu    sync.Mutex
+	start time.Time
+	nodes []*node
+
+	// producers maps each stage's output channel to the stage, so the
+	// stages reading from it can be linked back to it.
+	producers map[interface{}]*node
+}
+
+type node struct {
+	id       int
+	name     string
+	kind     Kind
+	workers  int
+	capacity int
+	inputs   []interface{}
+
+	// count is the number of values the stage has sent on,
+	// or for a sink, received. Updated atomically.
+	count int64
+}
+
+func (n *node) inc() {
+	atomic.AddInt64(&n.count, 1)
+}
+
+// Node describes a stage in the graph.
+type Node struct {
+	ID   int
+	Name string
+	Kind Kind
+
+	// Workers is the number of goroutines running the stage,
+	// or for a merge, the number of channels merged.
+	Workers int
+
+	// Count is the number of values the stage has sent, or for a sink,
+	// received.
+	Count int64
+}
+
+// Edge is a channel from one stage to another.
+type Edge struct {
+	// From is the ID of the stage writing to the channel, or -1 if the
+	// channel was not created by a stage in the graph.
+	From int
+	To   int
+
+	// Capacity is the channel's buffer size.
+	Capacity int
+}
+
+func newGraph() *Graph {
+	return &Graph{
+		start:     time.Now(),
+		producers: make(map[interface{}]*node),
+	}
+}
+
+// add registers a stage reading from inputs and writing to output,
+// a channel with the given capacity. output is nil for sinks.
+func (g *Graph) add(name string, kind Kind, workers int, inputs []interface{}, output interface{}, capacity int) *node {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	n := &node{
+		id:       len(g.nodes),
+		name:     name,
+		kind:     kind,
+		workers:  workers,
+		capacity: capacity,
+		inputs:   inputs,
+	}
+	g.nodes = append(g.nodes, n)
+	if output != nil {
+		g.producers[output] = n
+	}
+	return n
+}
+
+// Nodes returns the stages in the order they were created.
+func (g *Graph) Nodes() []Node {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	nodes := make([]Node, len(g.nodes))
+	for i, n := range g.nodes {
+		nodes[i] = Node{
+			ID:      n.id,
+			Name:    n.name,
+			Kind:    n.kind,
+			Workers: n.workers,
+			Count:   atomic.LoadInt64(&n.count),
+		}
+	}
+	return nodes
+}
+
+// Edges returns the channels between stages.
+func (g *Graph) Edges() []Edge {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	var edges []Edge
+	for _, n := range g.nodes {
+		for _, in := range n.inputs {
+			e := Edge{From: -1, To: n.id}
+			if p, ok := g.producers[in]; ok {
+				e.From = p.id
+				e.Capacity = p.capacity
+			}
+			edges = append(edges, e)
+		}
+	}
+	return edges
+}
+
+// Elapsed returns how long ago the graph was created.
+func (g *Graph) Elapsed() time.Duration {
+	return time.Since(g.start)
+}
+
+// WriteDOT writes the graph in Graphviz DOT format. If stats is true each
+// stage is annotated with the number of values it has handled so far and
+// its average rate since the pipeline was created.
+//
+// Render it with `dot -Tpng -o pipeline.png`.
+func (g *Graph) WriteDOT(w io.Writer, stats bool) error {
+	nodes := g.Nodes()
+	edges := g.Edges()
+	elapsed := g.Elapsed()
+
+	bw := bufio.NewWriter(w)
+
+	fmt.Fprintln(bw, "digraph pipeline {")
+	fmt.Fprintln(bw, "\trankdir=LR;")
+	fmt.Fprintln(bw, "\tnode [shape=box];")
+
+	external := false
+	for _, e := range edges {
+		if e.From < 0 {
+			external = true
+		}
+	}
+	if external {
+		fmt.Fprintln(bw, "\tinput [shape=plaintext];")
+	}
+
+	for _, n := range nodes {
+		label := []string{n.Name, string(n.Kind)}
+		switch {
+		case n.Kind == KindStage && n.Workers > 1:
+			label[1] = fmt.Sprintf("stage ×%d", n.Workers)
+		case n.Kind == KindMerge:
+			label[1] = fmt.Sprintf("merge %d", n.Workers)
+		}
+		if stats {
+			label = append(label, fmt.Sprintf("%d items, %.1f/s", n.Count, rate(n.Count, elapsed)))
+		}
+		fmt.Fprintf(bw, "\tn%d [label=%s%s];\n", n.ID, quote(strings.Join(label, "\n")), shape(n.Kind))
+	}
+
+	for _, e := range edges {
+		from := "input"
+		if e.From >= 0 {
+			from = fmt.Sprintf("n%d", e.From)
+		}
+		fmt.Fprintf(bw, "\t%s -> n%d [label=%s];\n", from, e.To, quote(fmt.Sprintf("cap %d", e.Capacity)))
+	}
+
+	fmt.Fprintln(bw, "}")
+
+	return bw.Flush()
+}
+
+// shape returns the node attributes that pick out sources and sinks.
+func shape(k Kind) string {
+	switch k {
+	case KindSource, KindSink:
+		return ", shape=ellipse"
+	case KindMerge:
+		return ", shape=invtrapezium"
+	}
+	return ""
+}
+
+// quote returns s as a DOT string.
+func quote(s string) string {
+	r := strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`)
+	return `"` + r.Replace(s) + `"`
+}
+
+func rate(count int64, elapsed time.Duration) float64 {
+	if elapsed <= 0 {
+		return 0
+	}
+	return float64(count) / elapsed.Seconds()
+}
@@ -0,0 +1,123 @@
+package pipeline
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+)
+
+// build creates the generator -> square x2 -> merge -> print pipeline
+// from the exercises, and runs it to completion.
+func build(t *testing.T) *Pipeline {
+	t.Helper()
+
+	done := make(chan struct{})
+	t.Cleanup(func() { close(done) })
+
+	p := New(done)
+	in := Generator(p, "generator", 2, 3)
+	c1 := Stage(p, "square", 1, 0, in, square)
+	c2 := Stage(p, "square", 3, 5, in, square)
+	Sink(p, "print", Merge(p, "merge", c1, c2), func(int) {})
+	return p
+}
+
+func TestGraphEdges(t *testing.T) {
+	g := build(t).Graph()
+
+	want := []Edge{
+		{From: 0, To: 1, Capacity: 0},
+		{From: 0, To: 2, Capacity: 0},
+		{From: 1, To: 3, Capacity: 0},
+		{From: 2, To: 3, Capacity: 5},
+		{From: 3, To: 4, Capacity: 0},
+	}
+	got := g.Edges()
+	if len(got) != len(want) {
+		t.Fatalf("got %d edges %v, want %v", len(got), got, want)
+	}
+	for i := range want {
+		if got[i] != want[i] {
+			t.Errorf("edge %d: got %+v, want %+v", i, got[i], want[i])
+		}
+	}
+}
+
+func TestGraphCounts(t *testing.T) {
+	g := build(t).Graph()
+
+	counts := map[Kind]int64{}
+	for _, n := range g.Nodes() {
+		counts[n.Kind] += n.Count
+	}
+
+	// Both values pass through every kind of stage once.
+	for _, k := range []Kind{KindSource, KindStage, KindMerge, KindSink} {
+		if counts[k] != 2 {
+			t.Errorf("%s handled %d values, want 2", k, counts[k])
+		}
+	}
+}
+
+func TestWriteDOT(t *testing.T) {
+	g := build(t).Graph()
+
+	var buf bytes.Buffer
+	if err := g.WriteDOT(&buf, false); err != nil {
+		t.Fatal(err)
+	}
+
+	want := `digraph pipeline {
+	rankdir=LR;
+	node [shape=box];
+	n0 [label="generator\nsource", shape=ellipse];
+	n1 [label="square\nstage"];
+	n2 [label="square\nstage ×3"];
+	n3 [label="merge\nmerge 2", shape=invtrapezium];
+	n4 [label="print\nsink", shape=ellipse];
+	n0 -> n1 [label="cap 0"];
+	n0 -> n2 [label="cap 0"];
+	n1 -> n3 [label="cap 0"];
+	n2 -> n3 [label="cap 5"];
+	n3 -> n4 [label="cap 0"];
+}
+`
+	if got := buf.String(); got != want {
+		t.Errorf("got:\n%s\nwant:\n%s", got, want)
+	}
+}
+
+func TestWriteDOTStats(t *testing.T) {
+	g := build(t).Graph()
+
+	var buf bytes.Buffer
+	if err := g.WriteDOT(&buf, true); err != nil {
+		t.Fatal(err)
+	}
+
+	if !strings.Contains(buf.String(), `n0 [label="generator\nsource\n2 items, `) {
+		t.Errorf("missing stats in:\n%s", buf.String())
+	}
+}
+
+func TestWriteDOTExternalInput(t *testing.T) {
+	done := make(chan struct{})
+	defer close(done)
+
+	in := make(chan int)
+	close(in)
+
+	p := New(done)
+	Sink(p, "print", Stage(p, "square", 1, 0, in, square), func(int) {})
+
+	var buf bytes.Buffer
+	if err := p.Graph().WriteDOT(&buf, false); err != nil {
+		t.Fatal(err)
+	}
+
+	for _, want := range []string{"input [shape=plaintext];", `input -> n0 [label="cap 0"];`} {
+		if !strings.Contains(buf.String(), want) {
+			t.Errorf("missing %q in:\n%s", want, buf.String())
+		}
+	}
+}
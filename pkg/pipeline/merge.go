@@ -0,0 +1,195 @@
+package pipeline
+
+import (
+	"reflect"
+)
+
+// WeightedMerge fans in the channels in cs like Merge, but when several of
+// them have values waiting it shares the output between them in
+// proportion to weights, which holds one weight per channel. With weights
+// 1 and 3, the second channel gets three values through for every one from
+// the first, as long as both keep up.
+//
+// Channels are picked with smooth weighted round robin, so the values from
+// a heavy channel are spread out rather than sent in bursts. A channel with
+// nothing waiting doesn't build up credit while it is idle.
+func WeightedMerge[T any](p *Pipeline, name string, weights []int, cs ...<-chan T) <-chan T {
+	if len(weights) != len(cs) {
+		panic("pipeline: WeightedMerge needs one weight per channel")
+	}
+	for _, w := range weights {
+		if w < 1 {
+			panic("pipeline: WeightedMerge weights must be at least 1")
+		}
+	}
+
+	current := make([]int, len(cs))
+
+	return merge(p, name, cs, func(ready []bool) int {
+		total := 0
+		best := -1
+		for i, ok := range ready {
+			if !ok {
+				continue
+			}
+			current[i] += weights[i]
+			total += weights[i]
+			if best < 0 || current[i] > current[best] {
+				best = i
+			}
+		}
+		current[best] -= total
+		return best
+	})
+}
+
+// PriorityMerge fans in the channels in cs like Merge, but when several of
+// them have values waiting it sends from the first one in cs, so cs[0] has
+// the highest priority.
+//
+// To stop a busy high priority channel starving the others, every time a
+// channel with a value waiting is passed over it ages by one. Once it has
+// aged by aging, it moves up one priority level, and it goes back to its
+// own level after it has been picked. With two busy channels and an aging
+// of 9, the second channel gets one value through in every ten. If aging
+// is less than 1, priorities are strict and low priority channels can
+// starve.
+func PriorityMerge[T any](p *Pipeline, name string, aging int, cs ...<-chan T) <-chan T {
+	age := make([]int, len(cs))
+
+	return merge(p, name, cs, func(ready []bool) int {
+		best := -1
+		bestScore := 0
+		for i, ok := range ready {
+			if !ok {
+				continue
+			}
+
+			score := i
+			if aging > 0 {
+				score = i*aging - age[i]
+			}
+
+			// On a tie the channel that has waited longest wins.
+			if best < 0 || score < bestScore || (score == bestScore && age[i] > age[best]) {
+				best = i
+				bestScore = score
+			}
+		}
+
+		for i, ok := range ready {
+			if ok && i != best {
+				age[i]++
+			}
+		}
+		age[best] = 0
+		return best
+	})
+}
+
+// merge fans in cs, holding at most one value from each channel. While
+// any values are held, pick is called with the channels that have one
+// and returns the one to send next.
+func merge[T any](p *Pipeline, name string, cs []<-chan T, pick func(ready []bool) int) <-chan T {
+	out := make(chan T)
+	n := p.graph.add(name, KindMerge, len(cs), chans(cs), (<-chan T)(out), cap(out))
+
+	go func() {
+		// defer close(out) to ensure closure if goroutine terminated
+		defer close(out)
+
+		// Take a copy so closed channels can be set to nil
+		// without changing the caller's slice.
+		cs := append([]<-chan T(nil), cs...)
+		open := len(cs)
+
+		held := make([]T, len(cs))
+		ready := make([]bool, len(cs))
+		waiting := 0
+
+		// receive stores a value received from cs[i], or if cs[i] has
+		// been closed, stops reading from it.
+		receive := func(i int, v T, ok bool) {
+			if !ok {
+				cs[i] = nil
+				open--
+				return
+			}
+			held[i] = v
+			ready[i] = true
+			waiting++
+		}
+
+		for open > 0 || waiting > 0 {
+			// Take a value from every channel that has one ready
+			// without blocking, so pick can choose between them.
+			for i, c := range cs {
+				if c == nil || ready[i] {
+					continue
+				}
+				select {
+				case v, ok := <-c:
+					receive(i, v, ok)
+				default:
+				}
+			}
+
+			if waiting == 0 {
+				if open == 0 {
+					return
+				}
+
+				// Nothing is ready, so block until a channel has
+				// a value or is closed.
+				i, v, ok, cancelled := wait(p.done, cs)
+				if cancelled {
+					return
+				}
+				receive(i, v, ok)
+				continue
+			}
+
+			i := pick(ready)
+			select {
+			case out <- held[i]:
+				n.inc()
+				var zero T
+				held[i] = zero
+				ready[i] = false
+				waiting--
+			case <-p.done:
+				return
+			}
+		}
+	}()
+
+	return out
+}
+
+// wait blocks until one of the non-nil channels in cs can be received
+// from, or done is closed. The number of channels is only known at run
+// time, so it uses reflect.Select rather than a select statement.
+func wait[T any](done <-chan struct{}, cs []<-chan T) (i int, v T, ok bool, cancelled bool) {
+	cases := make([]reflect.SelectCase, 0, len(cs)+1)
+	index := make([]int, 0, len(cs))
+
+	cases = append(cases, reflect.SelectCase{Dir: reflect.SelectRecv, Chan: reflect.ValueOf(done)})
+	for i, c := range cs {
+		if c == nil {
+			continue
+		}
+		cases = append(cases, reflect.SelectCase{Dir: reflect.SelectRecv, Chan: reflect.ValueOf(c)})
+		index = append(index, i)
+	}
+
+	chosen, value, ok := reflect.Select(cases)
+	if chosen == 0 {
+		return 0, v, false, true
+	}
+	if ok {
+		// A nil interface value can't be asserted to T,
+		// so leave v as the zero value.
+		v, _ = value.Interface().(T)
+	}
+	return index[chosen-1], v, ok, false
+}
@@ -0,0 +1,196 @@
+package pipeline
+
+import (
+	"math"
+	"sort"
+	"testing"
+
+	"go-concurrency-exercises/pkg/leakcheck"
+)
+
+// saturate returns a channel that always has a value waiting, until
+// done is closed.
+func saturate(done <-chan struct{}, v int) <-chan int {
+	out := make(chan int, 64)
+	go func() {
+		defer close(out)
+		for {
+			select {
+			case out <- v:
+			case <-done:
+				return
+			}
+		}
+	}()
+	return out
+}
+
+// shares reads n values from out and returns the fraction of them
+// that had each value from 0 to inputs-1.
+func shares(out <-chan int, inputs, n int) []float64 {
+	counts := make([]int, inputs)
+	for i := 0; i < n; i++ {
+		counts[<-out]++
+	}
+
+	s := make([]float64, inputs)
+	for i, c := range counts {
+		s[i] = float64(c) / float64(n)
+	}
+	return s
+}
+
+func checkShares(t *testing.T, got, want []float64) {
+	t.Helper()
+
+	// The producers can fall behind for a moment, which lets the
+	// other inputs through, so allow some slack.
+	const tolerance = 0.03
+	for i := range want {
+		if math.Abs(got[i]-want[i]) > tolerance {
+			t.Errorf("input %d got %.3f of the output, want %.3f ± %.2f", i, got[i], want[i], tolerance)
+		}
+	}
+}
+
+func TestWeightedMergeDeliversEverything(t *testing.T) {
+	leakcheck.Check(t)
+
+	done := make(chan struct{})
+	defer close(done)
+
+	p := New(done)
+	a := Generator(p, "a", 1, 2, 3)
+	b := Generator(p, "b", 4, 5)
+	c := Generator(p, "c", 6)
+
+	var got []int
+	Sink(p, "collect", WeightedMerge(p, "merge", []int{1, 2, 3}, a, b, c), func(n int) {
+		got = append(got, n)
+	})
+
+	sort.Ints(got)
+	if len(got) != 6 {
+		t.Fatalf("got %v, want 1 to 6", got)
+	}
+	for i, n := range got {
+		if n != i+1 {
+			t.Fatalf("got %v, want 1 to 6", got)
+		}
+	}
+}
+
+func TestWeightedMergeRatios(t *testing.T) {
+	leakcheck.Check(t)
+
+	done := make(chan struct{})
+	defer close(done)
+
+	p := New(done)
+	out := WeightedMerge(p, "merge", []int{1, 2, 5},
+		saturate(done, 0), saturate(done, 1), saturate(done, 2))
+
+	checkShares(t, shares(out, 3, 20000), []float64{1.0 / 8, 2.0 / 8, 5.0 / 8})
+}
+
+func TestWeightedMergeSmooth(t *testing.T) {
+	leakcheck.Check(t)
+
+	done := make(chan struct{})
+	defer close(done)
+
+	in := make([]chan int, 2)
+	for i := range in {
+		in[i] = make(chan int, 10)
+		for j := 0; j < 10; j++ {
+			in[i] <- i
+		}
+		close(in[i])
+	}
+
+	p := New(done)
+	out := WeightedMerge(p, "merge", []int{1, 2}, in[0], in[1])
+
+	// With both inputs full, smooth weighted round robin
+	// interleaves them rather than sending runs.
+	var got []int
+	for i := 0; i < 6; i++ {
+		got = append(got, <-out)
+	}
+	want := []int{1, 0, 1, 1, 0, 1}
+	for i := range want {
+		if got[i] != want[i] {
+			t.Fatalf("got %v, want %v", got, want)
+		}
+	}
+}
+
+func TestPriorityMergeAging(t *testing.T) {
+	leakcheck.Check(t)
+
+	done := make(chan struct{})
+	defer close(done)
+
+	p := New(done)
+	out := PriorityMerge(p, "merge", 9, saturate(done, 0), saturate(done, 1))
+
+	checkShares(t, shares(out, 2, 20000), []float64{0.9, 0.1})
+}
+
+func TestPriorityMergeAgingThreeInputs(t *testing.T) {
+	leakcheck.Check(t)
+
+	done := make(chan struct{})
+	defer close(done)
+
+	p := New(done)
+	out := PriorityMerge(p, "merge", 4, saturate(done, 0), saturate(done, 1), saturate(done, 2))
+
+	got := shares(out, 3, 20000)
+
+	// Aging keeps every input moving, with the shares
+	// still in priority order.
+	if !(got[0] > got[1] && got[1] > got[2] && got[2] > 0.02) {
+		t.Errorf("got shares %v, want them decreasing and none starved", got)
+	}
+}
+
+func TestPriorityMergeStrict(t *testing.T) {
+	leakcheck.Check(t)
+
+	done := make(chan struct{})
+	defer close(done)
+
+	p := New(done)
+	out := PriorityMerge(p, "merge", 0, saturate(done, 0), saturate(done, 1))
+
+	got := shares(out, 2, 20000)
+	if got[1] > 0.03 {
+		t.Errorf("low priority input got %.3f of the output, want close to 0", got[1])
+	}
+}
+
+func TestPriorityMergeCancelled(t *testing.T) {
+	leakcheck.Check(t)
+
+	done := make(chan struct{})
+
+	p := New(done)
+	out := PriorityMerge(p, "merge", 3, saturate(done, 0), saturate(done, 1))
+	<-out
+
+	close(done)
+}
+
+func TestWeightedMergeWeightsMismatch(t *testing.T) {
+	defer func() {
+		if recover() == nil {
+			t.Error("expected panic")
+		}
+	}()
+
+	done := make(chan struct{})
+	defer close(done)
+
+	WeightedMerge(New(done), "merge", []int{1}, make(chan int), make(chan int))
+}
@@ -0,0 +1,196 @@
+// Package pipeline provides the generator, square and merge stages from the
+// pipeline exercises as reusable, generic stages.
+//
+// Every stage takes the Pipeline it belongs to as its first parameter, the
+// same way the exercises pass the done channel first. Closing the done
+// channel stops every stage. Each stage also registers itself in the
+// pipeline's Graph, so the shape of a running pipeline can be written out
+// with Graph.WriteDOT.
+package pipeline
+
+import (
+	"sync"
+)
+
+// Pipeline holds what every stage needs: the done channel that cancels
+// the stages and the graph they register themselves in.
+type Pipeline struct {
+	done  <-chan struct{}
+	graph *Graph
+}
+
+// New returns a pipeline whose stages stop when done is closed.
+func New(done <-chan struct{}) *Pipeline {
+	return &Pipeline{
+		done:  done,
+		graph: newGraph(),
+	}
+}
+
+// Done returns the pipeline's done channel.
+func (p *Pipeline) Done() <-chan struct{} {
+	return p.done
+}
+
+// Graph returns the graph the pipeline's stages are registered in.
+func (p *Pipeline) Graph() *Graph {
+	return p.graph
+}
+
+// Generator is the first stage of a pipeline. It sends items on the
+// returned channel, then closes it.
+func Generator[T any](p *Pipeline, name string, items ...T) <-chan T {
+	out := make(chan T)
+	n := p.graph.add(name, KindSource, 1, nil, (<-chan T)(out), cap(out))
+
+	go func() {
+		// defer close(out) to ensure closure if goroutine terminated
+		defer close(out)
+
+		for _, item := range items {
+			select {
+			case out <- item:
+				n.inc()
+			case <-p.done:
+				return
+			}
+		}
+	}()
+
+	return out
+}
+
+// Stage starts workers goroutines that each read from in, call f and
+// send the result on the returned channel. Using more than one worker
+// fans the stage out. capacity sets the buffer size of the returned
+// channel. The channel is closed once in is closed and every worker
+// has finished, or when the pipeline is cancelled.
+func Stage[T, U any](p *Pipeline, name string, workers, capacity int, in <-chan T, f func(T) U) <-chan U {
+	if workers < 1 {
+		workers = 1
+	}
+
+	out := make(chan U, capacity)
+	n := p.graph.add(name, KindStage, workers, []interface{}{in}, (<-chan U)(out), cap(out))
+
+	var wg sync.WaitGroup
+	wg.Add(workers)
+	for i := 0; i < workers; i++ {
+		go func() {
+			defer wg.Done()
+
+			for v := range in {
+				select {
+				case out <- f(v):
+					n.inc()
+				case <-p.done:
+					return
+				}
+			}
+		}()
+	}
+
+	go func() {
+		wg.Wait()
+		close(out)
+	}()
+
+	return out
+}
+
+// Filter sends on the values from in for which keep returns true, and
+// drops the rest. With a checkpoint journal it lets a restarted pipeline
+// skip the items an earlier run finished:
+//
+//	todo := pipeline.Filter(p, "skip completed", paths, func(path string) bool {
+//		return !journal.Completed(path)
+//	})
+func Filter[T any](p *Pipeline, name string, in <-chan T, keep func(T) bool) <-chan T {
+	out := make(chan T)
+	n := p.graph.add(name, KindStage, 1, []interface{}{in}, (<-chan T)(out), cap(out))
+
+	go func() {
+		// defer close(out) to ensure closure if goroutine terminated
+		defer close(out)
+
+		for v := range in {
+			if !keep(v) {
+				continue
+			}
+			select {
+			case out <- v:
+				n.inc()
+			case <-p.done:
+				return
+			}
+		}
+	}()
+
+	return out
+}
+
+// Merge fans in the channels in cs, sending every value from them on the
+// returned channel. The channel is closed once all of cs are closed, or
+// when the pipeline is cancelled.
+func Merge[T any](p *Pipeline, name string, cs ...<-chan T) <-chan T {
+	out := make(chan T)
+	n := p.graph.add(name, KindMerge, len(cs), chans(cs), (<-chan T)(out), cap(out))
+
+	var wg sync.WaitGroup
+
+	output := func(c <-chan T) {
+		// defer wg.Done() to ensure it's run even if done
+		// channel makes us return.
+		defer wg.Done()
+
+		for v := range c {
+			select {
+			case out <- v:
+				n.inc()
+			case <-p.done:
+				return
+			}
+		}
+	}
+
+	wg.Add(len(cs))
+	for _, c := range cs {
+		go output(c)
+	}
+
+	go func() {
+		wg.Wait()
+		close(out)
+	}()
+
+	return out
+}
+
+// Sink is the last stage of a pipeline. It calls f for every value
+// received from in, and returns when in is closed or the pipeline is
+// cancelled.
+func Sink[T any](p *Pipeline, name string, in <-chan T, f func(T)) {
+	n := p.graph.add(name, KindSink, 1, []interface{}{in}, nil, 0)
+
+	for {
+		select {
+		case v, ok := <-in:
+			if !ok {
+				return
+			}
+			f(v)
+			n.inc()
+		case <-p.done:
+			return
+		}
+	}
+}
+
+// chans converts cs to the keys the graph uses to link stages together.
+func chans[T any](cs []<-chan T) []interface{} {
+	keys := make([]interface{}, len(cs))
+	for i, c := range cs {
+		keys[i] = c
+	}
+	return keys
+}
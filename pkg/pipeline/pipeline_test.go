@@ -0,0 +1,118 @@
+package pipeline
+
+import (
+	"path/filepath"
+	"sort"
+	"strconv"
+	"testing"
+
+	"go-concurrency-exercises/pkg/checkpoint"
+	"go-concurrency-exercises/pkg/leakcheck"
+)
+
+func square(n int) int {
+	return n * n
+}
+
+func TestPipeline(t *testing.T) {
+	leakcheck.Check(t)
+
+	done := make(chan struct{})
+	defer close(done)
+
+	p := New(done)
+	in := Generator(p, "generator", 1, 2, 3, 4)
+	c1 := Stage(p, "square", 1, 0, in, square)
+	c2 := Stage(p, "square", 1, 0, in, square)
+
+	var got []int
+	Sink(p, "print", Merge(p, "merge", c1, c2), func(n int) {
+		got = append(got, n)
+	})
+
+	sort.Ints(got)
+	want := []int{1, 4, 9, 16}
+	if len(got) != len(want) {
+		t.Fatalf("got %v, want %v", got, want)
+	}
+	for i := range want {
+		if got[i] != want[i] {
+			t.Fatalf("got %v, want %v", got, want)
+		}
+	}
+}
+
+func TestStageWorkers(t *testing.T) {
+	leakcheck.Check(t)
+
+	done := make(chan struct{})
+	defer close(done)
+
+	p := New(done)
+	nums := make([]int, 100)
+	for i := range nums {
+		nums[i] = i
+	}
+
+	sum := 0
+	Sink(p, "sum", Stage(p, "square", 4, 10, Generator(p, "generator", nums...), square), func(n int) {
+		sum += n
+	})
+
+	if sum != 328350 {
+		t.Errorf("got sum %d, want 328350", sum)
+	}
+}
+
+func TestPipelineCancelled(t *testing.T) {
+	leakcheck.Check(t)
+
+	done := make(chan struct{})
+
+	p := New(done)
+	in := Generator(p, "generator", 2, 3)
+	c1 := Stage(p, "square", 1, 0, in, square)
+	c2 := Stage(p, "square", 1, 0, in, square)
+	out := Merge(p, "merge", c1, c2)
+
+	if n := <-out; n != 4 && n != 9 {
+		t.Errorf("got %d, want 4 or 9", n)
+	}
+
+	close(done)
+}
+
+func TestFilterSkipsCompleted(t *testing.T) {
+	leakcheck.Check(t)
+
+	done := make(chan struct{})
+	defer close(done)
+
+	journal, err := checkpoint.Open(filepath.Join(t.TempDir(), "journal"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer journal.Close()
+
+	// A first run finished 1 and 3 before it was stopped.
+	journal.Mark("1")
+	journal.Mark("3")
+
+	p := New(done)
+	todo := Filter(p, "skip completed", Generator(p, "generator", 1, 2, 3, 4), func(n int) bool {
+		return !journal.Completed(strconv.Itoa(n))
+	})
+
+	var got []int
+	Sink(p, "process", todo, func(n int) {
+		got = append(got, n)
+		journal.Mark(strconv.Itoa(n))
+	})
+
+	if len(got) != 2 || got[0] != 2 || got[1] != 4 {
+		t.Errorf("got %v, want [2 4]", got)
+	}
+	if journal.Len() != 4 {
+		t.Errorf("journal has %d keys, want 4", journal.Len())
+	}
+}
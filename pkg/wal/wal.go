@@ -0,0 +1,500 @@
+// Package wal is a write-ahead log of jobs, so queued jobs survive the
+// process exiting.
+//
+// Append writes a job's payload to the log and syncs it to disk before
+// returning, so once it returns the job will not be lost. When the job is
+// done, Ack writes an acknowledgement. Opening the log again replays it,
+// and Pending returns every job that was appended but never acknowledged,
+// to be run again. Jobs are therefore delivered at least once: a job that
+// finished just before a crash, but whose acknowledgement was not yet on
+// disk, is delivered again.
+//
+// The log is split into segment files. Once the active segment reaches
+// its size limit a new one is started, and segments at the start of the
+// log whose jobs have all been acknowledged are deleted. Compact rewrites
+// the jobs still pending into a fresh segment, so a single slow job
+// doesn't keep every segment after it on disk.
+package wal
+
+import (
+	"bufio"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"hash/crc32"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// DefaultSegmentSize is the size at which a new segment is started.
+const DefaultSegmentSize = 4 << 20
+
+const (
+	// headerSize is the length and checksum in front of every record.
+	headerSize = 8
+
+	// The record types, written after the header. Each is followed
+	// by the job ID, and an enqueue record by the payload too.
+	typeEnqueue = 1
+	typeAck     = 2
+
+	segmentExt = ".wal"
+)
+
+var (
+	// ErrClosed is returned when using a log after Close.
+	ErrClosed = errors.New("wal: log closed")
+
+	// ErrCorrupt is returned by Open when a segment other than the last
+	// one has a damaged record. A damaged record at the end of the last
+	// segment is expected after a crash, and is dropped.
+	ErrCorrupt = errors.New("wal: corrupt segment")
+)
+
+// Record is a job read back from the log.
+type Record struct {
+	ID      uint64
+	Payload []byte
+}
+
+// Option configures a Log.
+type Option func(*Log)
+
+// SegmentSize sets the size at which a new segment is started.
+func SegmentSize(n int64) Option {
+	return func(l *Log) {
+		if n > 0 {
+			l.segmentSize = n
+		}
+	}
+}
+
+// Log is a write-ahead log of jobs. It is safe for concurrent use.
+type Log struct {
+	dir         string
+	segmentSize int64
+
+	mu     sync.Mutex
+	f      *os.File
+	seg    uint64
+	size   int64
+	nextID uint64
+	closed bool
+
+	// pending maps the ID of every job not yet acknowledged to its
+	// payload and the segment it was written to.
+	pending map[uint64]pendingJob
+
+	// live counts the pending jobs written to each segment.
+	live map[uint64]int
+
+	// segs holds the segment numbers on disk, oldest first.
+	segs []uint64
+}
+
+type pendingJob struct {
+	payload []byte
+	seg     uint64
+}
+
+// Open opens the log in dir, creating the directory if needed, and
+// replays the segments already in it.
+func Open(dir string, opts ...Option) (*Log, error) {
+	l := &Log{
+		dir:         dir,
+		segmentSize: DefaultSegmentSize,
+		nextID:      1,
+		pending:     make(map[uint64]pendingJob),
+		live:        make(map[uint64]int),
+	}
+	for _, opt := range opts {
+		opt(l)
+	}
+
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return nil, err
+	}
+
+	segs, err := segments(dir)
+	if err != nil {
+		return nil, err
+	}
+
+	for i, seg := range segs {
+		last := i == len(segs)-1
+		if err := l.replay(seg, last); err != nil {
+			return nil, err
+		}
+	}
+	l.segs = segs
+
+	if len(segs) == 0 {
+		if err := l.rotate(); err != nil {
+			return nil, err
+		}
+		return l, nil
+	}
+
+	// Carry on appending to the last segment.
+	seg := segs[len(segs)-1]
+	f, err := os.OpenFile(l.path(seg), os.O_WRONLY|os.O_APPEND, 0o644)
+	if err != nil {
+		return nil, err
+	}
+	info, err := f.Stat()
+	if err != nil {
+		f.Close()
+		return nil, err
+	}
+	l.f = f
+	l.seg = seg
+	l.size = info.Size()
+
+	return l, nil
+}
+
+// segments returns the numbers of the segment files in dir, in order.
+func segments(dir string) ([]uint64, error) {
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return nil, err
+	}
+
+	var segs []uint64
+	for _, e := range entries {
+		name := e.Name()
+		if e.IsDir() || !strings.HasSuffix(name, segmentExt) {
+			continue
+		}
+		seg, err := strconv.ParseUint(strings.TrimSuffix(name, segmentExt), 16, 64)
+		if err != nil {
+			continue
+		}
+		segs = append(segs, seg)
+	}
+
+	sort.Slice(segs, func(i, j int) bool { return segs[i] < segs[j] })
+	return segs, nil
+}
+
+func (l *Log) path(seg uint64) string {
+	return filepath.Join(l.dir, fmt.Sprintf("%016x%s", seg, segmentExt))
+}
+
+// replay applies the records in segment seg. If last is true, a damaged
+// record is taken to be a write cut short by a crash, and it and anything
+// after it are truncated away.
+func (l *Log) replay(seg uint64, last bool) error {
+	f, err := os.OpenFile(l.path(seg), os.O_RDWR, 0)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	r := bufio.NewReader(f)
+	var good int64
+	for {
+		typ, id, payload, n, err := readRecord(r)
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			if !last {
+				return fmt.Errorf("%w %s at offset %d: %v", ErrCorrupt, l.path(seg), good, err)
+			}
+			if err := f.Truncate(good); err != nil {
+				return err
+			}
+			return f.Sync()
+		}
+		good += n
+
+		if id >= l.nextID {
+			l.nextID = id + 1
+		}
+
+		switch typ {
+		case typeEnqueue:
+			if _, ok := l.pending[id]; ok {
+				// Compact copies pending jobs forward, so a crash
+				// during Compact can leave a job in two segments.
+				l.live[l.pending[id].seg]--
+			}
+			l.pending[id] = pendingJob{payload: payload, seg: seg}
+			l.live[seg]++
+		case typeAck:
+			if p, ok := l.pending[id]; ok {
+				l.live[p.seg]--
+				delete(l.pending, id)
+			}
+		}
+	}
+}
+
+// readRecord reads a record, returning its type, job ID, payload and
+// length on disk.
+func readRecord(r *bufio.Reader) (typ byte, id uint64, payload []byte, n int64, err error) {
+	var header [headerSize]byte
+	if _, err := io.ReadFull(r, header[:]); err != nil {
+		if err == io.EOF {
+			return 0, 0, nil, 0, io.EOF
+		}
+		return 0, 0, nil, 0, fmt.Errorf("short header: %v", err)
+	}
+
+	length := binary.BigEndian.Uint32(header[0:4])
+	sum := binary.BigEndian.Uint32(header[4:8])
+	if length < 9 || length > 1<<30 {
+		return 0, 0, nil, 0, fmt.Errorf("bad record length %d", length)
+	}
+
+	body := make([]byte, length)
+	if _, err := io.ReadFull(r, body); err != nil {
+		return 0, 0, nil, 0, fmt.Errorf("short record: %v", err)
+	}
+	if crc32.ChecksumIEEE(body) != sum {
+		return 0, 0, nil, 0, errors.New("checksum mismatch")
+	}
+
+	typ = body[0]
+	if typ != typeEnqueue && typ != typeAck {
+		return 0, 0, nil, 0, fmt.Errorf("unknown record type %d", typ)
+	}
+	return typ, binary.BigEndian.Uint64(body[1:9]), body[9:], int64(headerSize + length), nil
+}
+
+// encode returns a record ready to write.
+func encode(typ byte, id uint64, payload []byte) []byte {
+	buf := make([]byte, headerSize+9+len(payload))
+	body := buf[headerSize:]
+	body[0] = typ
+	binary.BigEndian.PutUint64(body[1:9], id)
+	copy(body[9:], payload)
+
+	binary.BigEndian.PutUint32(buf[0:4], uint32(len(body)))
+	binary.BigEndian.PutUint32(buf[4:8], crc32.ChecksumIEEE(body))
+	return buf
+}
+
+// write appends rec to the active segment and syncs it, starting a new
+// segment first if the active one is full. l.mu must be held.
+func (l *Log) write(rec []byte) error {
+	if l.size > 0 && l.size+int64(len(rec)) > l.segmentSize {
+		if err := l.rotate(); err != nil {
+			return err
+		}
+	}
+
+	if _, err := l.f.Write(rec); err != nil {
+		return err
+	}
+	if err := l.f.Sync(); err != nil {
+		return err
+	}
+	l.size += int64(len(rec))
+	return nil
+}
+
+// rotate starts a new active segment, then deletes the segments at the
+// start of the log that have no pending jobs left. l.mu must be held.
+func (l *Log) rotate() error {
+	seg := uint64(1)
+	if len(l.segs) > 0 {
+		seg = l.segs[len(l.segs)-1] + 1
+	}
+
+	f, err := os.OpenFile(l.path(seg), os.O_WRONLY|os.O_CREATE|os.O_EXCL|os.O_APPEND, 0o644)
+	if err != nil {
+		return err
+	}
+	if err := syncDir(l.dir); err != nil {
+		f.Close()
+		return err
+	}
+
+	if l.f != nil {
+		l.f.Close()
+	}
+	l.f = f
+	l.seg = seg
+	l.size = 0
+	l.segs = append(l.segs, seg)
+
+	return l.dropAcked()
+}
+
+// dropAcked deletes segments from the start of the log, up to the active
+// one, that have no pending jobs. Only a run at the start can go: a later
+// segment may hold the acknowledgement for a job in an earlier one, and
+// deleting it would bring the job back. l.mu must be held.
+func (l *Log) dropAcked() error {
+	removed := 0
+	for _, seg := range l.segs {
+		if seg == l.seg || l.live[seg] > 0 {
+			break
+		}
+		if err := os.Remove(l.path(seg)); err != nil && !os.IsNotExist(err) {
+			return err
+		}
+		delete(l.live, seg)
+		removed++
+	}
+
+	if removed == 0 {
+		return nil
+	}
+	l.segs = append([]uint64(nil), l.segs[removed:]...)
+	return syncDir(l.dir)
+}
+
+// Append writes a job to the log and returns its ID. The job is on disk
+// when Append returns.
+func (l *Log) Append(payload []byte) (uint64, error) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	if l.closed {
+		return 0, ErrClosed
+	}
+
+	id := l.nextID
+	if err := l.write(encode(typeEnqueue, id, payload)); err != nil {
+		return 0, fmt.Errorf("wal: appending job %d: %w", id, err)
+	}
+	l.nextID++
+
+	l.pending[id] = pendingJob{payload: append([]byte(nil), payload...), seg: l.seg}
+	l.live[l.seg]++
+	return id, nil
+}
+
+// Ack records that the job with the given ID is done, so it is not
+// delivered again. Acknowledging an unknown or already acknowledged job
+// does nothing.
+func (l *Log) Ack(id uint64) error {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	if l.closed {
+		return ErrClosed
+	}
+
+	p, ok := l.pending[id]
+	if !ok {
+		return nil
+	}
+	if err := l.write(encode(typeAck, id, nil)); err != nil {
+		return fmt.Errorf("wal: acknowledging job %d: %w", id, err)
+	}
+
+	delete(l.pending, id)
+	l.live[p.seg]--
+	return nil
+}
+
+// Pending returns the jobs that have not been acknowledged, oldest first.
+func (l *Log) Pending() []Record {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	recs := make([]Record, 0, len(l.pending))
+	for id, p := range l.pending {
+		recs = append(recs, Record{ID: id, Payload: append([]byte(nil), p.payload...)})
+	}
+	sort.Slice(recs, func(i, j int) bool { return recs[i].ID < recs[j].ID })
+	return recs
+}
+
+// Segments returns the number of segment files on disk.
+func (l *Log) Segments() int {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	return len(l.segs)
+}
+
+// Compact copies the pending jobs into a new segment and deletes every
+// older segment. A crash part way through leaves the old segments in
+// place, and replaying them alongside the new one gives the same pending
+// jobs.
+func (l *Log) Compact() error {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	if l.closed {
+		return ErrClosed
+	}
+
+	old := append([]uint64(nil), l.segs...)
+	if err := l.rotate(); err != nil {
+		return fmt.Errorf("wal: compacting: %w", err)
+	}
+
+	ids := make([]uint64, 0, len(l.pending))
+	for id := range l.pending {
+		ids = append(ids, id)
+	}
+	sort.Slice(ids, func(i, j int) bool { return ids[i] < ids[j] })
+
+	// Write the jobs in one go rather than with a sync each.
+	var buf []byte
+	for _, id := range ids {
+		buf = append(buf, encode(typeEnqueue, id, l.pending[id].payload)...)
+	}
+	if _, err := l.f.Write(buf); err != nil {
+		return fmt.Errorf("wal: compacting: %w", err)
+	}
+	if err := l.f.Sync(); err != nil {
+		return fmt.Errorf("wal: compacting: %w", err)
+	}
+	l.size += int64(len(buf))
+
+	for _, id := range ids {
+		p := l.pending[id]
+		l.live[p.seg]--
+		p.seg = l.seg
+		l.pending[id] = p
+		l.live[l.seg]++
+	}
+
+	for _, seg := range old {
+		if seg == l.seg {
+			continue
+		}
+		if err := os.Remove(l.path(seg)); err != nil && !os.IsNotExist(err) {
+			return fmt.Errorf("wal: compacting: %w", err)
+		}
+		delete(l.live, seg)
+	}
+	l.segs = []uint64{l.seg}
+	return syncDir(l.dir)
+}
+
+// Close closes the log.
+func (l *Log) Close() error {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	if l.closed {
+		return ErrClosed
+	}
+	l.closed = true
+	return l.f.Close()
+}
+
+// syncDir syncs the directory at path, so files created, renamed or
+// removed in it are on disk.
+func syncDir(path string) error {
+	d, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer d.Close()
+
+	return d.Sync()
+}
@@ -0,0 +1,344 @@
+package wal
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"testing"
+
+	"go-concurrency-exercises/pkg/leakcheck"
+)
+
+func open(t *testing.T, dir string, opts ...Option) *Log {
+	t.Helper()
+
+	l, err := Open(dir, opts...)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return l
+}
+
+func appendJobs(t *testing.T, l *Log, payloads ...string) []uint64 {
+	t.Helper()
+
+	ids := make([]uint64, len(payloads))
+	for i, p := range payloads {
+		id, err := l.Append([]byte(p))
+		if err != nil {
+			t.Fatal(err)
+		}
+		ids[i] = id
+	}
+	return ids
+}
+
+func ack(t *testing.T, l *Log, ids ...uint64) {
+	t.Helper()
+
+	for _, id := range ids {
+		if err := l.Ack(id); err != nil {
+			t.Fatal(err)
+		}
+	}
+}
+
+func payloads(l *Log) []string {
+	var ps []string
+	for _, rec := range l.Pending() {
+		ps = append(ps, string(rec.Payload))
+	}
+	return ps
+}
+
+func equal(a, b []string) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if a[i] != b[i] {
+			return false
+		}
+	}
+	return true
+}
+
+func TestReplay(t *testing.T) {
+	leakcheck.Check(t)
+
+	dir := t.TempDir()
+
+	l := open(t, dir)
+	ids := appendJobs(t, l, "a", "b", "c", "d")
+	ack(t, l, ids[1], ids[3], ids[3])
+	if err := l.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	l = open(t, dir)
+	defer l.Close()
+
+	if got, want := payloads(l), []string{"a", "c"}; !equal(got, want) {
+		t.Errorf("got pending %q, want %q", got, want)
+	}
+
+	// IDs carry on from where the last run stopped.
+	id, err := l.Append([]byte("e"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if id <= ids[3] {
+		t.Errorf("got ID %d after reopening, want more than %d", id, ids[3])
+	}
+}
+
+func TestTornTail(t *testing.T) {
+	leakcheck.Check(t)
+
+	dir := t.TempDir()
+
+	l := open(t, dir)
+	appendJobs(t, l, "a", "b")
+	l.Close()
+
+	// Cut the last record short, as a crash part way through a write
+	// would.
+	path := filepath.Join(dir, fmt.Sprintf("%016x%s", 1, segmentExt))
+	info, err := os.Stat(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Truncate(path, info.Size()-1); err != nil {
+		t.Fatal(err)
+	}
+
+	l = open(t, dir)
+	if got, want := payloads(l), []string{"a"}; !equal(got, want) {
+		t.Errorf("got pending %q, want %q", got, want)
+	}
+
+	// The torn record is gone, so new records can be read back.
+	appendJobs(t, l, "c")
+	l.Close()
+
+	l = open(t, dir)
+	defer l.Close()
+
+	if got, want := payloads(l), []string{"a", "c"}; !equal(got, want) {
+		t.Errorf("got pending %q, want %q", got, want)
+	}
+}
+
+func TestCorruptSegment(t *testing.T) {
+	leakcheck.Check(t)
+
+	dir := t.TempDir()
+
+	l := open(t, dir, SegmentSize(64))
+	appendJobs(t, l, "aaaaaaaaaaaaaaaaaaaa", "bbbbbbbbbbbbbbbbbbbb", "cccccccccccccccccccc")
+	if l.Segments() < 2 {
+		t.Fatalf("got %d segments, want at least 2", l.Segments())
+	}
+	l.Close()
+
+	// Damage the first segment, which was complete before the crash.
+	path := filepath.Join(dir, fmt.Sprintf("%016x%s", 1, segmentExt))
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	data[len(data)-1] ^= 0xff
+	if err := os.WriteFile(path, data, 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := Open(dir); !errors.Is(err, ErrCorrupt) {
+		t.Errorf("got %v, want %v", err, ErrCorrupt)
+	}
+}
+
+func TestRotationDropsAckedSegments(t *testing.T) {
+	leakcheck.Check(t)
+
+	dir := t.TempDir()
+
+	l := open(t, dir, SegmentSize(100))
+	defer l.Close()
+
+	for i := 0; i < 50; i++ {
+		ids := appendJobs(t, l, fmt.Sprintf("job %d", i))
+		ack(t, l, ids...)
+	}
+
+	if n := l.Segments(); n > 2 {
+		t.Errorf("got %d segments with every job acknowledged, want at most 2", n)
+	}
+}
+
+func TestCompact(t *testing.T) {
+	leakcheck.Check(t)
+
+	dir := t.TempDir()
+
+	l := open(t, dir, SegmentSize(100))
+
+	// A job that is never acknowledged keeps every later segment on
+	// disk, until Compact copies it forward.
+	appendJobs(t, l, "slow")
+	for i := 0; i < 50; i++ {
+		ids := appendJobs(t, l, fmt.Sprintf("job %d", i))
+		ack(t, l, ids...)
+	}
+	if n := l.Segments(); n < 10 {
+		t.Fatalf("got %d segments before compacting, want at least 10", n)
+	}
+
+	if err := l.Compact(); err != nil {
+		t.Fatal(err)
+	}
+	if n := l.Segments(); n != 1 {
+		t.Errorf("got %d segments after compacting, want 1", n)
+	}
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(entries) != 1 {
+		t.Errorf("got %d files after compacting, want 1", len(entries))
+	}
+
+	appendJobs(t, l, "new")
+	l.Close()
+
+	l = open(t, dir)
+	defer l.Close()
+
+	if got, want := payloads(l), []string{"slow", "new"}; !equal(got, want) {
+		t.Errorf("got pending %q, want %q", got, want)
+	}
+}
+
+// crashDirEnv names the log directory when the test binary is run as the
+// writer in TestCrashRecovery.
+const crashDirEnv = "WAL_CRASH_DIR"
+
+// TestCrashWriter is the writer process killed by TestCrashRecovery. It
+// appends jobs, acknowledging every other one, and prints each append and
+// acknowledgement once it has returned, until it is killed.
+func TestCrashWriter(t *testing.T) {
+	dir := os.Getenv(crashDirEnv)
+	if dir == "" {
+		t.Skip("only run by TestCrashRecovery")
+	}
+
+	l, err := Open(dir, SegmentSize(512))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	w := bufio.NewWriter(os.Stdout)
+	for i := 1; ; i++ {
+		id, err := l.Append([]byte(fmt.Sprintf("job %d", i)))
+		if err != nil {
+			t.Fatal(err)
+		}
+		fmt.Fprintf(w, "append %d\n", id)
+		w.Flush()
+
+		if id%2 == 0 {
+			if err := l.Ack(id); err != nil {
+				t.Fatal(err)
+			}
+			fmt.Fprintf(w, "ack %d\n", id)
+			w.Flush()
+		}
+	}
+}
+
+func TestCrashRecovery(t *testing.T) {
+	if testing.Short() {
+		t.Skip("starts a writer process")
+	}
+
+	dir := t.TempDir()
+
+	cmd := exec.Command(os.Args[0], "-test.run=^TestCrashWriter$")
+	cmd.Env = append(os.Environ(), crashDirEnv+"="+dir)
+	out, err := cmd.StdoutPipe()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := cmd.Start(); err != nil {
+		t.Fatal(err)
+	}
+
+	// Kill the writer part way through the stream, while it is busy
+	// appending and acknowledging.
+	appended := make(map[uint64]bool)
+	acked := make(map[uint64]bool)
+	s := bufio.NewScanner(out)
+	for len(appended) < 300 && s.Scan() {
+		f := strings.Fields(s.Text())
+		if len(f) != 2 {
+			continue
+		}
+		id, err := strconv.ParseUint(f[1], 10, 64)
+		if err != nil {
+			continue
+		}
+		switch f[0] {
+		case "append":
+			appended[id] = true
+		case "ack":
+			acked[id] = true
+		}
+	}
+	if err := cmd.Process.Kill(); err != nil {
+		t.Fatal(err)
+	}
+	cmd.Wait()
+
+	if len(appended) < 300 {
+		t.Fatalf("writer stopped after %d jobs: %v", len(appended), s.Err())
+	}
+
+	l := open(t, dir, SegmentSize(512))
+	defer l.Close()
+
+	pending := make(map[uint64]string)
+	for _, rec := range l.Pending() {
+		pending[rec.ID] = string(rec.Payload)
+	}
+
+	// Every job the writer saw appended and not acknowledged is
+	// delivered again, and no job it saw acknowledged is.
+	for id := range appended {
+		if !acked[id] && id%2 == 1 {
+			if _, ok := pending[id]; !ok {
+				t.Errorf("job %d was appended but is not pending", id)
+			}
+		}
+	}
+	for id := range acked {
+		if _, ok := pending[id]; ok {
+			t.Errorf("job %d was acknowledged but is pending", id)
+		}
+	}
+
+	// The writer may have got further than it said before it was
+	// killed, but what was recovered must be intact.
+	for id, p := range pending {
+		if want := fmt.Sprintf("job %d", id); p != want {
+			t.Errorf("job %d: got payload %q, want %q", id, p, want)
+		}
+	}
+
+	if _, err := l.Append([]byte("after the crash")); err != nil {
+		t.Errorf("appending after recovery: %v", err)
+	}
+}
@@ -0,0 +1,197 @@
+package workerpool
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"go-concurrency-exercises/pkg/clock"
+	"go-concurrency-exercises/pkg/leakcheck"
+)
+
+// waitForCtx returns a job that closes started, then waits for its
+// context and returns its error.
+func waitForCtx(started chan<- struct{}) Job[string] {
+	return func(ctx context.Context) (string, error) {
+		close(started)
+		<-ctx.Done()
+		return "", ctx.Err()
+	}
+}
+
+// mustNotRun returns a job that fails the test if it runs.
+func mustNotRun(t *testing.T) Job[string] {
+	return func(context.Context) (string, error) {
+		t.Error("job ran")
+		return "", nil
+	}
+}
+
+func expectErr(t *testing.T, f *Future[string], want error) {
+	t.Helper()
+
+	if _, err := f.Result(); !errors.Is(err, want) {
+		t.Errorf("got %v, want %v", err, want)
+	}
+}
+
+func TestTimeoutWhileRunning(t *testing.T) {
+	leakcheck.Check(t)
+
+	c := clock.NewFake(epoch)
+	p := NewPool[string](context.Background(), 1, Clock(c))
+	defer shutdown(t, p)
+
+	started := make(chan struct{})
+	f := submit(t, p, waitForCtx(started), Timeout(time.Second))
+	<-started
+
+	c.Advance(time.Second)
+	expectErr(t, f, context.DeadlineExceeded)
+}
+
+func TestDeadlineWhileQueued(t *testing.T) {
+	leakcheck.Check(t)
+
+	c := clock.NewFake(epoch)
+	p := NewPool[string](context.Background(), 1, Clock(c))
+	defer shutdown(t, p)
+
+	release := make(chan struct{})
+	holdWorker(t, p, release)
+	defer close(release)
+
+	f := submit(t, p, mustNotRun(t), Deadline(epoch.Add(time.Minute)))
+
+	// The job is dropped at its deadline, while the worker is still busy.
+	c.Advance(time.Minute)
+	expectErr(t, f, context.DeadlineExceeded)
+	if n := p.Queued(); n != 0 {
+		t.Errorf("got %d queued jobs, want 0", n)
+	}
+}
+
+func TestTimeoutIncludesQueueing(t *testing.T) {
+	leakcheck.Check(t)
+
+	c := clock.NewFake(epoch)
+	p := NewPool[string](context.Background(), 1, Clock(c))
+	defer shutdown(t, p)
+
+	release := make(chan struct{})
+	holdWorker(t, p, release)
+
+	started := make(chan struct{})
+	f := submit(t, p, waitForCtx(started), Timeout(time.Minute))
+
+	// The job starts with half its time left, and times out when the
+	// rest has gone.
+	c.Advance(30 * time.Second)
+	close(release)
+	<-started
+
+	c.Advance(29 * time.Second)
+	select {
+	case <-f.Done():
+		t.Fatal("job timed out early")
+	case <-time.After(10 * time.Millisecond):
+	}
+
+	c.Advance(time.Second)
+	expectErr(t, f, context.DeadlineExceeded)
+}
+
+func TestCancelQueued(t *testing.T) {
+	leakcheck.Check(t)
+
+	p := NewPool[string](context.Background(), 1)
+	defer shutdown(t, p)
+
+	release := make(chan struct{})
+	holdWorker(t, p, release)
+
+	order := make(chan string, 10)
+	cancelled := submit(t, p, mustNotRun(t), Priority(1))
+	submit(t, p, record(order, "next"))
+
+	cancelled.Cancel()
+	expectErr(t, cancelled, context.Canceled)
+	if n := p.Queued(); n != 1 {
+		t.Errorf("got %d queued jobs, want 1", n)
+	}
+
+	close(release)
+	expectOrder(t, order, "next")
+}
+
+func TestCancelDelayed(t *testing.T) {
+	leakcheck.Check(t)
+
+	c := clock.NewFake(epoch)
+	p := NewPool[string](context.Background(), 1, Clock(c))
+	defer shutdown(t, p)
+
+	f := submit(t, p, mustNotRun(t), NotBefore(epoch.Add(time.Hour)))
+	f.Cancel()
+	expectErr(t, f, context.Canceled)
+
+	// Shutdown doesn't wait for the cancelled job to become due.
+}
+
+func TestCancelRunning(t *testing.T) {
+	leakcheck.Check(t)
+
+	c := clock.NewFake(epoch)
+	p := NewPool[string](context.Background(), 1, Clock(c))
+	defer shutdown(t, p)
+
+	started := make(chan struct{})
+	f := submit(t, p, waitForCtx(started), Timeout(time.Minute))
+	<-started
+
+	// Cancelling is reported as such, not as the deadline.
+	f.Cancel()
+	expectErr(t, f, context.Canceled)
+}
+
+func TestCancelFinished(t *testing.T) {
+	leakcheck.Check(t)
+
+	p := NewPool[string](context.Background(), 1)
+	defer shutdown(t, p)
+
+	order := make(chan string, 1)
+	f := submit(t, p, record(order, "done"))
+	<-f.Done()
+
+	f.Cancel()
+	if v, err := f.Result(); v != "done" || err != nil {
+		t.Errorf("got %q, %v after cancelling a finished job, want done, nil", v, err)
+	}
+}
+
+func TestCancelDoesNotAffectOtherJobs(t *testing.T) {
+	leakcheck.Check(t)
+
+	p := NewPool[string](context.Background(), 2)
+	defer shutdown(t, p)
+
+	startedA := make(chan struct{})
+	startedB := make(chan struct{})
+	a := submit(t, p, waitForCtx(startedA))
+	b := submit(t, p, waitForCtx(startedB))
+	<-startedA
+	<-startedB
+
+	a.Cancel()
+	expectErr(t, a, context.Canceled)
+
+	select {
+	case <-b.Done():
+		t.Fatal("cancelling one job cancelled another")
+	case <-time.After(10 * time.Millisecond):
+	}
+	b.Cancel()
+	expectErr(t, b, context.Canceled)
+}
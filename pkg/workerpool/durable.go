@@ -0,0 +1,82 @@
+package workerpool
+
+import (
+	"context"
+	"fmt"
+
+	"go-concurrency-exercises/pkg/wal"
+)
+
+// Handler runs a durable job, given the payload it was enqueued with.
+type Handler func(ctx context.Context, payload []byte) error
+
+// Durable is a pool whose jobs are written to a write-ahead log before they
+// are queued, so they survive the process exiting. A job is acknowledged
+// in the log once its handler returns nil. Jobs that fail, panic or were
+// still queued or running when the process exited stay in the log, and
+// are delivered again by the next NewDurable on the same log. Handlers
+// must therefore cope with running the same payload more than once.
+type Durable struct {
+	pool   *Pool[struct{}]
+	log    *wal.Log
+	handle Handler
+}
+
+// NewDurable starts a pool of workers running handle on the jobs in log.
+// The jobs left pending in log from an earlier run are queued first. The
+// options are those of NewPool. The caller closes log after Shutdown.
+func NewDurable(ctx context.Context, workers int, log *wal.Log, handle Handler, opts ...Option) (*Durable, error) {
+	d := &Durable{
+		pool:   NewPool[struct{}](ctx, workers, opts...),
+		log:    log,
+		handle: handle,
+	}
+
+	for _, rec := range log.Pending() {
+		if _, err := d.pool.Submit(ctx, d.job(rec)); err != nil {
+			d.pool.cancel()
+			d.pool.Shutdown(ctx)
+			return nil, fmt.Errorf("workerpool: redelivering job %d: %w", rec.ID, err)
+		}
+	}
+
+	return d, nil
+}
+
+// job returns the pool job that runs rec and acknowledges it.
+func (d *Durable) job(rec wal.Record) Job[struct{}] {
+	return func(ctx context.Context) (struct{}, error) {
+		if err := d.handle(ctx, rec.Payload); err != nil {
+			return struct{}{}, err
+		}
+		return struct{}{}, d.log.Ack(rec.ID)
+	}
+}
+
+// Enqueue writes payload to the log and queues it, waiting for room like
+// Pool.Submit. Once Enqueue returns without an error the job will run, in
+// this process or, if it exits first, the next one. If the job can't be
+// queued it is acknowledged straight away, so it is not delivered on the
+// next run either. The job options apply to this run of the job only; a
+// job delivered again by a later run gets none.
+func (d *Durable) Enqueue(ctx context.Context, payload []byte, opts ...JobOption) (*Future[struct{}], error) {
+	payload = append([]byte(nil), payload...)
+	id, err := d.log.Append(payload)
+	if err != nil {
+		return nil, err
+	}
+
+	f, err := d.pool.Submit(ctx, d.job(wal.Record{ID: id, Payload: payload}), opts...)
+	if err != nil {
+		if ackErr := d.log.Ack(id); ackErr != nil {
+			return nil, fmt.Errorf("%w (and dropping it from the log: %v)", err, ackErr)
+		}
+		return nil, err
+	}
+	return f, nil
+}
+
+// Shutdown is Pool.Shutdown. Jobs abandoned by it stay in the log.
+func (d *Durable) Shutdown(ctx context.Context) error {
+	return d.pool.Shutdown(ctx)
+}
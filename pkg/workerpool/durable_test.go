@@ -0,0 +1,196 @@
+package workerpool
+
+import (
+	"context"
+	"errors"
+	"sort"
+	"sync"
+	"testing"
+
+	"go-concurrency-exercises/pkg/leakcheck"
+	"go-concurrency-exercises/pkg/wal"
+)
+
+func openLog(t *testing.T, dir string) *wal.Log {
+	t.Helper()
+
+	l, err := wal.Open(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return l
+}
+
+// handled records the payloads a handler has been given.
+type handled struct {
+	mu       sync.Mutex
+	payloads []string
+}
+
+func (h *handled) add(payload []byte) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	h.payloads = append(h.payloads, string(payload))
+}
+
+func (h *handled) sorted() []string {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	ps := append([]string(nil), h.payloads...)
+	sort.Strings(ps)
+	return ps
+}
+
+func sameStrings(a, b []string) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if a[i] != b[i] {
+			return false
+		}
+	}
+	return true
+}
+
+func TestDurableRedeliversFailedJobs(t *testing.T) {
+	leakcheck.Check(t)
+
+	ctx := context.Background()
+	dir := t.TempDir()
+
+	// The first run fails b, so it stays in the log.
+	log := openLog(t, dir)
+	d, err := NewDurable(ctx, 2, log, func(_ context.Context, payload []byte) error {
+		if string(payload) == "b" {
+			return errors.New("failed")
+		}
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, p := range []string{"a", "b", "c"} {
+		if _, err := d.Enqueue(ctx, []byte(p)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := d.Shutdown(ctx); err != nil {
+		t.Fatal(err)
+	}
+	log.Close()
+
+	// The second run is given b again.
+	var h handled
+	log = openLog(t, dir)
+	defer log.Close()
+	d, err = NewDurable(ctx, 2, log, func(_ context.Context, payload []byte) error {
+		h.add(payload)
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := d.Shutdown(ctx); err != nil {
+		t.Fatal(err)
+	}
+
+	if got, want := h.sorted(), []string{"b"}; !sameStrings(got, want) {
+		t.Errorf("redelivered %q, want %q", got, want)
+	}
+	if n := len(log.Pending()); n != 0 {
+		t.Errorf("got %d pending jobs after they succeeded, want 0", n)
+	}
+}
+
+func TestDurableRedeliversAbandonedJobs(t *testing.T) {
+	leakcheck.Check(t)
+
+	dir := t.TempDir()
+
+	// The first run stops while one job is running and two are queued,
+	// as if the process had exited.
+	ctx, cancel := context.WithCancel(context.Background())
+	log := openLog(t, dir)
+	started := make(chan struct{})
+	d, err := NewDurable(ctx, 1, log, func(ctx context.Context, _ []byte) error {
+		close(started)
+		<-ctx.Done()
+		return ctx.Err()
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, p := range []string{"a", "b", "c"} {
+		if _, err := d.Enqueue(ctx, []byte(p)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	<-started
+	cancel()
+	d.Shutdown(context.Background())
+	log.Close()
+
+	var h handled
+	ctx = context.Background()
+	log = openLog(t, dir)
+	defer log.Close()
+	d, err = NewDurable(ctx, 2, log, func(_ context.Context, payload []byte) error {
+		h.add(payload)
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := d.Enqueue(ctx, []byte("d")); err != nil {
+		t.Fatal(err)
+	}
+	if err := d.Shutdown(ctx); err != nil {
+		t.Fatal(err)
+	}
+
+	if got, want := h.sorted(), []string{"a", "b", "c", "d"}; !sameStrings(got, want) {
+		t.Errorf("handled %q, want %q", got, want)
+	}
+}
+
+func TestDurableDropsRejectedJobs(t *testing.T) {
+	leakcheck.Check(t)
+
+	ctx := context.Background()
+	log := openLog(t, t.TempDir())
+	defer log.Close()
+
+	started := make(chan struct{}, 1)
+	release := make(chan struct{})
+	d, err := NewDurable(ctx, 1, log, func(context.Context, []byte) error {
+		started <- struct{}{}
+		<-release
+		return nil
+	}, QueueSize(1), RejectWhenFull())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer d.Shutdown(ctx)
+	defer close(release)
+
+	if _, err := d.Enqueue(ctx, []byte("a")); err != nil {
+		t.Fatal(err)
+	}
+	// Once the worker has taken a, b fills the queue.
+	<-started
+	if _, err := d.Enqueue(ctx, []byte("b")); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := d.Enqueue(ctx, []byte("c")); !errors.Is(err, ErrQueueFull) {
+		t.Fatalf("got %v, want %v", err, ErrQueueFull)
+	}
+
+	for _, rec := range log.Pending() {
+		if string(rec.Payload) == "c" {
+			t.Error("rejected job is still in the log")
+		}
+	}
+}
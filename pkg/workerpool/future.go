@@ -0,0 +1,62 @@
+package workerpool
+
+import (
+	"context"
+)
+
+// Future is the result of a submitted job, which becomes available once
+// the job has run.
+type Future[T any] struct {
+	done  chan struct{}
+	value T
+	err   error
+
+	// cancel aborts the job. It is nil for futures not made by a pool.
+	cancel func()
+}
+
+func newFuture[T any]() *Future[T] {
+	return &Future[T]{done: make(chan struct{})}
+}
+
+// complete sets the job's result and wakes everyone waiting for it.
+func (f *Future[T]) complete(v T, err error) {
+	f.value = v
+	f.err = err
+	close(f.done)
+}
+
+// Done returns a channel that is closed once the result is available.
+func (f *Future[T]) Done() <-chan struct{} {
+	return f.done
+}
+
+// Wait waits for the job to finish and returns its result and error. If
+// ctx is done first it returns ctx.Err(); the job carries on regardless.
+func (f *Future[T]) Wait(ctx context.Context) (T, error) {
+	select {
+	case <-f.done:
+		return f.value, f.err
+	case <-ctx.Done():
+		var zero T
+		return zero, ctx.Err()
+	}
+}
+
+// Result waits for the job to finish and returns its result and error.
+func (f *Future[T]) Result() (T, error) {
+	<-f.done
+	return f.value, f.err
+}
+
+// Cancel aborts the job. A job still waiting in the queue is removed from
+// it and its error is context.Canceled. A running job has its context
+// cancelled, and its result is whatever it returns; jobs that return
+// ctx.Err() return context.Canceled. Once the job has finished Cancel does
+// nothing. A future shared by jobs collapsed with DedupKey cancels the one
+// job they share.
+func (f *Future[T]) Cancel() {
+	if f.cancel != nil {
+		f.cancel()
+	}
+}
@@ -0,0 +1,300 @@
+package workerpool
+
+import (
+	"context"
+	"runtime/debug"
+	"sync"
+)
+
+// DefaultMaxBurst is the number of jobs a lane runs for one key in a row
+// while other keys on the lane are waiting.
+const DefaultMaxBurst = 4
+
+// DefaultKeyQueueSize is the number of jobs that can wait for each key
+// before Submit blocks.
+const DefaultKeyQueueSize = 64
+
+// KeyedOption configures a Keyed pool.
+type KeyedOption func(*keyedConfig)
+
+type keyedConfig struct {
+	maxBurst     int
+	keyQueueSize int
+}
+
+// MaxBurst sets the number of jobs a lane runs for one key in a row while
+// other keys on the lane are waiting. A key with a long queue holds up the
+// other keys on its lane for at most n jobs before they get a turn.
+func MaxBurst(n int) KeyedOption {
+	return func(c *keyedConfig) {
+		if n > 0 {
+			c.maxBurst = n
+		}
+	}
+}
+
+// KeyQueueSize sets the number of jobs that can wait for each key. Submit
+// waits for room for its key only, so a hot key filling its queue doesn't
+// stop other keys being submitted.
+func KeyQueueSize(n int) KeyedOption {
+	return func(c *keyedConfig) {
+		if n > 0 {
+			c.keyQueueSize = n
+		}
+	}
+}
+
+// Keyed is a pool that runs jobs with the same key one at a time, in the
+// order they were submitted, and jobs with different keys in parallel.
+//
+// Keys are spread over a fixed number of lanes by consistent hashing, and
+// each lane is a single goroutine, which is what keeps a key's jobs in
+// order. A lane takes turns between the keys waiting on it, running up to
+// MaxBurst jobs for a key before moving to the next, so a hot key only
+// delays the other keys sharing its lane by a bounded number of jobs.
+type Keyed[T any] struct {
+	ctx    context.Context
+	cancel context.CancelFunc
+	ring   *ring
+	lanes  []*lane[T]
+	wg     sync.WaitGroup
+}
+
+// lane runs the jobs of the keys hashed to it.
+type lane[T any] struct {
+	ctx          context.Context
+	maxBurst     int
+	keyQueueSize int
+
+	mu     sync.Mutex
+	closed bool
+
+	// queues holds the jobs waiting for each key. active holds the keys
+	// with jobs waiting, in the order they take turns, and served is the
+	// number of jobs run in a row for the key at the front.
+	queues map[string][]*keyedTask[T]
+	active []string
+	served int
+
+	// work and room wake the lane and waiting submitters, as in Pool.
+	work chan struct{}
+	room chan struct{}
+}
+
+type keyedTask[T any] struct {
+	job    Job[T]
+	future *Future[T]
+}
+
+// NewKeyed starts a pool with the given number of lanes. Cancelling ctx
+// stops the pool: running jobs see their context cancelled and queued
+// jobs are abandoned.
+func NewKeyed[T any](ctx context.Context, lanes int, opts ...KeyedOption) *Keyed[T] {
+	c := keyedConfig{maxBurst: DefaultMaxBurst, keyQueueSize: DefaultKeyQueueSize}
+	for _, opt := range opts {
+		opt(&c)
+	}
+	if lanes < 1 {
+		lanes = 1
+	}
+
+	ctx, cancel := context.WithCancel(ctx)
+	k := &Keyed[T]{
+		ctx:    ctx,
+		cancel: cancel,
+		ring:   newRing(lanes),
+	}
+
+	for i := 0; i < lanes; i++ {
+		l := &lane[T]{
+			ctx:          ctx,
+			maxBurst:     c.maxBurst,
+			keyQueueSize: c.keyQueueSize,
+			queues:       make(map[string][]*keyedTask[T]),
+			work:         make(chan struct{}),
+			room:         make(chan struct{}),
+		}
+		k.lanes = append(k.lanes, l)
+	}
+
+	k.wg.Add(lanes)
+	for _, l := range k.lanes {
+		go func(l *lane[T]) {
+			defer k.wg.Done()
+			l.run()
+		}(l)
+	}
+
+	// Wake every lane and submitter when the pool's context is done.
+	go func() {
+		<-ctx.Done()
+
+		for _, l := range k.lanes {
+			l.mu.Lock()
+			l.wake()
+			l.mu.Unlock()
+		}
+	}()
+
+	return k
+}
+
+// wake wakes the lane and the submitters waiting on it. l.mu must be held.
+func (l *lane[T]) wake() {
+	close(l.work)
+	l.work = make(chan struct{})
+	close(l.room)
+	l.room = make(chan struct{})
+}
+
+// run runs the lane's jobs until it is closed and empty.
+func (l *lane[T]) run() {
+	for {
+		t, ok := l.next()
+		if !ok {
+			return
+		}
+
+		if l.ctx.Err() != nil {
+			var zero T
+			t.future.complete(zero, ErrAbandoned)
+			continue
+		}
+
+		l.runJob(t)
+	}
+}
+
+// next waits for a job and takes it from the key whose turn it is.
+func (l *lane[T]) next() (*keyedTask[T], bool) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	for len(l.active) == 0 {
+		if l.closed || l.ctx.Err() != nil {
+			return nil, false
+		}
+
+		work := l.work
+		l.mu.Unlock()
+		<-work
+		l.mu.Lock()
+	}
+
+	key := l.active[0]
+	q := l.queues[key]
+	t := q[0]
+	q[0] = nil
+	q = q[1:]
+	l.served++
+
+	switch {
+	case len(q) == 0:
+		// The key has nothing left, so it drops out of the turns.
+		delete(l.queues, key)
+		l.active = l.active[1:]
+		l.served = 0
+	case l.served >= l.maxBurst:
+		// The key has had its turn; it goes to the back.
+		l.queues[key] = q
+		l.active = append(l.active[1:], key)
+		l.served = 0
+	default:
+		l.queues[key] = q
+	}
+
+	close(l.room)
+	l.room = make(chan struct{})
+	return t, true
+}
+
+// runJob runs t's job and completes its future, recovering a panic as a
+// *PanicError. The lane's goroutine carries on afterwards, because
+// replacing it would let the key's next job overtake this one.
+func (l *lane[T]) runJob(t *keyedTask[T]) {
+	defer func() {
+		if r := recover(); r != nil {
+			var zero T
+			t.future.complete(zero, &PanicError{Value: r, Stack: debug.Stack()})
+		}
+	}()
+
+	v, err := t.job(l.ctx)
+	t.future.complete(v, err)
+}
+
+// Submit queues job behind the other jobs submitted with key, and returns
+// a Future for its result. If key already has KeyQueueSize jobs waiting it
+// waits for room until ctx is done. Once Shutdown has been called, or the
+// pool's context is done, it returns ErrClosed.
+func (k *Keyed[T]) Submit(ctx context.Context, key string, job Job[T]) (*Future[T], error) {
+	l := k.lanes[k.ring.lane(key)]
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	for {
+		if l.closed || l.ctx.Err() != nil {
+			return nil, ErrClosed
+		}
+		if len(l.queues[key]) < l.keyQueueSize {
+			break
+		}
+
+		room := l.room
+		l.mu.Unlock()
+		select {
+		case <-room:
+		case <-ctx.Done():
+			l.mu.Lock()
+			return nil, ctx.Err()
+		}
+		l.mu.Lock()
+	}
+
+	q, ok := l.queues[key]
+	if !ok {
+		l.active = append(l.active, key)
+	}
+	t := &keyedTask[T]{job: job, future: newFuture[T]()}
+	l.queues[key] = append(q, t)
+
+	close(l.work)
+	l.work = make(chan struct{})
+	return t.future, nil
+}
+
+// Lane returns the lane key's jobs run on.
+func (k *Keyed[T]) Lane(key string) int {
+	return k.ring.lane(key)
+}
+
+// Shutdown stops the pool accepting jobs and waits for the lanes to run
+// the jobs already queued. If ctx is done first, the jobs still queued are
+// abandoned, running jobs have their context cancelled, and Shutdown
+// returns ctx.Err() without waiting for them.
+func (k *Keyed[T]) Shutdown(ctx context.Context) error {
+	for _, l := range k.lanes {
+		l.mu.Lock()
+		if !l.closed {
+			l.closed = true
+			l.wake()
+		}
+		l.mu.Unlock()
+	}
+
+	finished := make(chan struct{})
+	go func() {
+		k.wg.Wait()
+		close(finished)
+	}()
+
+	select {
+	case <-finished:
+		k.cancel()
+		return nil
+	case <-ctx.Done():
+		k.cancel()
+		return ctx.Err()
+	}
+}
@@ -0,0 +1,249 @@
+package workerpool
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"testing"
+	"time"
+
+	"go-concurrency-exercises/pkg/leakcheck"
+)
+
+func shutdownKeyed[T any](t *testing.T, k *Keyed[T]) {
+	t.Helper()
+
+	if err := k.Shutdown(context.Background()); err != nil {
+		t.Fatalf("Shutdown: %v", err)
+	}
+}
+
+func submitKeyed(t *testing.T, k *Keyed[string], key string, job Job[string]) *Future[string] {
+	t.Helper()
+
+	f, err := k.Submit(context.Background(), key, job)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return f
+}
+
+func TestKeyedOrder(t *testing.T) {
+	leakcheck.Check(t)
+
+	const keys, jobsPerKey = 50, 200
+
+	ctx := context.Background()
+	k := NewKeyed[struct{}](ctx, 8, KeyQueueSize(16))
+
+	var mu sync.Mutex
+	seen := make(map[string][]int)
+
+	// Each key has its own submitter, so its jobs are submitted in
+	// order, and all keys are submitted at once.
+	var wg sync.WaitGroup
+	for i := 0; i < keys; i++ {
+		key := fmt.Sprintf("user-%d", i)
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for j := 0; j < jobsPerKey; j++ {
+				j := j
+				_, err := k.Submit(ctx, key, func(context.Context) (struct{}, error) {
+					mu.Lock()
+					seen[key] = append(seen[key], j)
+					mu.Unlock()
+					return struct{}{}, nil
+				})
+				if err != nil {
+					t.Error(err)
+					return
+				}
+			}
+		}()
+	}
+	wg.Wait()
+
+	if err := k.Shutdown(ctx); err != nil {
+		t.Fatal(err)
+	}
+
+	for key, js := range seen {
+		if len(js) != jobsPerKey {
+			t.Errorf("%s: ran %d jobs, want %d", key, len(js), jobsPerKey)
+		}
+		for i, j := range js {
+			if i != j {
+				t.Errorf("%s: job %d ran in position %d", key, j, i)
+				break
+			}
+		}
+	}
+	if len(seen) != keys {
+		t.Errorf("ran jobs for %d keys, want %d", len(seen), keys)
+	}
+}
+
+func TestKeyedRunsKeysInParallel(t *testing.T) {
+	leakcheck.Check(t)
+
+	k := NewKeyed[string](context.Background(), 4)
+	defer shutdownKeyed(t, k)
+
+	// Find two keys on different lanes, and block both at once.
+	a, b := "a", ""
+	for i := 0; b == ""; i++ {
+		if key := fmt.Sprint(i); k.Lane(key) != k.Lane(a) {
+			b = key
+		}
+	}
+
+	var started sync.WaitGroup
+	started.Add(2)
+	release := make(chan struct{})
+	for _, key := range []string{a, b} {
+		submitKeyed(t, k, key, func(context.Context) (string, error) {
+			started.Done()
+			<-release
+			return "", nil
+		})
+	}
+
+	// Both start without either finishing.
+	started.Wait()
+	close(release)
+}
+
+func TestKeyedHotKeyBurst(t *testing.T) {
+	leakcheck.Check(t)
+
+	k := NewKeyed[string](context.Background(), 1, MaxBurst(3))
+	defer shutdownKeyed(t, k)
+
+	// Hold the lane while the hot key queues up ten jobs, then submit a
+	// job for a quiet key.
+	release := make(chan struct{})
+	started := make(chan struct{})
+	submitKeyed(t, k, "hot", func(context.Context) (string, error) {
+		close(started)
+		<-release
+		return "", nil
+	})
+	<-started
+
+	order := make(chan string, 20)
+	for i := 0; i < 10; i++ {
+		submitKeyed(t, k, "hot", record(order, fmt.Sprintf("hot-%d", i)))
+	}
+	submitKeyed(t, k, "quiet", record(order, "quiet"))
+	close(release)
+
+	// The quiet key waits for one burst of three hot jobs, not all ten.
+	expectOrder(t, order, "hot-0", "hot-1", "hot-2", "quiet", "hot-3", "hot-4")
+}
+
+func TestKeyedKeyQueueSize(t *testing.T) {
+	leakcheck.Check(t)
+
+	k := NewKeyed[string](context.Background(), 1, KeyQueueSize(2))
+	defer shutdownKeyed(t, k)
+
+	release := make(chan struct{})
+	started := make(chan struct{})
+	submitKeyed(t, k, "hot", func(context.Context) (string, error) {
+		close(started)
+		<-release
+		return "", nil
+	})
+	<-started
+	submitKeyed(t, k, "hot", record(make(chan string, 1), "hot-1"))
+	submitKeyed(t, k, "hot", record(make(chan string, 1), "hot-2"))
+
+	// The hot key's queue is full, but the quiet key's isn't.
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+	if _, err := k.Submit(ctx, "hot", record(make(chan string, 1), "hot-3")); !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("got %v submitting to a full key, want %v", err, context.DeadlineExceeded)
+	}
+	submitKeyed(t, k, "quiet", record(make(chan string, 1), "quiet"))
+
+	close(release)
+}
+
+func TestKeyedPanic(t *testing.T) {
+	leakcheck.Check(t)
+
+	k := NewKeyed[string](context.Background(), 1)
+	defer shutdownKeyed(t, k)
+
+	f := submitKeyed(t, k, "a", func(context.Context) (string, error) {
+		panic("boom")
+	})
+	order := make(chan string, 1)
+	submitKeyed(t, k, "a", record(order, "next"))
+
+	var perr *PanicError
+	if _, err := f.Result(); !errors.As(err, &perr) {
+		t.Errorf("got %v, want a *PanicError", err)
+	}
+	expectOrder(t, order, "next")
+}
+
+func TestKeyedContextCancelled(t *testing.T) {
+	leakcheck.Check(t)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	k := NewKeyed[string](ctx, 1)
+
+	started := make(chan struct{})
+	running := submitKeyed(t, k, "a", waitForCtx(started))
+	queued := submitKeyed(t, k, "a", mustNotRun(t))
+	<-started
+
+	cancel()
+	expectErr(t, running, context.Canceled)
+	expectErr(t, queued, ErrAbandoned)
+
+	if _, err := k.Submit(context.Background(), "a", mustNotRun(t)); !errors.Is(err, ErrClosed) {
+		t.Errorf("got %v, want %v", err, ErrClosed)
+	}
+	k.Shutdown(context.Background())
+}
+
+func TestRingSpreadsKeys(t *testing.T) {
+	const lanes, keys = 8, 80000
+
+	r := newRing(lanes)
+	counts := make([]int, lanes)
+	for i := 0; i < keys; i++ {
+		counts[r.lane(fmt.Sprintf("user-%d", i))]++
+	}
+
+	for lane, n := range counts {
+		if n < keys/lanes/2 || n > keys/lanes*2 {
+			t.Errorf("lane %d got %d keys, want about %d", lane, n, keys/lanes)
+		}
+	}
+}
+
+func TestRingMovesFewKeys(t *testing.T) {
+	const keys = 10000
+
+	before, after := newRing(8), newRing(9)
+	moved := 0
+	for i := 0; i < keys; i++ {
+		key := fmt.Sprintf("user-%d", i)
+		if b, a := before.lane(key), after.lane(key); b != a {
+			if a != 8 {
+				t.Fatalf("%s moved from lane %d to old lane %d", key, b, a)
+			}
+			moved++
+		}
+	}
+
+	// About one key in nine moves to the new lane.
+	if moved < keys/18 || moved > keys*2/9 {
+		t.Errorf("%d of %d keys moved, want about %d", moved, keys, keys/9)
+	}
+}
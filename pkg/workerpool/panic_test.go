@@ -0,0 +1,118 @@
+package workerpool
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"sync/atomic"
+	"testing"
+
+	"go-concurrency-exercises/pkg/leakcheck"
+)
+
+func explode(context.Context) (int, error) {
+	panic("boom")
+}
+
+func TestPanicReturnedToSubmitter(t *testing.T) {
+	leakcheck.Check(t)
+
+	ctx := context.Background()
+	p := NewPool[int](ctx, 1)
+	defer shutdown(t, p)
+
+	f, err := p.Submit(ctx, explode)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	_, err = f.Result()
+	var perr *PanicError
+	if !errors.As(err, &perr) {
+		t.Fatalf("got %v, want a *PanicError", err)
+	}
+	if perr.Value != "boom" {
+		t.Errorf("got panic value %v, want boom", perr.Value)
+	}
+	if !strings.Contains(string(perr.Stack), "explode") {
+		t.Errorf("stack does not mention the panicking function:\n%s", perr.Stack)
+	}
+
+	// The pool carries on running jobs.
+	f, err = p.Submit(ctx, func(context.Context) (int, error) { return 42, nil })
+	if err != nil {
+		t.Fatal(err)
+	}
+	if v, err := f.Result(); v != 42 || err != nil {
+		t.Errorf("got %d, %v, want 42, nil", v, err)
+	}
+}
+
+func TestPanicUnwrapsErrors(t *testing.T) {
+	leakcheck.Check(t)
+
+	ctx := context.Background()
+	p := NewPool[int](ctx, 1)
+	defer shutdown(t, p)
+
+	errBoom := errors.New("boom")
+	f, _ := p.Submit(ctx, func(context.Context) (int, error) {
+		panic(errBoom)
+	})
+
+	if _, err := f.Result(); !errors.Is(err, errBoom) {
+		t.Errorf("got %v, want it to wrap %v", err, errBoom)
+	}
+}
+
+func TestPanicKeepsCapacity(t *testing.T) {
+	leakcheck.Check(t)
+
+	const workers = 3
+
+	ctx := context.Background()
+	var hooked int64
+	p := NewPool[int](ctx, workers, OnPanic(func(*PanicError) {
+		atomic.AddInt64(&hooked, 1)
+	}))
+	defer shutdown(t, p)
+
+	for i := 0; i < 20; i++ {
+		f, err := p.Submit(ctx, explode)
+		if err != nil {
+			t.Fatal(err)
+		}
+		f.Result()
+	}
+
+	// Every worker must still be there: these jobs only finish
+	// once all of them are running at the same time.
+	var started int64
+	all := make(chan struct{})
+	var futures []*Future[int]
+	for i := 0; i < workers; i++ {
+		f, err := p.Submit(ctx, func(context.Context) (int, error) {
+			if atomic.AddInt64(&started, 1) == workers {
+				close(all)
+			}
+			<-all
+			return 0, nil
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+		futures = append(futures, f)
+	}
+	for _, f := range futures {
+		if _, err := f.Result(); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	if p.Panics() != 20 {
+		t.Errorf("Panics() = %d, want 20", p.Panics())
+	}
+	if hooked != 20 {
+		t.Errorf("OnPanic called %d times, want 20", hooked)
+	}
+}
@@ -0,0 +1,175 @@
+package workerpool
+
+import (
+	"container/heap"
+	"time"
+)
+
+// readyQueue holds the jobs that are due to run, highest priority first,
+// and in the order they were submitted within a priority.
+type readyQueue[T any] []*task[T]
+
+func (q readyQueue[T]) Len() int { return len(q) }
+
+func (q readyQueue[T]) Less(i, j int) bool {
+	if q[i].priority != q[j].priority {
+		return q[i].priority > q[j].priority
+	}
+	return q[i].seq < q[j].seq
+}
+
+func (q readyQueue[T]) Swap(i, j int) {
+	q[i], q[j] = q[j], q[i]
+	q[i].index = i
+	q[j].index = j
+}
+
+func (q *readyQueue[T]) Push(x interface{}) {
+	t := x.(*task[T])
+	t.index = len(*q)
+	t.ready = true
+	*q = append(*q, t)
+}
+
+func (q *readyQueue[T]) Pop() interface{} {
+	old := *q
+	t := old[len(old)-1]
+	old[len(old)-1] = nil
+	*q = old[:len(old)-1]
+	t.index = -1
+	return t
+}
+
+// delayedQueue holds the jobs that must not run before a time, earliest
+// first.
+type delayedQueue[T any] []*task[T]
+
+func (q delayedQueue[T]) Len() int { return len(q) }
+
+func (q delayedQueue[T]) Less(i, j int) bool {
+	if !q[i].notBefore.Equal(q[j].notBefore) {
+		return q[i].notBefore.Before(q[j].notBefore)
+	}
+	return q[i].seq < q[j].seq
+}
+
+func (q delayedQueue[T]) Swap(i, j int) {
+	q[i], q[j] = q[j], q[i]
+	q[i].index = i
+	q[j].index = j
+}
+
+func (q *delayedQueue[T]) Push(x interface{}) {
+	t := x.(*task[T])
+	t.index = len(*q)
+	t.ready = false
+	*q = append(*q, t)
+}
+
+func (q *delayedQueue[T]) Pop() interface{} {
+	old := *q
+	t := old[len(old)-1]
+	old[len(old)-1] = nil
+	*q = old[:len(old)-1]
+	t.index = -1
+	return t
+}
+
+// schedule is the queue of jobs waiting for a worker. Jobs that are due
+// wait in ready; jobs with a "not before" time still to come wait in
+// delayed until promote moves them across.
+type schedule[T any] struct {
+	ready   readyQueue[T]
+	delayed delayedQueue[T]
+
+	// keys maps the dedup keys of queued jobs to the job.
+	keys map[string]*task[T]
+
+	// seq numbers jobs in the order they were submitted.
+	seq uint64
+}
+
+func (s *schedule[T]) len() int {
+	return len(s.ready) + len(s.delayed)
+}
+
+// push queues t, as due if it has no "not before" time after now.
+func (s *schedule[T]) push(t *task[T], now time.Time) {
+	s.seq++
+	t.seq = s.seq
+	if t.key != "" {
+		if s.keys == nil {
+			s.keys = make(map[string]*task[T])
+		}
+		s.keys[t.key] = t
+	}
+
+	if t.notBefore.After(now) {
+		heap.Push(&s.delayed, t)
+		return
+	}
+	heap.Push(&s.ready, t)
+}
+
+// promote moves the delayed jobs that are due at now to ready.
+func (s *schedule[T]) promote(now time.Time) {
+	for len(s.delayed) > 0 && !s.delayed[0].notBefore.After(now) {
+		heap.Push(&s.ready, heap.Pop(&s.delayed))
+	}
+}
+
+// pop removes and returns the next job that is due, or nil if there is
+// none.
+func (s *schedule[T]) pop() *task[T] {
+	if len(s.ready) == 0 {
+		return nil
+	}
+	return s.forget(heap.Pop(&s.ready).(*task[T]))
+}
+
+// popAny removes and returns a job whether it is due or not, or nil if
+// the schedule is empty. It is used to empty the schedule when the pool
+// stops.
+func (s *schedule[T]) popAny() *task[T] {
+	if t := s.pop(); t != nil {
+		return t
+	}
+	if len(s.delayed) == 0 {
+		return nil
+	}
+	return s.forget(heap.Pop(&s.delayed).(*task[T]))
+}
+
+// remove takes t out of the schedule, wherever it is waiting. It returns
+// false if t is not queued, because a worker has already taken it or it
+// has already been removed.
+func (s *schedule[T]) remove(t *task[T]) bool {
+	if t.index < 0 {
+		return false
+	}
+	if t.ready {
+		heap.Remove(&s.ready, t.index)
+	} else {
+		heap.Remove(&s.delayed, t.index)
+	}
+	s.forget(t)
+	return true
+}
+
+// forget removes t's dedup key, so a new job with the same key is queued
+// rather than collapsed into t once t has left the queue.
+func (s *schedule[T]) forget(t *task[T]) *task[T] {
+	if t.key != "" && s.keys[t.key] == t {
+		delete(s.keys, t.key)
+	}
+	return t
+}
+
+// next returns when the earliest delayed job is due, and false if there
+// are no delayed jobs.
+func (s *schedule[T]) next() (time.Time, bool) {
+	if len(s.delayed) == 0 {
+		return time.Time{}, false
+	}
+	return s.delayed[0].notBefore, true
+}
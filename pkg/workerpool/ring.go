@@ -0,0 +1,67 @@
+package workerpool
+
+import (
+	"hash/fnv"
+	"sort"
+	"strconv"
+)
+
+// ringReplicas is the number of points each lane gets on the ring. More
+// points spread keys more evenly between lanes.
+const ringReplicas = 64
+
+// ring is a consistent hash ring mapping keys to lanes. Each lane owns
+// the arcs of the ring ending at its points, and a key belongs to the lane
+// owning the point at or after the key's hash. Adding a lane only moves
+// the keys on the arcs its points split, about 1/n of them, where hashing
+// modulo the number of lanes would move nearly all of them.
+type ring struct {
+	hashes []uint32
+	lanes  []int
+}
+
+func newRing(lanes int) *ring {
+	r := &ring{}
+	for lane := 0; lane < lanes; lane++ {
+		for i := 0; i < ringReplicas; i++ {
+			r.hashes = append(r.hashes, hash(strconv.Itoa(lane)+"#"+strconv.Itoa(i)))
+			r.lanes = append(r.lanes, lane)
+		}
+	}
+	sort.Sort(r)
+	return r
+}
+
+func (r *ring) Len() int { return len(r.hashes) }
+
+func (r *ring) Less(i, j int) bool { return r.hashes[i] < r.hashes[j] }
+
+func (r *ring) Swap(i, j int) {
+	r.hashes[i], r.hashes[j] = r.hashes[j], r.hashes[i]
+	r.lanes[i], r.lanes[j] = r.lanes[j], r.lanes[i]
+}
+
+// lane returns the lane key belongs to.
+func (r *ring) lane(key string) int {
+	h := hash(key)
+	i := sort.Search(len(r.hashes), func(i int) bool { return r.hashes[i] >= h })
+	if i == len(r.hashes) {
+		i = 0
+	}
+	return r.lanes[i]
+}
+
+// hash is FNV-1a followed by the MurmurHash3 finaliser, which spreads
+// similar strings such as the points "1#0" and "1#1" across the ring.
+func hash(s string) uint32 {
+	f := fnv.New32a()
+	f.Write([]byte(s))
+	h := f.Sum32()
+
+	h ^= h >> 16
+	h *= 0x85ebca6b
+	h ^= h >> 13
+	h *= 0xc2b2ae35
+	h ^= h >> 16
+	return h
+}
@@ -0,0 +1,237 @@
+package workerpool
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"go-concurrency-exercises/pkg/clock"
+	"go-concurrency-exercises/pkg/leakcheck"
+)
+
+var epoch = time.Date(2022, 6, 1, 9, 0, 0, 0, time.UTC)
+
+// waitFor waits until cond, which is called with p.mu held, is true. The
+// pool broadcasts p.changed when a submitter starts waiting for room or
+// the timer for delayed jobs is moved.
+func (p *Pool[T]) waitFor(cond func() bool) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	for !cond() {
+		p.changed.Wait()
+	}
+}
+
+// record returns a job that sends name on order when it runs.
+func record(order chan<- string, name string) Job[string] {
+	return func(context.Context) (string, error) {
+		order <- name
+		return name, nil
+	}
+}
+
+func submit(t *testing.T, p *Pool[string], job Job[string], opts ...JobOption) *Future[string] {
+	t.Helper()
+
+	f, err := p.Submit(context.Background(), job, opts...)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return f
+}
+
+func expectOrder(t *testing.T, order <-chan string, want ...string) {
+	t.Helper()
+
+	for i, w := range want {
+		if got := <-order; got != w {
+			t.Fatalf("job %d: got %s, want %s", i, got, w)
+		}
+	}
+}
+
+// holdWorker submits a job that keeps the pool's only worker busy until
+// release is closed, so jobs submitted meanwhile queue up.
+func holdWorker(t *testing.T, p *Pool[string], release <-chan struct{}) {
+	t.Helper()
+
+	started := make(chan struct{})
+	submit(t, p, func(context.Context) (string, error) {
+		close(started)
+		<-release
+		return "", nil
+	})
+	<-started
+}
+
+func TestPriorityOrder(t *testing.T) {
+	leakcheck.Check(t)
+
+	p := NewPool[string](context.Background(), 1)
+	defer shutdown(t, p)
+
+	release := make(chan struct{})
+	holdWorker(t, p, release)
+
+	order := make(chan string, 10)
+	submit(t, p, record(order, "low-1"), Priority(-1))
+	submit(t, p, record(order, "normal-1"))
+	submit(t, p, record(order, "high"), Priority(10))
+	submit(t, p, record(order, "normal-2"))
+	submit(t, p, record(order, "low-2"), Priority(-1))
+	close(release)
+
+	expectOrder(t, order, "high", "normal-1", "normal-2", "low-1", "low-2")
+}
+
+func TestNotBeforeWakesIdleWorker(t *testing.T) {
+	leakcheck.Check(t)
+
+	c := clock.NewFake(epoch)
+	p := NewPool[string](context.Background(), 2, Clock(c))
+	defer shutdown(t, p)
+
+	order := make(chan string, 10)
+	f := submit(t, p, record(order, "delayed"), NotBefore(epoch.Add(time.Minute)))
+
+	// Both workers go back to waiting, with a timer set for the job.
+	c.BlockUntil(1)
+	c.Advance(59 * time.Second)
+	select {
+	case <-f.Done():
+		t.Fatal("delayed job ran early")
+	case <-time.After(10 * time.Millisecond):
+	}
+
+	c.Advance(time.Second)
+	expectOrder(t, order, "delayed")
+}
+
+func TestDelayedJobsDoNotBlockDueJobs(t *testing.T) {
+	leakcheck.Check(t)
+
+	c := clock.NewFake(epoch)
+	p := NewPool[string](context.Background(), 1, Clock(c))
+	defer shutdown(t, p)
+
+	release := make(chan struct{})
+	holdWorker(t, p, release)
+
+	order := make(chan string, 10)
+	submit(t, p, record(order, "in-2m"), NotBefore(epoch.Add(2*time.Minute)), Priority(10))
+	submit(t, p, record(order, "in-1m"), NotBefore(epoch.Add(time.Minute)))
+	submit(t, p, record(order, "now"))
+	close(release)
+
+	expectOrder(t, order, "now")
+
+	c.BlockUntil(1)
+	c.Advance(time.Minute)
+	expectOrder(t, order, "in-1m")
+
+	c.BlockUntil(1)
+	c.Advance(time.Minute)
+	expectOrder(t, order, "in-2m")
+}
+
+func TestDueJobsRunByPriority(t *testing.T) {
+	leakcheck.Check(t)
+
+	c := clock.NewFake(epoch)
+	p := NewPool[string](context.Background(), 1, Clock(c))
+	defer shutdown(t, p)
+
+	release := make(chan struct{})
+	holdWorker(t, p, release)
+
+	order := make(chan string, 10)
+	submit(t, p, record(order, "first-due"), NotBefore(epoch.Add(time.Second)))
+	submit(t, p, record(order, "urgent"), NotBefore(epoch.Add(2*time.Second)), Priority(5))
+
+	// Both become due while the worker is busy, so the higher
+	// priority job goes first even though it was due later.
+	c.Advance(time.Minute)
+	close(release)
+
+	expectOrder(t, order, "urgent", "first-due")
+}
+
+func TestEarlierDelayedJobResetsTimer(t *testing.T) {
+	leakcheck.Check(t)
+
+	c := clock.NewFake(epoch)
+	p := NewPool[string](context.Background(), 1, Clock(c))
+	defer shutdown(t, p)
+
+	order := make(chan string, 10)
+	submit(t, p, record(order, "in-1h"), NotBefore(epoch.Add(time.Hour)))
+	c.BlockUntil(1)
+
+	submit(t, p, record(order, "in-1m"), NotBefore(epoch.Add(time.Minute)))
+
+	// Wait for the worker to move the timer to the earlier job.
+	p.waitFor(func() bool { return p.timerAt.Equal(epoch.Add(time.Minute)) })
+
+	c.Advance(time.Minute)
+	expectOrder(t, order, "in-1m")
+
+	c.BlockUntil(1)
+	c.Advance(time.Hour)
+	expectOrder(t, order, "in-1h")
+}
+
+func TestDedupKey(t *testing.T) {
+	leakcheck.Check(t)
+
+	p := NewPool[string](context.Background(), 1)
+	defer shutdown(t, p)
+
+	release := make(chan struct{})
+	holdWorker(t, p, release)
+
+	order := make(chan string, 10)
+	f1 := submit(t, p, record(order, "report-1"), DedupKey("report"))
+	f2 := submit(t, p, record(order, "report-2"), DedupKey("report"))
+	submit(t, p, record(order, "other"), DedupKey("other"))
+
+	if f1 != f2 {
+		t.Error("job with the same key as a queued job got its own future")
+	}
+	if n := p.Queued(); n != 2 {
+		t.Errorf("got %d queued jobs, want 2", n)
+	}
+
+	close(release)
+	expectOrder(t, order, "report-1", "other")
+	if v, _ := f2.Result(); v != "report-1" {
+		t.Errorf("collapsed job got %q, want report-1", v)
+	}
+
+	// The key is free again once its job has left the queue.
+	f3 := submit(t, p, record(order, "report-3"), DedupKey("report"))
+	expectOrder(t, order, "report-3")
+	if f3 == f1 {
+		t.Error("job submitted after its key ran was collapsed into the old one")
+	}
+}
+
+func TestShutdownAbandonsDelayedJobs(t *testing.T) {
+	leakcheck.Check(t)
+
+	c := clock.NewFake(epoch)
+	p := NewPool[string](context.Background(), 1, Clock(c))
+
+	order := make(chan string, 10)
+	f := submit(t, p, record(order, "tomorrow"), NotBefore(epoch.Add(24*time.Hour)))
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	if err := p.Shutdown(ctx); err != context.DeadlineExceeded {
+		t.Errorf("Shutdown: got %v, want %v", err, context.DeadlineExceeded)
+	}
+
+	if _, err := f.Result(); err != ErrAbandoned {
+		t.Errorf("got %v, want %v", err, ErrAbandoned)
+	}
+}
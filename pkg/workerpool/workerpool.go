@@ -0,0 +1,579 @@
+// Package workerpool runs jobs on a fixed number of goroutines.
+//
+// It is the pool from cmd/cli turned into a library: jobs wait in a queue
+// until one of the workers takes them, and shutting down closes the queue
+// and waits for the workers to finish. On top of that each submitted job
+// gets a Future that delivers its result and error back to the submitter.
+//
+// Jobs run in the order they were submitted unless they are given a
+// Priority, a NotBefore time before which they must not start, or a
+// DedupKey that collapses them into an identical job that is still
+// waiting. The queue is a heap, and a timer on the pool's clock wakes the
+// workers when a delayed job becomes due.
+//
+// Each job runs with its own context, derived from the pool's. A Deadline
+// or Timeout job option bounds how long the job may wait and run, and the
+// job's Future can Cancel it whether it is still queued or already running.
+// A job dropped from the queue by either gets context.DeadlineExceeded or
+// context.Canceled as its error.
+//
+// A job that panics doesn't take the process down with it. The panic is
+// recovered and returned to the submitter as a *PanicError, and the worker
+// that ran the job is replaced, so the pool keeps the same number of
+// workers.
+package workerpool
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"runtime/debug"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"go-concurrency-exercises/pkg/clock"
+)
+
+// DefaultQueueSize is the number of jobs that can wait for a worker
+// before Submit blocks, or with RejectWhenFull, fails.
+const DefaultQueueSize = 64
+
+var (
+	// ErrQueueFull is returned by Submit when the queue is full and the
+	// pool was created with RejectWhenFull.
+	ErrQueueFull = errors.New("workerpool: queue full")
+
+	// ErrClosed is returned by Submit once the pool is shutting down.
+	ErrClosed = errors.New("workerpool: pool closed")
+
+	// ErrAbandoned is the error of jobs that were still queued when the
+	// pool stopped without running them.
+	ErrAbandoned = errors.New("workerpool: job abandoned")
+)
+
+// Job is a unit of work. It should return early if ctx is cancelled.
+type Job[T any] func(ctx context.Context) (T, error)
+
+// Option configures a Pool.
+type Option func(*config)
+
+type config struct {
+	queueSize int
+	reject    bool
+	onPanic   func(*PanicError)
+	clock     clock.Clock
+}
+
+// QueueSize sets the number of jobs that can wait for a worker, on top of
+// the jobs that idle workers can take straight away. With a queue size of
+// zero, Submit waits until a worker is free.
+func QueueSize(n int) Option {
+	return func(c *config) {
+		if n >= 0 {
+			c.queueSize = n
+		}
+	}
+}
+
+// RejectWhenFull makes Submit return ErrQueueFull straight away when the
+// queue is full, instead of waiting for room.
+func RejectWhenFull() Option {
+	return func(c *config) {
+		c.reject = true
+	}
+}
+
+// OnPanic sets a function to call, on the worker's goroutine, each time a
+// job panics. Use it to count or log panics for alerting.
+func OnPanic(f func(*PanicError)) Option {
+	return func(c *config) {
+		c.onPanic = f
+	}
+}
+
+// Clock sets the clock NotBefore times are measured against. Tests pass a
+// fake clock to control when delayed jobs become due.
+func Clock(c clock.Clock) Option {
+	return func(cfg *config) {
+		cfg.clock = c
+	}
+}
+
+// JobOption configures a single submitted job.
+type JobOption func(*jobConfig)
+
+type jobConfig struct {
+	priority  int
+	notBefore time.Time
+	key       string
+	deadline  time.Time
+	timeout   time.Duration
+}
+
+// Priority sets the job's priority. Jobs with a higher priority run before
+// jobs with a lower one, whatever order they were submitted in. The
+// default priority is zero.
+func Priority(p int) JobOption {
+	return func(c *jobConfig) {
+		c.priority = p
+	}
+}
+
+// NotBefore stops the job starting before t. Until then it waits in the
+// queue without holding up the jobs behind it.
+func NotBefore(t time.Time) JobOption {
+	return func(c *jobConfig) {
+		c.notBefore = t
+	}
+}
+
+// DedupKey collapses the job into any job with the same key that is still
+// waiting in the queue. Submit then returns the waiting job's Future, and
+// the new job is dropped along with its other options. Once a job has
+// started, a new job with its key is queued as normal.
+func DedupKey(key string) JobOption {
+	return func(c *jobConfig) {
+		c.key = key
+	}
+}
+
+// Deadline sets a time by which the job must finish. The job's context is
+// cancelled with context.DeadlineExceeded at the deadline, and if the job
+// is still queued then, it is dropped from the queue and its error is
+// context.DeadlineExceeded.
+func Deadline(t time.Time) JobOption {
+	return func(c *jobConfig) {
+		c.deadline = t
+	}
+}
+
+// Timeout sets the job's deadline to d after it is submitted. The time
+// spent waiting in the queue counts towards it.
+func Timeout(d time.Duration) JobOption {
+	return func(c *jobConfig) {
+		c.timeout = d
+	}
+}
+
+// PanicError is the error returned for a job that panicked.
+type PanicError struct {
+	// Value is the value the job passed to panic.
+	Value interface{}
+
+	// Stack is the stack trace of the goroutine that panicked.
+	Stack []byte
+}
+
+func (e *PanicError) Error() string {
+	return fmt.Sprintf("workerpool: job panicked: %v\n\n%s", e.Value, e.Stack)
+}
+
+// Unwrap returns the value passed to panic if it is an error.
+func (e *PanicError) Unwrap() error {
+	err, _ := e.Value.(error)
+	return err
+}
+
+// Pool runs jobs returning T on a fixed number of worker goroutines.
+type Pool[T any] struct {
+	ctx       context.Context
+	cancel    context.CancelFunc
+	clock     clock.Clock
+	queueSize int
+	reject    bool
+	onPanic   func(*PanicError)
+
+	// panics counts the jobs that have panicked. Updated atomically.
+	panics uint64
+
+	wg sync.WaitGroup
+
+	mu     sync.Mutex
+	queue  schedule[T]
+	closed bool
+
+	// idle is the number of workers waiting for a job.
+	idle int
+
+	// work is closed, and replaced, to wake the workers waiting for a
+	// job when one is queued or becomes due, or the pool stops.
+	work chan struct{}
+
+	// room is closed, and replaced, to wake the submitters waiting for
+	// room in the queue when a job leaves it, a worker becomes idle, or
+	// the pool stops.
+	room chan struct{}
+
+	// timer wakes the workers when the earliest delayed job is due,
+	// at timerAt.
+	timer   clock.Timer
+	timerAt time.Time
+
+	// waiting is the number of submitters waiting for room in the queue.
+	// changed is broadcast when it or timerAt changes, so tests can wait
+	// for the pool to get there rather than sleep.
+	waiting int
+	changed *sync.Cond
+}
+
+type task[T any] struct {
+	job    Job[T]
+	future *Future[T]
+
+	// ctx is the job's own context, and cancel cancels it.
+	ctx    context.Context
+	cancel context.CancelFunc
+
+	// expiry drops the job from the queue at its deadline, if it has one.
+	expiry clock.Timer
+
+	priority  int
+	notBefore time.Time
+	key       string
+	seq       uint64
+
+	// index is the job's position in the heap it is waiting in, ready
+	// or delayed, or -1 once it has left the queue.
+	index int
+	ready bool
+}
+
+// NewPool starts workers goroutines to run jobs. Jobs are passed a
+// context derived from ctx, and cancelling ctx stops the pool: running
+// jobs see their context cancelled and queued jobs are abandoned.
+func NewPool[T any](ctx context.Context, workers int, opts ...Option) *Pool[T] {
+	c := config{queueSize: DefaultQueueSize}
+	for _, opt := range opts {
+		opt(&c)
+	}
+	if c.clock == nil {
+		c.clock = clock.New()
+	}
+	if workers < 1 {
+		workers = 1
+	}
+
+	ctx, cancel := context.WithCancel(ctx)
+	p := &Pool[T]{
+		ctx:       ctx,
+		cancel:    cancel,
+		clock:     c.clock,
+		queueSize: c.queueSize,
+		reject:    c.reject,
+		onPanic:   c.onPanic,
+		work:      make(chan struct{}),
+		room:      make(chan struct{}),
+	}
+	p.changed = sync.NewCond(&p.mu)
+
+	p.wg.Add(workers)
+	for i := 0; i < workers; i++ {
+		go p.worker()
+	}
+
+	// Wake everyone when the pool's context is done, so workers can
+	// abandon the queue and exit.
+	go func() {
+		<-ctx.Done()
+
+		p.mu.Lock()
+		if p.timer != nil {
+			p.timer.Stop()
+		}
+		p.wakeWorkers()
+		p.wakeSubmitters()
+		p.mu.Unlock()
+	}()
+
+	return p
+}
+
+// wakeWorkers wakes the workers waiting for a job. p.mu must be held.
+func (p *Pool[T]) wakeWorkers() {
+	close(p.work)
+	p.work = make(chan struct{})
+}
+
+// wakeSubmitters wakes the submitters waiting for room in the queue.
+// p.mu must be held.
+func (p *Pool[T]) wakeSubmitters() {
+	close(p.room)
+	p.room = make(chan struct{})
+}
+
+// worker runs jobs from the queue until it is closed and empty.
+func (p *Pool[T]) worker() {
+	defer p.wg.Done()
+
+	for {
+		t, ok := p.next()
+		if !ok {
+			return
+		}
+		if t.expiry != nil {
+			t.expiry.Stop()
+		}
+
+		if p.ctx.Err() != nil {
+			t.cancel()
+			var zero T
+			t.future.complete(zero, ErrAbandoned)
+			continue
+		}
+
+		// The job's deadline may have passed as the worker took it.
+		if err := t.ctx.Err(); err != nil {
+			t.cancel()
+			var zero T
+			t.future.complete(zero, err)
+			continue
+		}
+
+		if !p.run(t) {
+			// The job panicked. Hand over to a new worker, so the
+			// pool keeps the same number of workers. The Add happens
+			// before this worker's Done, so Shutdown waits for it.
+			p.wg.Add(1)
+			go p.worker()
+			return
+		}
+	}
+}
+
+// next waits for a job that is due and takes it off the queue. Once the
+// pool's context is done it returns the queued jobs straight away, due or
+// not, to be abandoned. It returns false when the pool has stopped and
+// the queue is empty.
+func (p *Pool[T]) next() (*task[T], bool) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	for {
+		var t *task[T]
+		if p.ctx.Err() != nil {
+			t = p.queue.popAny()
+		} else {
+			p.queue.promote(p.clock.Now())
+			t = p.queue.pop()
+		}
+
+		if t != nil {
+			p.wakeSubmitters()
+			return t, true
+		}
+		if p.queue.len() == 0 && (p.closed || p.ctx.Err() != nil) {
+			return nil, false
+		}
+
+		p.setTimer()
+
+		// An idle worker makes room for another job.
+		p.idle++
+		p.wakeSubmitters()
+
+		work := p.work
+		p.mu.Unlock()
+		<-work
+		p.mu.Lock()
+		p.idle--
+	}
+}
+
+// setTimer makes sure the timer is set for when the earliest delayed job
+// is due. p.mu must be held.
+func (p *Pool[T]) setTimer() {
+	at, ok := p.queue.next()
+	if !ok || (p.timer != nil && at.Equal(p.timerAt)) {
+		return
+	}
+
+	d := at.Sub(p.clock.Now())
+	p.timerAt = at
+	p.changed.Broadcast()
+	if p.timer == nil {
+		p.timer = p.clock.AfterFunc(d, func() {
+			p.mu.Lock()
+			p.wakeWorkers()
+			p.mu.Unlock()
+		})
+		return
+	}
+	p.timer.Reset(d)
+}
+
+// run runs t's job and completes its future. If the job panics, run
+// recovers, completes the future with a *PanicError and returns false.
+func (p *Pool[T]) run(t *task[T]) (ok bool) {
+	defer t.cancel()
+	defer func() {
+		r := recover()
+		if r == nil {
+			return
+		}
+
+		err := &PanicError{Value: r, Stack: debug.Stack()}
+		atomic.AddUint64(&p.panics, 1)
+		if p.onPanic != nil {
+			p.onPanic(err)
+		}
+
+		var zero T
+		t.future.complete(zero, err)
+		ok = false
+	}()
+
+	v, err := t.job(t.ctx)
+	t.future.complete(v, err)
+	return true
+}
+
+// Panics returns the number of jobs that have panicked.
+func (p *Pool[T]) Panics() uint64 {
+	return atomic.LoadUint64(&p.panics)
+}
+
+// Queued returns the number of jobs waiting in the queue, including
+// delayed jobs that are not yet due.
+func (p *Pool[T]) Queued() int {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	return p.queue.len()
+}
+
+// Submit queues job to be run by the next free worker, and returns a
+// Future for its result. If the queue is full it waits for room until ctx
+// is done, unless the pool was created with RejectWhenFull, in which case
+// it returns ErrQueueFull. Once Shutdown has been called, or the pool's
+// context is done, it returns ErrClosed.
+func (p *Pool[T]) Submit(ctx context.Context, job Job[T], opts ...JobOption) (*Future[T], error) {
+	var c jobConfig
+	for _, opt := range opts {
+		opt(&c)
+	}
+
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	for {
+		if p.closed || p.ctx.Err() != nil {
+			return nil, ErrClosed
+		}
+
+		if c.key != "" {
+			if t, ok := p.queue.keys[c.key]; ok {
+				return t.future, nil
+			}
+		}
+
+		// Idle workers take jobs off the queue as soon as they
+		// are woken, so they count as room in the queue.
+		if p.queue.len() < p.queueSize+p.idle {
+			break
+		}
+
+		if p.reject {
+			return nil, ErrQueueFull
+		}
+
+		room := p.room
+		p.waiting++
+		p.changed.Broadcast()
+		p.mu.Unlock()
+		select {
+		case <-room:
+		case <-ctx.Done():
+			p.mu.Lock()
+			p.waiting--
+			return nil, ctx.Err()
+		}
+		p.mu.Lock()
+		p.waiting--
+	}
+
+	now := p.clock.Now()
+	t := &task[T]{
+		job:       job,
+		future:    newFuture[T](),
+		priority:  c.priority,
+		notBefore: c.notBefore,
+		key:       c.key,
+	}
+	t.future.cancel = func() { p.abort(t, context.Canceled) }
+
+	deadline := c.deadline
+	if c.timeout > 0 && (deadline.IsZero() || now.Add(c.timeout).Before(deadline)) {
+		deadline = now.Add(c.timeout)
+	}
+	if deadline.IsZero() {
+		t.ctx, t.cancel = context.WithCancel(p.ctx)
+	} else {
+		t.ctx, t.cancel = clock.WithDeadline(p.ctx, p.clock, deadline)
+		t.expiry = p.clock.AfterFunc(deadline.Sub(now), func() {
+			p.abort(t, context.DeadlineExceeded)
+		})
+	}
+
+	p.queue.push(t, now)
+	p.wakeWorkers()
+
+	return t.future, nil
+}
+
+// abort stops t. If t is still queued it is taken out of the queue and its
+// future completed with err. If a worker has taken it, its context is
+// cancelled, unless err is context.DeadlineExceeded: the context then
+// reaches its deadline by itself.
+func (p *Pool[T]) abort(t *task[T], err error) {
+	p.mu.Lock()
+	queued := p.queue.remove(t)
+	if queued {
+		p.wakeSubmitters()
+	}
+	p.mu.Unlock()
+
+	if queued {
+		if t.expiry != nil {
+			t.expiry.Stop()
+		}
+		t.cancel()
+		var zero T
+		t.future.complete(zero, err)
+		return
+	}
+
+	if err != context.DeadlineExceeded {
+		t.cancel()
+	}
+}
+
+// Shutdown stops the pool accepting jobs and waits for the workers to run
+// the jobs already queued, including delayed jobs once they are due. If
+// ctx is done first, the jobs still queued are abandoned, running jobs
+// have their context cancelled, and Shutdown returns ctx.Err() without
+// waiting for them.
+func (p *Pool[T]) Shutdown(ctx context.Context) error {
+	p.mu.Lock()
+	if !p.closed {
+		p.closed = true
+		p.wakeWorkers()
+		p.wakeSubmitters()
+	}
+	p.mu.Unlock()
+
+	finished := make(chan struct{})
+	go func() {
+		p.wg.Wait()
+		close(finished)
+	}()
+
+	select {
+	case <-finished:
+		p.cancel()
+		return nil
+	case <-ctx.Done():
+		p.cancel()
+		return ctx.Err()
+	}
+}
@@ -0,0 +1,282 @@
+package workerpool
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"go-concurrency-exercises/pkg/leakcheck"
+)
+
+func shutdown[T any](t *testing.T, p *Pool[T]) {
+	t.Helper()
+
+	if err := p.Shutdown(context.Background()); err != nil {
+		t.Fatalf("Shutdown: %v", err)
+	}
+}
+
+func TestResults(t *testing.T) {
+	leakcheck.Check(t)
+
+	ctx := context.Background()
+	p := NewPool[int](ctx, 5)
+	defer shutdown(t, p)
+
+	futures := make([]*Future[int], 100)
+	for i := range futures {
+		i := i
+		f, err := p.Submit(ctx, func(context.Context) (int, error) {
+			return i * i, nil
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+		futures[i] = f
+	}
+
+	for i, f := range futures {
+		v, err := f.Result()
+		if err != nil || v != i*i {
+			t.Errorf("job %d: got %d, %v, want %d, nil", i, v, err, i*i)
+		}
+	}
+}
+
+func TestErrors(t *testing.T) {
+	leakcheck.Check(t)
+
+	ctx := context.Background()
+	p := NewPool[string](ctx, 2)
+	defer shutdown(t, p)
+
+	errBoom := errors.New("boom")
+	f, err := p.Submit(ctx, func(context.Context) (string, error) {
+		return "", fmt.Errorf("job: %w", errBoom)
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := f.Wait(ctx); !errors.Is(err, errBoom) {
+		t.Errorf("got %v, want %v", err, errBoom)
+	}
+}
+
+func TestWorkersLimitConcurrency(t *testing.T) {
+	leakcheck.Check(t)
+
+	ctx := context.Background()
+	p := NewPool[struct{}](ctx, 3)
+
+	var running, max int64
+	var wg sync.WaitGroup
+	for i := 0; i < 30; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			p.Submit(ctx, func(context.Context) (struct{}, error) {
+				n := atomic.AddInt64(&running, 1)
+				for {
+					m := atomic.LoadInt64(&max)
+					if n <= m || atomic.CompareAndSwapInt64(&max, m, n) {
+						break
+					}
+				}
+				time.Sleep(time.Millisecond)
+				atomic.AddInt64(&running, -1)
+				return struct{}{}, nil
+			})
+		}()
+	}
+	wg.Wait()
+	shutdown(t, p)
+
+	if max > 3 {
+		t.Errorf("%d jobs ran at once, want at most 3", max)
+	}
+}
+
+// blockWorkers submits a job per worker that runs until release is
+// closed, and waits for them all to start.
+func blockWorkers(t *testing.T, p *Pool[int], workers int, release <-chan struct{}) {
+	t.Helper()
+
+	started := make(chan struct{})
+	for i := 0; i < workers; i++ {
+		if _, err := p.Submit(context.Background(), func(context.Context) (int, error) {
+			started <- struct{}{}
+			<-release
+			return 0, nil
+		}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	for i := 0; i < workers; i++ {
+		<-started
+	}
+}
+
+func TestRejectWhenFull(t *testing.T) {
+	leakcheck.Check(t)
+
+	ctx := context.Background()
+	p := NewPool[int](ctx, 1, QueueSize(1), RejectWhenFull())
+	defer shutdown(t, p)
+
+	release := make(chan struct{})
+	defer close(release)
+	blockWorkers(t, p, 1, release)
+
+	job := func(context.Context) (int, error) { return 1, nil }
+	if _, err := p.Submit(ctx, job); err != nil {
+		t.Fatalf("first queued job: %v", err)
+	}
+	if _, err := p.Submit(ctx, job); err != ErrQueueFull {
+		t.Errorf("got %v, want %v", err, ErrQueueFull)
+	}
+}
+
+func TestSubmitBlocksWhenFull(t *testing.T) {
+	leakcheck.Check(t)
+
+	p := NewPool[int](context.Background(), 1, QueueSize(0))
+	defer shutdown(t, p)
+
+	release := make(chan struct{})
+	defer close(release)
+	blockWorkers(t, p, 1, release)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+
+	_, err := p.Submit(ctx, func(context.Context) (int, error) { return 1, nil })
+	if err != context.DeadlineExceeded {
+		t.Errorf("got %v, want %v", err, context.DeadlineExceeded)
+	}
+}
+
+func TestShutdownDrains(t *testing.T) {
+	leakcheck.Check(t)
+
+	ctx := context.Background()
+	p := NewPool[int](ctx, 2)
+
+	var ran int64
+	var futures []*Future[int]
+	for i := 0; i < 20; i++ {
+		f, err := p.Submit(ctx, func(context.Context) (int, error) {
+			time.Sleep(time.Millisecond)
+			atomic.AddInt64(&ran, 1)
+			return 0, nil
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+		futures = append(futures, f)
+	}
+
+	shutdown(t, p)
+
+	if ran != 20 {
+		t.Errorf("ran %d jobs before Shutdown returned, want 20", ran)
+	}
+	for _, f := range futures {
+		select {
+		case <-f.Done():
+		default:
+			t.Fatal("future not done after Shutdown")
+		}
+	}
+
+	if _, err := p.Submit(ctx, func(context.Context) (int, error) { return 0, nil }); err != ErrClosed {
+		t.Errorf("Submit after Shutdown: got %v, want %v", err, ErrClosed)
+	}
+}
+
+func TestShutdownAbandons(t *testing.T) {
+	leakcheck.Check(t)
+
+	p := NewPool[int](context.Background(), 1)
+
+	// The running job only stops when its context is cancelled.
+	started := make(chan struct{})
+	running, err := p.Submit(context.Background(), func(ctx context.Context) (int, error) {
+		close(started)
+		<-ctx.Done()
+		return 0, ctx.Err()
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	<-started
+
+	queued, err := p.Submit(context.Background(), func(context.Context) (int, error) {
+		return 1, nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	if err := p.Shutdown(ctx); err != context.DeadlineExceeded {
+		t.Errorf("Shutdown: got %v, want %v", err, context.DeadlineExceeded)
+	}
+
+	if _, err := running.Result(); err != context.Canceled {
+		t.Errorf("running job: got %v, want %v", err, context.Canceled)
+	}
+	if _, err := queued.Result(); err != ErrAbandoned {
+		t.Errorf("queued job: got %v, want %v", err, ErrAbandoned)
+	}
+}
+
+func TestShutdownWakesBlockedSubmit(t *testing.T) {
+	leakcheck.Check(t)
+
+	p := NewPool[int](context.Background(), 1, QueueSize(0))
+
+	release := make(chan struct{})
+	blockWorkers(t, p, 1, release)
+
+	errc := make(chan error)
+	go func() {
+		_, err := p.Submit(context.Background(), func(context.Context) (int, error) { return 0, nil })
+		errc <- err
+	}()
+
+	// Wait for Submit to block on the full queue.
+	p.waitFor(func() bool { return p.waiting == 1 })
+
+	shutdownErr := make(chan error)
+	go func() {
+		shutdownErr <- p.Shutdown(context.Background())
+	}()
+
+	if err := <-errc; err != ErrClosed {
+		t.Errorf("blocked Submit: got %v, want %v", err, ErrClosed)
+	}
+
+	close(release)
+	if err := <-shutdownErr; err != nil {
+		t.Errorf("Shutdown: %v", err)
+	}
+}
+
+func TestPoolContextCancelled(t *testing.T) {
+	leakcheck.Check(t)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	p := NewPool[int](ctx, 1)
+	defer shutdown(t, p)
+
+	cancel()
+	if _, err := p.Submit(context.Background(), func(context.Context) (int, error) { return 0, nil }); err != ErrClosed {
+		t.Errorf("got %v, want %v", err, ErrClosed)
+	}
+}